@@ -0,0 +1,171 @@
+// Package cliauth implements the authentication options shared by the
+// command line tools.
+package cliauth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/KarpelesLab/rest"
+)
+
+// PassphraseEnv is the environment variable holding the passphrase used to
+// encrypt stored tokens
+const PassphraseEnv = "REST_TOKEN_PASSPHRASE"
+
+var (
+	tokenFlag = flag.String("token", "", "OAuth2 access token to authenticate with")
+	tokenFile = flag.String("token-file", "", "file containing an OAuth2 token, either as json or as a raw access token")
+	ClientID  = flag.String("client-id", "", "OAuth2 client id, used to renew expired tokens")
+)
+
+// LoadToken returns the token specified on the command line, if any
+func LoadToken() (*rest.Token, error) {
+	if *tokenFlag != "" && *tokenFile != "" {
+		return nil, errors.New("-token and -token-file cannot be used together")
+	}
+
+	var tok *rest.Token
+	switch {
+	case *tokenFlag != "":
+		tok = &rest.Token{AccessToken: *tokenFlag}
+	case *tokenFile != "":
+		buf, err := os.ReadFile(*tokenFile)
+		if err != nil {
+			return nil, err
+		}
+		buf = bytes.TrimSpace(buf)
+		if rest.IsSealedToken(buf) {
+			if tok, err = openToken(buf); err != nil {
+				return nil, fmt.Errorf("failed to decrypt token file %s: %w", *tokenFile, err)
+			}
+		} else if len(buf) > 0 && buf[0] == '{' {
+			if err := json.Unmarshal(buf, &tok); err != nil {
+				return nil, fmt.Errorf("failed to parse token file %s: %w", *tokenFile, err)
+			}
+		} else {
+			tok = &rest.Token{AccessToken: string(buf)}
+		}
+		if tok == nil || tok.AccessToken == "" {
+			return nil, fmt.Errorf("no access token found in %s", *tokenFile)
+		}
+	default:
+		return nil, nil
+	}
+
+	if *ClientID != "" {
+		tok.ClientID = *ClientID
+	}
+	return tok, nil
+}
+
+// Use returns ctx authenticated with the token given on the command line,
+// or the one saved by login if none was given
+func Use(ctx context.Context) (context.Context, error) {
+	tok, err := LoadToken()
+	if err != nil {
+		return nil, err
+	}
+	if tok == nil {
+		tok = LoadStoredToken()
+	}
+	if tok != nil {
+		ctx = tok.Use(ctx)
+	}
+	return ctx, nil
+}
+
+// storedToken is the token obtained via login, if it was loaded, along with
+// the access token it had at that time
+var (
+	storedToken       *rest.Token
+	storedAccessToken string
+)
+
+// StoredTokenPath returns the location of the token saved by login
+func StoredTokenPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "restupload", "token.json"), nil
+}
+
+// LoadStoredToken returns the token saved by login, if any
+func LoadStoredToken() *rest.Token {
+	fn, err := StoredTokenPath()
+	if err != nil {
+		return nil
+	}
+	buf, err := os.ReadFile(fn)
+	if err != nil {
+		return nil
+	}
+	var tok *rest.Token
+	if rest.IsSealedToken(buf) {
+		if tok, err = openToken(buf); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to decrypt stored token: %s\n", err)
+			return nil
+		}
+	} else if err := json.Unmarshal(buf, &tok); err != nil {
+		return nil
+	}
+	if tok == nil || tok.AccessToken == "" {
+		return nil
+	}
+	storedToken = tok
+	storedAccessToken = tok.AccessToken
+	return tok
+}
+
+// SaveStoredToken stores tok so it can be used by later invocations. The
+// token is encrypted if a passphrase is set in the environment.
+func SaveStoredToken(tok *rest.Token) error {
+	fn, err := StoredTokenPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(fn), 0700); err != nil {
+		return err
+	}
+	var buf []byte
+	if pass := os.Getenv(PassphraseEnv); pass != "" {
+		buf, err = rest.SealToken(tok, pass)
+	} else {
+		buf, err = json.Marshal(tok)
+	}
+	if err != nil {
+		return err
+	}
+	tmp := fn + ".tmp"
+	if err := os.WriteFile(tmp, buf, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, fn)
+}
+
+// SaveRenewedToken stores the token loaded from disk again if it was
+// renewed while running
+func SaveRenewedToken() {
+	if storedToken == nil || storedToken.AccessToken == storedAccessToken {
+		return
+	}
+	if err := SaveStoredToken(storedToken); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to save renewed token: %s\n", err)
+	}
+}
+
+// openToken decrypts a sealed token with the passphrase from the environment
+func openToken(buf []byte) (*rest.Token, error) {
+	pass := os.Getenv(PassphraseEnv)
+	if pass == "" {
+		return nil, fmt.Errorf("token is encrypted, set %s to the passphrase", PassphraseEnv)
+	}
+	return rest.OpenToken(buf, pass)
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/KarpelesLab/rest"
+	"github.com/KarpelesLab/rest/cli/internal/cliauth"
+	"github.com/KarpelesLab/webutil"
+)
+
+// perform a call to the given API path
+
+const (
+	exitOK       = 0
+	exitError    = 1 // transport or other local error
+	exitUsage    = 2
+	exitAuth     = 3 // 401/403 or login required
+	exitNotFound = 4
+	exitClient   = 5 // other 4xx errors
+	exitServer   = 6 // 5xx errors
+)
+
+// paramList collects -p key=value flags
+type paramList []string
+
+func (p *paramList) String() string {
+	return strings.Join(*p, ",")
+}
+
+func (p *paramList) Set(v string) error {
+	if !strings.Contains(v, "=") {
+		return fmt.Errorf("invalid parameter %q, expected key=value", v)
+	}
+	*p = append(*p, v)
+	return nil
+}
+
+var (
+	method = flag.String("X", "GET", "request method")
+	data   = flag.String("d", "", "params as json or url encoded string, @file to read from a file or - for stdin")
+	host   = flag.String("host", "", "API host (defaults to "+rest.Host+")")
+	raw    = flag.Bool("raw", false, "output compact json instead of pretty printed")
+	full   = flag.Bool("full", false, "output the whole response instead of only its data")
+
+	validate = flag.Bool("validate", false, "check parameters against the API description before performing the call")
+	describe = flag.Bool("describe", false, "output the API description of the object at path instead of calling it")
+	complete = flag.Bool("complete", false, "list the methods and fields of the object at path, for shell completion")
+
+	pList paramList
+)
+
+func init() {
+	flag.Var(&pList, "p", "parameter as key=value, json values are decoded (can be repeated)")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] <path>\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+}
+
+func main() {
+	flag.Parse()
+	if err := rest.ConfigFromEnv(); err != nil {
+		log.Printf("invalid environment configuration: %s", err)
+		os.Exit(exitUsage)
+	}
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(exitUsage)
+	}
+	if *host != "" {
+		rest.Host = *host
+	}
+
+	if err := cliauth.ConfigureNetwork(); err != nil {
+		log.Printf("invalid network options: %s", err)
+		os.Exit(exitUsage)
+	}
+
+	ctx, err := cliauth.Use(context.Background())
+	if err != nil {
+		log.Printf("failed to load token: %s", err)
+		os.Exit(exitUsage)
+	}
+
+	path := flag.Arg(0)
+	if *describe || *complete {
+		os.Exit(runDescribe(ctx, path))
+	}
+
+	p, err := buildParams()
+	if err != nil {
+		log.Printf("invalid parameters: %s", err)
+		os.Exit(exitUsage)
+	}
+
+	if *validate {
+		info, err := rest.Describe(ctx, path)
+		if err != nil {
+			log.Printf("failed to describe %s: %s", path, err)
+			os.Exit(exitCode(err))
+		}
+		if err := info.Validate(path, strings.ToUpper(*method), p); err != nil {
+			log.Printf("%s", err)
+			os.Exit(exitUsage)
+		}
+	}
+
+	res, err := rest.Do(ctx, path, strings.ToUpper(*method), p)
+	cliauth.SaveRenewedToken()
+	cliauth.SaveHAR()
+	if err != nil {
+		log.Printf("%s", err)
+		os.Exit(exitCode(err))
+	}
+
+	if err := output(res); err != nil {
+		log.Printf("failed to output response: %s", err)
+		os.Exit(exitError)
+	}
+}
+
+// runDescribe outputs the description of the object at path, or completion
+// candidates, and returns the exit code
+func runDescribe(ctx context.Context, path string) int {
+	info, err := rest.Describe(ctx, path)
+	cliauth.SaveRenewedToken()
+	cliauth.SaveHAR()
+	if err != nil {
+		log.Printf("failed to describe %s: %s", path, err)
+		return exitCode(err)
+	}
+
+	if *describe {
+		enc := json.NewEncoder(os.Stdout)
+		if !*raw {
+			enc.SetIndent("", "  ")
+		}
+		if err := enc.Encode(info); err != nil {
+			log.Printf("failed to output description: %s", err)
+			return exitError
+		}
+		return exitOK
+	}
+
+	// completion: methods after ':', otherwise parameters
+	obj, _, _ := strings.Cut(path, ":")
+	for _, m := range info.Methods {
+		fmt.Printf("%s:%s\n", obj, m.Name)
+	}
+	for _, f := range info.Fields {
+		fmt.Printf("%s=\n", f.Name)
+	}
+	return exitOK
+}
+
+// buildParams returns the request parameters from -d and -p flags
+func buildParams() (rest.Param, error) {
+	p := make(rest.Param)
+
+	if *data != "" {
+		var buf []byte
+		var err error
+		switch {
+		case *data == "-":
+			buf, err = io.ReadAll(os.Stdin)
+		case strings.HasPrefix(*data, "@"):
+			buf, err = os.ReadFile((*data)[1:])
+		default:
+			buf = []byte(*data)
+		}
+		if err != nil {
+			return nil, err
+		}
+		buf = bytes.TrimSpace(buf)
+		if len(buf) > 0 && buf[0] == '{' {
+			if err := json.Unmarshal(buf, &p); err != nil {
+				return nil, err
+			}
+		} else {
+			for k, v := range webutil.ParsePhpQuery(string(buf)) {
+				p[k] = v
+			}
+		}
+	}
+
+	for _, kv := range pList {
+		k, v, _ := strings.Cut(kv, "=")
+		var val any
+		if err := json.Unmarshal([]byte(v), &val); err != nil {
+			// not json, use as string
+			val = v
+		}
+		p[k] = val
+	}
+	return p, nil
+}
+
+func output(res *rest.Response) error {
+	var v any
+	var err error
+	if *full {
+		v, err = res.FullRaw()
+	} else {
+		v, err = res.Value()
+	}
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	if !*raw {
+		enc.SetIndent("", "  ")
+	}
+	return enc.Encode(v)
+}
+
+// exitCode returns the exit code matching err
+func exitCode(err error) int {
+	if errors.Is(err, rest.ErrLoginRequired) {
+		return exitAuth
+	}
+	code := 0
+	var restErr *rest.Error
+	var httpErr *rest.HttpError
+	switch {
+	case errors.As(err, &restErr) && restErr.Response != nil:
+		code = restErr.Response.Code
+	case errors.As(err, &httpErr):
+		code = httpErr.Code
+	}
+
+	switch {
+	case code == 401 || code == 403:
+		return exitAuth
+	case code == 404:
+		return exitNotFound
+	case code >= 400 && code < 500:
+		return exitClient
+	case code >= 500:
+		return exitServer
+	default:
+		return exitError
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/token"
+	"strings"
+	"unicode"
+
+	"github.com/KarpelesLab/rest"
+)
+
+// generator accumulates the generated code
+type generator struct {
+	buf   bytes.Buffer
+	names map[string]bool
+}
+
+func newGenerator(pkg string) *generator {
+	g := &generator{names: make(map[string]bool)}
+	fmt.Fprintf(&g.buf, "// Code generated by restgen. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&g.buf, "package %s\n\n", pkg)
+	fmt.Fprintf(&g.buf, "import (\n\t\"context\"\n\n\t\"github.com/KarpelesLab/rest\"\n)\n")
+	return g
+}
+
+// source returns the formatted generated code
+func (g *generator) source() ([]byte, error) {
+	return format.Source(g.buf.Bytes())
+}
+
+// object generates the struct and call wrappers for the object at path
+func (g *generator) object(path string, info *rest.ObjectInfo) {
+	name := g.unique(goName(strings.ReplaceAll(path, "/", "_"), true))
+
+	fmt.Fprintf(&g.buf, "\n// %s is a %s object\n", name, path)
+	fmt.Fprintf(&g.buf, "type %s struct {\n", name)
+	fields := make(map[string]bool)
+	for _, f := range info.Fields {
+		fname := goName(f.Name, false)
+		for fields[fname] {
+			fname += "_"
+		}
+		fields[fname] = true
+		fmt.Fprintf(&g.buf, "\t%s %s `json:\"%s,omitempty\"`\n", fname, goType(f), f.Name)
+	}
+	fmt.Fprintf(&g.buf, "}\n")
+
+	fmt.Fprintf(&g.buf, `
+// Get%[1]s fetches the %[2]s object with the given id
+func Get%[1]s(ctx context.Context, id string) (*%[1]s, error) {
+	res := &%[1]s{}
+	if err := rest.Apply(ctx, %[3]q+id, "GET", nil, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// List%[1]s returns the %[2]s objects matching params
+func List%[1]s(ctx context.Context, params rest.Param) ([]*%[1]s, error) {
+	var res []*%[1]s
+	if err := rest.Apply(ctx, %[2]q, "GET", params, &res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Create%[1]s creates a %[2]s object
+func Create%[1]s(ctx context.Context, params rest.Param) (*%[1]s, error) {
+	res := &%[1]s{}
+	if err := rest.Apply(ctx, %[2]q, "POST", params, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Update%[1]s updates the %[2]s object with the given id
+func Update%[1]s(ctx context.Context, id string, params rest.Param) (*%[1]s, error) {
+	res := &%[1]s{}
+	if err := rest.Apply(ctx, %[3]q+id, "PATCH", params, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Delete%[1]s deletes the %[2]s object with the given id
+func Delete%[1]s(ctx context.Context, id string) error {
+	_, err := rest.Do(ctx, %[3]q+id, "DELETE", nil)
+	return err
+}
+`, name, path, path+"/")
+}
+
+// unique returns name, or a variant of it if already used
+func (g *generator) unique(name string) string {
+	for g.names[name] {
+		name += "_"
+	}
+	g.names[name] = true
+	return name
+}
+
+// goName returns an exported Go identifier for name. API field names are
+// kept as is when possible so they stay recognizable.
+func goName(name string, camel bool) string {
+	if !camel && token.IsIdentifier(name) && token.IsExported(name) {
+		return name
+	}
+
+	var b strings.Builder
+	upper := true
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		b.WriteRune(r)
+	}
+	res := b.String()
+	if res == "" || !unicode.IsLetter([]rune(res)[0]) {
+		res = "F" + res
+	}
+	return res
+}
+
+// goType returns the Go type for a field
+func goType(f *rest.FieldInfo) string {
+	typ := strings.ToLower(strings.TrimSpace(f.Type))
+	size := ""
+	if pos := strings.IndexByte(typ, '('); pos != -1 {
+		size, _, _ = strings.Cut(typ[pos+1:], ")")
+		typ = typ[:pos]
+	}
+	typ, _, _ = strings.Cut(typ, " ") // drop "unsigned" and similar
+
+	var res string
+	switch typ {
+	case "tinyint":
+		if size == "1" {
+			res = "bool"
+		} else {
+			res = "int64"
+		}
+	case "int", "integer", "smallint", "mediumint", "bigint":
+		res = "int64"
+	case "float", "double", "decimal", "number":
+		res = "float64"
+	case "bool", "boolean":
+		res = "bool"
+	case "datetime", "timestamp", "date":
+		res = "rest.Time"
+	case "price":
+		res = "rest.Price"
+	case "char", "varchar", "text", "tinytext", "mediumtext", "longtext", "enum", "set", "string", "uuid":
+		res = "string"
+	default:
+		// json and unknown types
+		return "any"
+	}
+	if f.Null {
+		return "*" + res
+	}
+	return res
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/KarpelesLab/rest"
+	"github.com/KarpelesLab/rest/cli/internal/cliauth"
+)
+
+// generate Go types and call wrappers from API objects description
+
+type prefixList []string
+
+func (p *prefixList) String() string {
+	return strings.Join(*p, ",")
+}
+
+func (p *prefixList) Set(v string) error {
+	*p = append(*p, strings.Trim(v, "/"))
+	return nil
+}
+
+var (
+	pkgName  = flag.String("package", "api", "name of the generated package")
+	output   = flag.String("o", "", "output file (defaults to stdout)")
+	host     = flag.String("host", "", "API host (defaults to "+rest.Host+")")
+	prefixes prefixList
+)
+
+func init() {
+	flag.Var(&prefixes, "prefix", "API object to generate code for, for example User or Blog/Article (can be repeated)")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] -prefix <object>...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+}
+
+func main() {
+	flag.Parse()
+	if err := rest.ConfigFromEnv(); err != nil {
+		log.Printf("invalid environment configuration: %s", err)
+		os.Exit(2)
+	}
+	prefixes = append(prefixes, flag.Args()...)
+	if len(prefixes) == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *host != "" {
+		rest.Host = *host
+	}
+
+	if err := cliauth.ConfigureNetwork(); err != nil {
+		log.Printf("invalid network options: %s", err)
+		os.Exit(2)
+	}
+
+	ctx, err := cliauth.Use(context.Background())
+	if err != nil {
+		log.Printf("failed to load token: %s", err)
+		os.Exit(2)
+	}
+
+	g := newGenerator(*pkgName)
+	for _, prefix := range prefixes {
+		info, err := rest.Describe(ctx, prefix)
+		if err != nil {
+			log.Printf("failed to describe %s: %s", prefix, err)
+			os.Exit(1)
+		}
+		if len(info.Fields) == 0 {
+			log.Printf("no fields found for %s", prefix)
+			os.Exit(1)
+		}
+		g.object(prefix, info)
+	}
+	cliauth.SaveRenewedToken()
+	cliauth.SaveHAR()
+
+	src, err := g.source()
+	if err != nil {
+		log.Printf("failed to generate code: %s", err)
+		os.Exit(1)
+	}
+
+	if *output == "" {
+		os.Stdout.Write(src)
+		return
+	}
+	if err := os.WriteFile(*output, src, 0644); err != nil {
+		log.Printf("failed to write %s: %s", *output, err)
+		os.Exit(1)
+	}
+}
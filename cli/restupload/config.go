@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/KarpelesLab/rest"
+)
+
+// profile holds settings loaded from the configuration file
+type profile struct {
+	API     string
+	Host    string
+	Token   string
+	Headers map[string]string
+	Params  map[string]any
+}
+
+// configPath returns the default configuration file location
+func configPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "restupload", "config.toml")
+}
+
+// loadProfile loads the given profile from the configuration file. A
+// missing configuration file is not an error unless a profile was
+// explicitly requested.
+func loadProfile(fn, name string, explicit bool) (*profile, error) {
+	f, err := os.Open(fn)
+	if err != nil {
+		if os.IsNotExist(err) && !explicit {
+			return &profile{}, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	sections, err := parseConfig(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", fn, err)
+	}
+
+	sect, ok := sections[name]
+	if !ok {
+		if explicit {
+			return nil, fmt.Errorf("profile %s not found in %s", name, fn)
+		}
+		return &profile{}, nil
+	}
+
+	p := &profile{
+		Headers: make(map[string]string),
+		Params:  sections[name+".params"],
+	}
+	p.API, _ = sect["api"].(string)
+	p.Host, _ = sect["host"].(string)
+	p.Token, _ = sect["token"].(string)
+	for k, v := range sections[name+".headers"] {
+		p.Headers[k] = fmt.Sprintf("%v", v)
+	}
+	return p, nil
+}
+
+// parseConfig parses the subset of TOML used by the configuration file:
+// sections, comments and key/value pairs with string, number or boolean
+// values.
+func parseConfig(f io.Reader) (map[string]map[string]any, error) {
+	res := make(map[string]map[string]any)
+	section := ""
+
+	scanner := bufio.NewScanner(f)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo += 1
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		if line[0] == '[' {
+			end := strings.IndexByte(line, ']')
+			if end == -1 {
+				return nil, fmt.Errorf("line %d: unterminated section", lineNo)
+			}
+			section = strings.TrimSpace(line[1:end])
+			if res[section] == nil {
+				res[section] = make(map[string]any)
+			}
+			continue
+		}
+
+		k, v, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected key = value", lineNo)
+		}
+		k = strings.TrimSpace(k)
+		if uk, err := strconv.Unquote(k); err == nil {
+			k = uk
+		}
+		val, err := parseConfigValue(strings.TrimSpace(v))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+		if res[section] == nil {
+			res[section] = make(map[string]any)
+		}
+		res[section][k] = val
+	}
+	return res, scanner.Err()
+}
+
+func parseConfigValue(v string) (any, error) {
+	switch {
+	case strings.HasPrefix(v, `"`):
+		end := strings.LastIndexByte(v, '"')
+		if end == 0 {
+			return nil, fmt.Errorf("unterminated string %s", v)
+		}
+		return strconv.Unquote(v[:end+1])
+	case strings.HasPrefix(v, "'"):
+		end := strings.LastIndexByte(v, '\'')
+		if end == 0 {
+			return nil, fmt.Errorf("unterminated string %s", v)
+		}
+		return v[1:end], nil
+	}
+
+	// strip trailing comment
+	if pos := strings.IndexByte(v, '#'); pos != -1 {
+		v = strings.TrimSpace(v[:pos])
+	}
+	switch v {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	}
+	if i, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return i, nil
+	}
+	if f, err := strconv.ParseFloat(v, 64); err == nil {
+		return f, nil
+	}
+	return nil, fmt.Errorf("unsupported value %s", v)
+}
+
+// apply configures ctx and the rest package according to the profile
+func (p *profile) apply(ctx context.Context) context.Context {
+	if p.Host != "" {
+		rest.Host = p.Host
+	}
+	if p.Token != "" {
+		ctx = (&rest.Token{AccessToken: p.Token}).Use(ctx)
+	}
+	if len(p.Headers) > 0 {
+		ctx = rest.WithRequestMutator(ctx, func(req *http.Request) {
+			for k, val := range p.Headers {
+				req.Header.Set(k, val)
+			}
+		})
+	}
+	return ctx
+}
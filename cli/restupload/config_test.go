@@ -0,0 +1,64 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		out  map[string]map[string]any
+	}{
+		{"empty", "", map[string]map[string]any{}},
+		{"top level", "api = \"Cloud/Aws/Bucket:upload\"\n", map[string]map[string]any{"": {"api": "Cloud/Aws/Bucket:upload"}}},
+		{
+			"sections",
+			"[default]\nhost = \"www.example.com\"\n\n[default.headers]\nX-Test = 'raw \\n value'\n[default.params]\nsize = 42\nratio = 0.5\npublic = true\nhidden = false\n",
+			map[string]map[string]any{
+				"default":         {"host": "www.example.com"},
+				"default.headers": {"X-Test": `raw \n value`},
+				"default.params":  {"size": int64(42), "ratio": 0.5, "public": true, "hidden": false},
+			},
+		},
+		{
+			"comments",
+			"# comment\n  [ prod ]  \ntoken = \"abc#def\" # trailing\ncount = 3 # trailing\n",
+			map[string]map[string]any{"prod": {"token": "abc#def", "count": int64(3)}},
+		},
+		{"quoted key", "[p]\n\"key with spaces\" = \"tab\\there\"\n", map[string]map[string]any{"p": {"key with spaces": "tab\there"}}},
+		{"empty section", "[p]\n", map[string]map[string]any{"p": {}}},
+	}
+	for _, tt := range tests {
+		res, err := parseConfig(strings.NewReader(tt.in))
+		if err != nil {
+			t.Errorf("%s: unexpected error %s", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(res, tt.out) {
+			t.Errorf("%s: got %v, expected %v", tt.name, res, tt.out)
+		}
+	}
+}
+
+func TestParseConfigErrors(t *testing.T) {
+	tests := []struct {
+		in  string
+		err string
+	}{
+		{"[default\n", "line 1: unterminated section"},
+		{"[p]\nhost\n", "line 2: expected key = value"},
+		{"a = \"unterminated\n", "line 1: unterminated string"},
+		{"a = 'unterminated\n", "line 1: unterminated string"},
+		{"a = \"bad \\q escape\"\n", "line 1: invalid syntax"},
+		{"\n\na = bare\n", "line 3: unsupported value bare"},
+	}
+	for _, tt := range tests {
+		_, err := parseConfig(strings.NewReader(tt.in))
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("parseConfig(%q): expected error %q, got %v", tt.in, tt.err, err)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/KarpelesLab/rest"
+	"github.com/KarpelesLab/rest/cli/internal/cliauth"
+)
+
+const deviceGrantType = "urn:ietf:params:oauth:grant-type:device_code"
+
+var deviceEndpoint = flag.String("device-endpoint", "OAuth2:device", "API endpoint starting the OAuth2 device flow, for login")
+
+// deviceAuth is the response to a device authorization request (RFC 8628)
+type deviceAuth struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int    `json:"expires_in"`
+	Interval                int    `json:"interval"`
+}
+
+// runLogin performs the OAuth2 device flow and stores the obtained token
+func runLogin(ctx context.Context) error {
+	if *cliauth.ClientID == "" {
+		return errors.New("parameter -client-id is required for login")
+	}
+
+	var da deviceAuth
+	err := rest.Apply(ctx, *deviceEndpoint, "POST", rest.Param{"client_id": *cliauth.ClientID}, &da)
+	if err != nil {
+		return fmt.Errorf("failed to start login: %w", err)
+	}
+
+	if da.VerificationURIComplete != "" {
+		fmt.Fprintf(os.Stderr, "To login, open the following URL in a browser:\n\n    %s\n\n", da.VerificationURIComplete)
+	} else {
+		fmt.Fprintf(os.Stderr, "To login, open the following URL in a browser:\n\n    %s\n\nand enter the code: %s\n\n", da.VerificationURI, da.UserCode)
+	}
+
+	interval := time.Duration(da.Interval) * time.Second
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	expires := time.Duration(da.ExpiresIn) * time.Second
+	if expires <= 0 {
+		expires = 10 * time.Minute
+	}
+	deadline := time.Now().Add(expires)
+
+	for time.Now().Before(deadline) {
+		time.Sleep(interval)
+
+		tok := &rest.Token{}
+		req := rest.Param{
+			"grant_type":  deviceGrantType,
+			"device_code": da.DeviceCode,
+			"client_id":   *cliauth.ClientID,
+		}
+		err := rest.Apply(ctx, rest.OAuth2Token.String(), "POST", req, tok)
+		if err == nil {
+			tok.ClientID = *cliauth.ClientID
+			if err := cliauth.SaveStoredToken(tok); err != nil {
+				return fmt.Errorf("failed to save token: %w", err)
+			}
+			fmt.Fprintf(os.Stderr, "Login successful.\n")
+			return nil
+		}
+
+		switch oauthError(err) {
+		case "authorization_pending":
+			// keep waiting
+		case "slow_down":
+			interval += 5 * time.Second
+		default:
+			return fmt.Errorf("login failed: %w", err)
+		}
+	}
+	return errors.New("login failed: code expired")
+}
+
+// oauthError returns the OAuth2 error code carried by err, for the codes
+// the device flow cares about
+func oauthError(err error) string {
+	var e *rest.Error
+	if !errors.As(err, &e) {
+		return ""
+	}
+	for _, s := range []string{e.Response.Token, e.Response.Extra, e.Response.Error} {
+		switch s {
+		case "authorization_pending", "slow_down":
+			return s
+		}
+	}
+	return ""
+}
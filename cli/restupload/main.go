@@ -4,31 +4,114 @@ import (
 	"context"
 	"encoding/json"
 	"flag"
+	"fmt"
 	"log"
 	"mime"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/KarpelesLab/rest"
+	"github.com/KarpelesLab/rest/cli/internal/cliauth"
 	"github.com/KarpelesLab/webutil"
 )
 
 // upload given file(s) to given API
 
 var (
-	api    = flag.String("api", "", "endpoint to direct upload to")
-	params = flag.String("params", "", "params to pass to the API")
+	api      = flag.String("api", "", "endpoint to direct upload to")
+	params   = flag.String("params", "", "params to pass to the API")
+	resume   = flag.Bool("resume", false, "keep track of uploads so interrupted uploads can be resumed")
+	stateDir = flag.String("state-dir", "", "directory where resume state is stored (defaults to the user cache directory)")
+
+	config      = flag.String("config", configPath(), "configuration file")
+	profileName = flag.String("profile", "", "profile to use from the configuration file (defaults to \"default\")")
+
+	outputFmt   = flag.String("output", "text", "output format: text or json (one object per uploaded file)")
+	dryRun      = flag.Bool("dry-run", false, "show what would be uploaded without uploading anything")
+	verify      = flag.Bool("verify", false, "compare the hash of uploaded files with the one returned by the server")
+	progressFmt = flag.String("progress", "", "progress reporting on stderr: text or json")
+	journal     = flag.String("journal", "", "append a json record of each completed upload (path, size, hashes, blob) to this file")
+
+	recursive = flag.Bool("recursive", false, "upload directories recursively, passing each file's relative path as \"path\" param")
+	includes  globList
+	excludes  globList
 )
 
+func init() {
+	flag.Var(&includes, "include", "only upload files matching this pattern in recursive mode (can be repeated)")
+	flag.Var(&excludes, "exclude", "do not upload files or directories matching this pattern in recursive mode (can be repeated)")
+}
+
 func main() {
 	flag.Parse()
+	if err := rest.ConfigFromEnv(); err != nil {
+		log.Printf("invalid environment configuration: %s", err)
+		os.Exit(1)
+	}
+
+	name := *profileName
+	if name == "" {
+		name = "default"
+	}
+	prof, err := loadProfile(*config, name, *profileName != "")
+	if err != nil {
+		log.Printf("failed to load configuration: %s", err)
+		os.Exit(1)
+	}
+	ctx := prof.apply(context.Background())
+	if err := cliauth.ConfigureNetwork(); err != nil {
+		log.Printf("invalid network options: %s", err)
+		os.Exit(1)
+	}
+
+	if flag.Arg(0) == "login" {
+		if err := runLogin(ctx); err != nil {
+			log.Printf("%s", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *api == "" {
+		*api = prof.API
+	}
 	if *api == "" {
 		log.Printf("parameter -api is required")
 		flag.Usage()
 		os.Exit(1)
 	}
 
+	tok, err := cliauth.LoadToken()
+	if err != nil {
+		log.Printf("failed to load token: %s", err)
+		os.Exit(1)
+	}
+	if tok == nil && prof.Token == "" {
+		// use token from login, if any
+		tok = cliauth.LoadStoredToken()
+	}
+	if tok != nil {
+		ctx = tok.Use(ctx)
+	}
+	if *journal != "" && !*dryRun {
+		f, err := os.OpenFile(*journal, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+		if err != nil {
+			log.Printf("failed to open journal: %s", err)
+			os.Exit(1)
+		}
+		// writes are not buffered, the file is closed on exit
+		ctx = rest.WithUploadJournal(ctx, rest.NewJSONJournal(f))
+	}
+	if !*dryRun {
+		// connect while we prepare the upload
+		go rest.Preconnect(ctx)
+	}
+
 	var p rest.Param = make(map[string]any)
+	for k, v := range prof.Params {
+		p[k] = v
+	}
 
 	if param := *params; param != "" {
 		if param[0] == '{' {
@@ -36,29 +119,178 @@ func main() {
 			json.Unmarshal([]byte(param), &p)
 		} else {
 			// url encoded
-			p = webutil.ParsePhpQuery(param)
+			for k, v := range webutil.ParsePhpQuery(param) {
+				p[k] = v
+			}
 		}
 	}
 
-	args := flag.Args()
+	code := run(ctx, flag.Args(), p)
+	cliauth.SaveRenewedToken()
+	cliauth.SaveHAR()
+	os.Exit(code)
+}
+
+// run uploads the given files and returns the exit code
+func run(ctx context.Context, args []string, p rest.Param) int {
+	if *dryRun {
+		return runDry(args, p)
+	}
+	if *recursive {
+		return uploadRecursive(ctx, args, p)
+	}
 
 	for _, fn := range args {
 		log.Printf("Uploading file %s", fn)
-		err := doUpload(fn, p)
+		_, err := doUpload(ctx, fn, "", p)
 		if err != nil {
 			log.Printf("failed to upload: %s", err)
-			os.Exit(1)
+			return exitCode(err)
+		}
+	}
+	return 0
+}
+
+// uploadRecursive uploads all given files and directories, and returns the
+// exit code
+func uploadRecursive(ctx context.Context, args []string, p rest.Param) int {
+	entries, err := listEntries(args)
+	if err != nil {
+		log.Printf("%s", err)
+		return 1
+	}
+
+	code := 0
+	for _, e := range entries {
+		log.Printf("Uploading file %s", e.fn)
+		res, err := doUpload(ctx, e.fn, e.rel, p)
+		if err != nil {
+			log.Printf("failed to upload: %s", err)
+			e.err = err
+			// report temporary failure only if all failures are temporary
+			if c := exitCode(err); code != exitPermanent {
+				code = c
+			}
+			continue
 		}
+		e.blob, _ = res.GetString("Blob__")
+	}
+
+	if *outputFmt != "json" {
+		printManifest(entries)
 	}
+	return code
 }
 
-func doUpload(fn string, p rest.Param) error {
+// listEntries returns the files to upload for the given arguments,
+// expanding directories in recursive mode
+func listEntries(args []string) ([]*uploadEntry, error) {
+	var entries []*uploadEntry
+	for _, fn := range args {
+		st, err := os.Stat(fn)
+		if err != nil {
+			return nil, fmt.Errorf("failed to access %s: %w", fn, err)
+		}
+		if !st.IsDir() || !*recursive {
+			e := &uploadEntry{fn: fn, size: st.Size()}
+			if *recursive {
+				e.rel = filepath.Base(fn)
+			}
+			entries = append(entries, e)
+			continue
+		}
+		files, err := collectFiles(fn)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %s: %w", fn, err)
+		}
+		entries = append(entries, files...)
+	}
+	return entries, nil
+}
+
+// runDry shows what would be uploaded and returns the exit code
+func runDry(args []string, p rest.Param) int {
+	entries, err := listEntries(args)
+	if err != nil {
+		log.Printf("%s", err)
+		return 1
+	}
+
+	var total int64
+	for _, e := range entries {
+		st, err := os.Stat(e.fn)
+		if err != nil {
+			log.Printf("failed to access %s: %s", e.fn, err)
+			return 1
+		}
+		pCopy, _ := fileParams(e.fn, e.rel, p, st)
+		buf, _ := json.Marshal(pCopy)
+		fmt.Printf("%s (%d bytes) => %s %s\n", e.fn, st.Size(), *api, buf)
+		total += st.Size()
+	}
+	fmt.Printf("%d files, %d bytes total (dry run, nothing uploaded)\n", len(entries), total)
+	return 0
+}
+
+// doUpload uploads fn, passing rel as path parameter if not empty, and
+// outputs the result
+func doUpload(ctx context.Context, fn, rel string, p rest.Param) (*rest.Response, error) {
+	start := time.Now()
+	res, stats, err := uploadFile(withProgress(rest.WithUploadPath(ctx, fn), fn), fn, rel, p)
+	if err != nil {
+		return nil, err
+	}
+	d := time.Since(start)
+	if *outputFmt != "json" {
+		log.Printf("Uploaded %s: %d bytes in %s (%.1f KB/s, %s, %d parts, %d retries)", fn, stats.Bytes, d.Round(time.Millisecond), stats.Throughput()/1024, stats.Method, stats.Parts, stats.Retries)
+	}
+
+	var sum string
+	var size int64
+	if *verify || *outputFmt == "json" {
+		sum, size, err = hashFile(fn)
+		if err != nil {
+			return res, err
+		}
+	}
+	if *verify {
+		if err := verifyHash(res, sum); err != nil {
+			return res, err
+		}
+	}
+	return res, printResult(fn, res, stats, d, sum, size)
+}
+
+func uploadFile(ctx context.Context, fn, rel string, p rest.Param) (*rest.Response, *rest.UploadResult, error) {
 	f, err := os.Open(fn)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 	defer f.Close()
 
+	st, err := f.Stat()
+	if err != nil {
+		return nil, nil, err
+	}
+	pCopy, mimeType := fileParams(fn, rel, p, st)
+
+	if *resume {
+		return resumableUpload(ctx, f, fn, pCopy, mimeType)
+	}
+
+	up, err := prepareUpload(ctx, pCopy)
+	if err != nil {
+		return nil, nil, err
+	}
+	res, err := up.Do(ctx, f, mimeType, st.Size())
+	if err != nil {
+		return nil, nil, err
+	}
+	return res, up.Result(), nil
+}
+
+// fileParams returns the parameters for the upload of fn, and its mime type
+func fileParams(fn, rel string, p rest.Param, st os.FileInfo) (rest.Param, string) {
 	mimeType := mime.TypeByExtension(filepath.Ext(fn))
 
 	var pCopy rest.Param = make(map[string]any)
@@ -66,12 +298,12 @@ func doUpload(fn string, p rest.Param) error {
 		pCopy[k] = v
 	}
 	pCopy["filename"] = filepath.Base(fn)
-	pCopy["type"] = mimeType
-	if st, err := f.Stat(); err == nil {
-		pCopy["size"] = st.Size()
-		pCopy["lastModified"] = st.ModTime().Unix()
+	if rel != "" {
+		pCopy["path"] = rel
 	}
+	pCopy["type"] = mimeType
+	pCopy["size"] = st.Size()
+	pCopy["lastModified"] = st.ModTime().Unix()
 
-	_, err = rest.Upload(context.Background(), *api, "POST", pCopy, f, mimeType)
-	return err
+	return pCopy, mimeType
 }
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/KarpelesLab/rest"
+)
+
+// uploadResult is printed for each uploaded file with -output json
+type uploadResult struct {
+	File       string          `json:"file"`
+	Blob       string          `json:"blob,omitempty"`
+	Size       int64           `json:"size"`
+	SHA256     string          `json:"sha256"`
+	Duration   float64         `json:"duration"`   // in seconds
+	Method     string          `json:"method"`     // put, part or aws
+	Parts      int             `json:"parts"`      // parts uploaded
+	Retries    int             `json:"retries"`    // requests retried
+	Throughput float64         `json:"throughput"` // in bytes per second
+	Data       json.RawMessage `json:"data,omitempty"`
+}
+
+// progressEvent is printed on stderr as uploads progress with -progress json
+type progressEvent struct {
+	Event string `json:"event"`
+	File  string `json:"file"`
+	Sent  int64  `json:"sent"`
+	Total int64  `json:"total"` // -1 if unknown
+}
+
+// withProgress returns a context reporting upload progress of fn according
+// to the -progress flag
+func withProgress(ctx context.Context, fn string) context.Context {
+	var cb rest.UploadProgressFunc
+
+	switch *progressFmt {
+	case "json":
+		enc := json.NewEncoder(os.Stderr)
+		cb = func(sent, total int64) {
+			enc.Encode(&progressEvent{Event: "progress", File: fn, Sent: sent, Total: total})
+		}
+	case "text":
+		cb = func(sent, total int64) {
+			if total > 0 {
+				log.Printf("%s: %d/%d bytes (%.1f%%)", fn, sent, total, float64(sent)*100/float64(total))
+			} else {
+				log.Printf("%s: %d bytes", fn, sent)
+			}
+		}
+	default:
+		return ctx
+	}
+	return rest.WithProgress(ctx, cb)
+}
+
+// hashFile returns the hex encoded sha256 hash of fn and its size
+func hashFile(fn string) (string, int64, error) {
+	f, err := os.Open(fn)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to hash %s: %w", fn, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), size, nil
+}
+
+// verifyHash checks that the hash returned by the server in the completion
+// response matches sum
+func verifyHash(res *rest.Response, sum string) error {
+	for _, k := range []string{"SHA256", "Sha256", "Hash"} {
+		remote, err := res.GetString(k)
+		if err != nil || remote == "" {
+			continue
+		}
+		if !strings.EqualFold(remote, sum) {
+			return fmt.Errorf("hash mismatch: local %s, server %s", sum, remote)
+		}
+		return nil
+	}
+	return errors.New("server response does not include a hash to verify")
+}
+
+// printResult outputs the result of an upload according to the -output flag
+func printResult(fn string, res *rest.Response, stats *rest.UploadResult, d time.Duration, sum string, size int64) error {
+	if *outputFmt != "json" {
+		return nil
+	}
+
+	r := &uploadResult{
+		File:       fn,
+		Size:       size,
+		SHA256:     sum,
+		Duration:   d.Seconds(),
+		Method:     string(stats.Method),
+		Parts:      stats.Parts,
+		Retries:    stats.Retries,
+		Throughput: stats.Throughput(),
+		Data:       json.RawMessage(res.Data),
+	}
+	r.Blob, _ = res.GetString("Blob__")
+
+	return json.NewEncoder(os.Stdout).Encode(r)
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+)
+
+// globList is a flag that can be specified multiple times
+type globList []string
+
+func (g *globList) String() string {
+	return strings.Join(*g, ",")
+}
+
+func (g *globList) Set(v string) error {
+	if _, err := path.Match(v, ""); err != nil {
+		return fmt.Errorf("invalid pattern %q: %w", v, err)
+	}
+	*g = append(*g, v)
+	return nil
+}
+
+// matches returns true if either the full relative path or the base name of
+// rel matches one of the patterns
+func (g globList) matches(rel string) bool {
+	for _, pattern := range g {
+		if ok, _ := path.Match(pattern, rel); ok {
+			return true
+		}
+		if ok, _ := path.Match(pattern, path.Base(rel)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// uploadEntry is a file to upload, and the result of its upload
+type uploadEntry struct {
+	fn   string // path on the local filesystem
+	rel  string // path relative to the uploaded directory, slash separated
+	size int64
+	blob string
+	err  error
+}
+
+// collectFiles returns the files found under dir that should be uploaded
+func collectFiles(dir string) ([]*uploadEntry, error) {
+	var res []*uploadEntry
+
+	err := filepath.WalkDir(dir, func(fn string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, fn)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		if d.IsDir() {
+			if rel != "." && excludes.matches(rel) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		if len(includes) > 0 && !includes.matches(rel) {
+			return nil
+		}
+		if excludes.matches(rel) {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		res = append(res, &uploadEntry{fn: fn, rel: rel, size: info.Size()})
+		return nil
+	})
+	return res, err
+}
+
+// printManifest prints a summary of uploaded files
+func printManifest(entries []*uploadEntry) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	defer w.Flush()
+
+	var total int64
+	var failed int
+	fmt.Fprintf(w, "PATH\tSIZE\tRESULT\n")
+	for _, e := range entries {
+		if e.err != nil {
+			failed += 1
+			fmt.Fprintf(w, "%s\t%d\terror: %s\n", e.rel, e.size, e.err)
+			continue
+		}
+		total += e.size
+		fmt.Fprintf(w, "%s\t%d\t%s\n", e.rel, e.size, e.blob)
+	}
+	fmt.Fprintf(w, "\n%d files uploaded (%d bytes), %d failed\n", len(entries)-failed, total, failed)
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/KarpelesLab/rest"
+)
+
+// resumeStore returns the store keeping track of resumable uploads
+func resumeStore() (*rest.FileUploadStore, error) {
+	dir := *stateDir
+	if dir == "" {
+		cache, err := os.UserCacheDir()
+		if err != nil {
+			return nil, err
+		}
+		dir = filepath.Join(cache, "restupload")
+	}
+	return rest.NewFileUploadStore(dir)
+}
+
+// resumeKey returns the key under which the upload of the given file is
+// tracked. It depends on the file's path, size and modification time so that
+// a modified file will not resume a previous upload.
+func resumeKey(fn string, st os.FileInfo) (string, error) {
+	abs, err := filepath.Abs(fn)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%s\x00%d\x00%d", *api, abs, st.Size(), st.ModTime().UnixNano())))
+	return hex.EncodeToString(h[:16]), nil
+}
+
+// resumableUpload uploads f, continuing a previous upload if one was
+// interrupted, and keeping track of progress in the state directory
+func resumableUpload(ctx context.Context, f *os.File, fn string, p rest.Param, mimeType string) (*rest.Response, *rest.UploadResult, error) {
+	st, err := f.Stat()
+	if err != nil {
+		return nil, nil, err
+	}
+	store, err := resumeStore()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open resume state: %w", err)
+	}
+	key, err := resumeKey(fn, st)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to locate resume state: %w", err)
+	}
+
+	var up *rest.UploadInfo
+	prepare := func(ctx context.Context, state *rest.UploadState) (*rest.UploadInfo, error) {
+		var err error
+		if state != nil {
+			log.Printf("Resuming upload of %s (%d parts already sent)", fn, len(state.Parts))
+			up, err = rest.ResumeUpload(state)
+			if err == nil {
+				configureUpload(up)
+			}
+		} else {
+			up, err = prepareUpload(ctx, p)
+		}
+		return up, err
+	}
+	res, err := rest.StoredUpload(ctx, store, key, prepare, f, mimeType, st.Size())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// upload complete, state not needed anymore
+	store.Delete(key)
+	return res, up.Result(), nil
+}
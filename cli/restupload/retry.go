@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+
+	"github.com/KarpelesLab/rest"
+)
+
+const (
+	exitPermanent = 1  // upload failed and retrying will not help
+	exitTemporary = 75 // EX_TEMPFAIL, upload failed but can be retried later
+)
+
+var (
+	retries      = flag.Int("retries", 3, "number of times a failed upload part is retried")
+	retryDelay   = flag.Duration("retry-delay", rest.DefaultUploadRetryDelay, "delay before retrying a failed part, doubled on each retry")
+	stallTimeout = flag.Duration("stall-timeout", 0, "abort and retry requests that could not send any data for this long (0 to disable)")
+)
+
+// prepareUpload performs the initial upload query and returns the resulting
+// UploadInfo configured according to the command line flags
+func prepareUpload(ctx context.Context, p rest.Param) (*rest.UploadInfo, error) {
+	var upinfo map[string]any
+	if err := rest.Apply(ctx, *api, "POST", p, &upinfo); err != nil {
+		return nil, fmt.Errorf("initial upload query failed: %w", err)
+	}
+	up, err := rest.PrepareUpload(upinfo)
+	if err != nil {
+		return nil, fmt.Errorf("upload prepare failed: %w", err)
+	}
+	configureUpload(up)
+	return up, nil
+}
+
+func configureUpload(up *rest.UploadInfo) {
+	up.Retries = *retries
+	up.RetryDelay = *retryDelay
+	up.StallTimeout = *stallTimeout
+}
+
+// exitCode returns the exit code to use after err, distinguishing errors
+// that may go away when retrying from permanent ones
+func exitCode(err error) int {
+	if isTemporary(err) {
+		return exitTemporary
+	}
+	return exitPermanent
+}
+
+func isTemporary(err error) bool {
+	if errors.Is(err, rest.ErrUploadStalled) || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	var httpErr *rest.HttpError
+	if errors.As(err, &httpErr) {
+		return httpErr.IsTemporary()
+	}
+	var restErr *rest.Error
+	if errors.As(err, &restErr) && restErr.Response != nil {
+		return restErr.IsTemporary()
+	}
+	return errors.Is(err, rest.ErrRateLimited)
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/KarpelesLab/rest"
+)
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		code int
+	}{
+		{"stalled", rest.ErrUploadStalled, exitTemporary},
+		{"deadline", fmt.Errorf("part 3: %w", context.DeadlineExceeded), exitTemporary},
+		{"truncated", io.ErrUnexpectedEOF, exitTemporary},
+		{"network", &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}, exitTemporary},
+		{"http 503", &rest.HttpError{Code: 503}, exitTemporary},
+		{"http 429", fmt.Errorf("upload failed: %w", &rest.HttpError{Code: 429}), exitTemporary},
+		{"http 403", &rest.HttpError{Code: 403}, exitPermanent},
+		{"api 502", &rest.Error{Response: &rest.Response{Result: "error", Code: 502}}, exitTemporary},
+		{"api 404", &rest.Error{Response: &rest.Response{Result: "error", Code: 404}}, exitPermanent},
+		{"rate limited", rest.ErrRateLimited, exitTemporary},
+		{"canceled", context.Canceled, exitPermanent},
+		{"other", errors.New("file not found"), exitPermanent},
+	}
+	for _, tt := range tests {
+		if code := exitCode(tt.err); code != tt.code {
+			t.Errorf("%s: exit code %d, expected %d", tt.name, code, tt.code)
+		}
+	}
+}
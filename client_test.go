@@ -0,0 +1,194 @@
+package rest
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClient(t *testing.T) {
+	newServer := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"result":"success","data":"` + name + ` ` + r.Header.Get("Authorization") + `"}`))
+		}))
+	}
+	srv1, srv2 := newServer("one"), newServer("two")
+	defer srv1.Close()
+	defer srv2.Close()
+
+	c1, err := NewClient(srv1.URL)
+	if err != nil {
+		t.Fatalf("NewClient failed: %s", err)
+	}
+	c1.Token = &Token{AccessToken: "t1"}
+	c2, _ := NewClient(srv2.URL)
+	c2.HTTPClient = srv2.Client()
+
+	ctx := context.Background()
+	var s string
+	if err := c1.Apply(ctx, "Test", "GET", nil, &s); err != nil || s != "one Bearer t1" {
+		t.Errorf("unexpected response from first client %q: %v", s, err)
+	}
+	if s, err := As[string](c2.Use(ctx), "Test", "GET", nil); err != nil || s != "two " {
+		t.Errorf("unexpected response from second client %q: %v", s, err)
+	}
+
+	// a token in the context takes precedence over the client token
+	res, err := c1.Do((&Token{AccessToken: "ctx"}).Use(ctx), "Test", "GET", nil)
+	if err != nil {
+		t.Fatalf("Do failed: %s", err)
+	}
+	if v, _ := res.Value(); v != "one Bearer ctx" {
+		t.Errorf("unexpected response %v", v)
+	}
+
+	var res2 string
+	if res, err := c2.ApplyResponse(ctx, "Test", "GET", nil, &res2); err != nil || res2 != "two " || res.Result != "success" {
+		t.Errorf("unexpected ApplyResponse result %q: %v", res2, err)
+	}
+
+	if _, err := NewClient("/relative"); err == nil {
+		t.Errorf("expected an error for a relative url")
+	}
+}
+
+func TestClientSettings(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Write([]byte(`{"result":"success","data":null}`))
+	}))
+	defer srv.Close()
+
+	// each client has its own cache
+	c1, _ := NewClient(srv.URL)
+	c1.Cache = NewResponseCache(time.Minute)
+	c2, _ := NewClient(srv.URL)
+	for i := 0; i < 2; i++ {
+		for _, c := range []*Client{c1, c2} {
+			if _, err := c.Do(context.Background(), "Test", "GET", nil); err != nil {
+				t.Fatalf("Do failed: %s", err)
+			}
+		}
+	}
+	if n := hits.Load(); n != 3 {
+		t.Errorf("expected 3 requests with one cached client, got %d", n)
+	}
+
+	// a full limiter only blocks calls of its client
+	c1.Limiter = NewConcurrencyLimiter(1)
+	c1.Limiter.Acquire(context.Background())
+	defer c1.Limiter.Release()
+	if _, err := c2.Do(context.Background(), "Test", "GET", nil); err != nil {
+		t.Errorf("call with another client failed: %s", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := c1.Do(ctx, "Test:other", "GET", nil); err == nil {
+		t.Errorf("expected call to wait for the limiter")
+	}
+}
+
+func TestSetTLSOptions(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"result":"success","data":{}}`))
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+	ctx := context.WithValue(context.Background(), BackendURL, u)
+
+	defer func(rest, upload *tls.Config) {
+		RestHttpTransport.TLSClientConfig, UploadHttpTransport.TLSClientConfig = rest, upload
+		RestHttpTransport.CloseIdleConnections()
+		UploadHttpTransport.CloseIdleConnections()
+	}(RestHttpTransport.TLSClientConfig, UploadHttpTransport.TLSClientConfig)
+
+	SetTLSOptions(&TLSOptions{MinVersion: tls.VersionTLS12})
+	var authErr x509.UnknownAuthorityError
+	if _, err := Do(ctx, "Test", "GET", nil); !errors.As(err, &authErr) {
+		t.Errorf("expected handshake to fail without the test root CA, got %v", err)
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(srv.Certificate())
+	SetTLSOptions(&TLSOptions{MinVersion: tls.VersionTLS12, RootCAs: pool})
+	if _, err := Do(ctx, "Test", "GET", nil); err != nil {
+		t.Errorf("request with the test root CA failed: %s", err)
+	}
+	res, err := UploadHttpClient.Get(srv.URL)
+	if err != nil {
+		t.Errorf("upload transport does not use the test root CA: %s", err)
+	} else {
+		res.Body.Close()
+	}
+
+	// the test certificate is valid for example.com
+	SetTLSOptions(&TLSOptions{RootCAs: pool, ServerName: "example.com"})
+	if _, err := Do(ctx, "Test", "GET", nil); err != nil {
+		t.Errorf("request with a valid server name failed: %s", err)
+	}
+	SetTLSOptions(&TLSOptions{RootCAs: pool, ServerName: "wrong.example.net"})
+	if _, err := Do(ctx, "Test", "GET", nil); err == nil {
+		t.Errorf("expected handshake to fail with a wrong server name")
+	}
+}
+
+func TestSetProxy(t *testing.T) {
+	var lk sync.Mutex
+	var hosts []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lk.Lock()
+		hosts = append(hosts, r.Host+r.URL.Path)
+		lk.Unlock()
+		w.Write([]byte(`{"result":"success","data":{}}`))
+	}))
+	defer proxy.Close()
+
+	defer func(rest, upload func(*http.Request) (*url.URL, error)) {
+		RestHttpTransport.Proxy, UploadHttpTransport.Proxy = rest, upload
+		RestHttpTransport.CloseIdleConnections()
+		UploadHttpTransport.CloseIdleConnections()
+	}(RestHttpTransport.Proxy, UploadHttpTransport.Proxy)
+
+	if err := SetProxy(&url.URL{Scheme: "ftp", Host: "proxy.example.com"}); err == nil {
+		t.Errorf("expected an error for an unsupported proxy scheme")
+	}
+
+	pu, _ := url.Parse(proxy.URL)
+	if err := SetProxy(pu); err != nil {
+		t.Fatalf("SetProxy failed: %s", err)
+	}
+	// the backend does not exist, requests only succeed through the proxy
+	ctx := context.WithValue(context.Background(), BackendURL, &url.URL{Scheme: "http", Host: "api.invalid"})
+	if _, err := Do(ctx, "Test", "GET", nil); err != nil {
+		t.Errorf("request through the proxy failed: %s", err)
+	}
+	res, err := UploadHttpClient.Get("http://storage.invalid/file")
+	if err != nil {
+		t.Errorf("upload request through the proxy failed: %s", err)
+	} else {
+		res.Body.Close()
+	}
+
+	lk.Lock()
+	if len(hosts) != 2 || hosts[0] != "api.invalid/_special/rest/Test" || hosts[1] != "storage.invalid/file" {
+		t.Errorf("unexpected requests received by the proxy %v", hosts)
+	}
+	lk.Unlock()
+
+	// disabling the proxy sends requests directly again
+	if err := SetProxy(nil); err != nil {
+		t.Fatalf("SetProxy failed: %s", err)
+	}
+	if _, err := Do(ctx, "Test", "GET", nil); err == nil {
+		t.Errorf("expected request to a missing backend to fail without the proxy")
+	}
+}
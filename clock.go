@@ -0,0 +1,180 @@
+package rest
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock is the source of time used for backoffs, timeouts, expirations and
+// signature timestamps. Tests can replace DefaultClock with a FakeClock to
+// fast-forward through retries and expirations.
+type Clock interface {
+	Now() time.Time
+	NewTimer(d time.Duration) Timer
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Timer is a timer created by a Clock
+type Timer interface {
+	// C returns the channel on which the time is sent, nil for timers
+	// created with AfterFunc
+	C() <-chan time.Time
+	Stop() bool
+	Reset(d time.Duration) bool
+}
+
+// DefaultClock is the Clock in use, the system clock by default
+var DefaultClock Clock = realClock{}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) NewTimer(d time.Duration) Timer {
+	return realTimer{time.NewTimer(d)}
+}
+
+func (realClock) AfterFunc(d time.Duration, f func()) Timer {
+	return realTimer{time.AfterFunc(d, f)}
+}
+
+type realTimer struct {
+	*time.Timer
+}
+
+func (t realTimer) C() <-chan time.Time {
+	return t.Timer.C
+}
+
+// now returns the current time according to DefaultClock
+func now() time.Time {
+	return DefaultClock.Now()
+}
+
+// sleep waits for d, or until ctx is done
+func sleep(ctx context.Context, d time.Duration) error {
+	t := DefaultClock.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C():
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// FakeClock is a Clock whose time only changes when Advance is called
+type FakeClock struct {
+	lk     sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+// NewFakeClock returns a FakeClock set at t
+func NewFakeClock(t time.Time) *FakeClock {
+	return &FakeClock{now: t}
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.lk.Lock()
+	defer c.lk.Unlock()
+	return c.now
+}
+
+func (c *FakeClock) NewTimer(d time.Duration) Timer {
+	t := &fakeTimer{clock: c, c: make(chan time.Time, 1)}
+	t.Reset(d)
+	return t
+}
+
+func (c *FakeClock) AfterFunc(d time.Duration, f func()) Timer {
+	t := &fakeTimer{clock: c, f: f}
+	t.Reset(d)
+	return t
+}
+
+// Advance moves the clock forward by d, firing the timers expiring in the
+// meantime
+func (c *FakeClock) Advance(d time.Duration) {
+	c.lk.Lock()
+	c.now = c.now.Add(d)
+	now := c.now
+	var fire []*fakeTimer
+	keep := c.timers[:0]
+	for _, t := range c.timers {
+		if !t.when.After(now) {
+			fire = append(fire, t)
+		} else {
+			keep = append(keep, t)
+		}
+	}
+	c.timers = keep
+	c.lk.Unlock()
+
+	sort.Slice(fire, func(i, j int) bool { return fire[i].when.Before(fire[j].when) })
+	for _, t := range fire {
+		t.fire(now)
+	}
+}
+
+// Timers returns the number of pending timers, allowing tests to wait for
+// code to be waiting on the clock before advancing it
+func (c *FakeClock) Timers() int {
+	c.lk.Lock()
+	defer c.lk.Unlock()
+	return len(c.timers)
+}
+
+type fakeTimer struct {
+	clock *FakeClock
+	when  time.Time
+	c     chan time.Time
+	f     func()
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTimer) fire(now time.Time) {
+	if t.f != nil {
+		go t.f()
+		return
+	}
+	select {
+	case t.c <- now:
+	default:
+	}
+}
+
+func (t *fakeTimer) Stop() bool {
+	c := t.clock
+	c.lk.Lock()
+	defer c.lk.Unlock()
+	for i, v := range c.timers {
+		if v == t {
+			c.timers = append(c.timers[:i], c.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	active := t.Stop()
+	c := t.clock
+	c.lk.Lock()
+	t.when = c.now.Add(d)
+	if d <= 0 {
+		c.lk.Unlock()
+		t.fire(t.when)
+		return active
+	}
+	c.timers = append(c.timers, t)
+	c.lk.Unlock()
+	return active
+}
@@ -0,0 +1,109 @@
+package rest
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/KarpelesLab/pjson"
+)
+
+// CoalesceGET causes identical GET requests (same backend, path, parameters,
+// token, session, version and locale headers) running at the same time to be merged
+// into a single upstream request. All callers then receive the same
+// Response, which must be treated as read-only. The request runs with the
+// context of the first caller without its cancellation and deadline, so
+// each caller only stops waiting when its own context is done, and the
+// request is cancelled once no caller is waiting for it. Requests altered
+// with WithRequestMutator are never merged.
+var CoalesceGET = false
+
+type inflightCall struct {
+	done    chan struct{}
+	res     *Response
+	err     error
+	info    RequestInfo
+	waiters int
+	cancel  context.CancelFunc
+}
+
+var (
+	inflight   = make(map[string]*inflightCall)
+	inflightLk sync.Mutex
+)
+
+// requestKey returns a key identifying a request for coalescing and caching
+func requestKey(ctx context.Context, path string, param any) (string, error) {
+	data, err := pjson.MarshalContext(ctx, param)
+	if err != nil {
+		return "", err
+	}
+	var auth string
+	if t := contextToken(ctx); t != nil {
+		auth = t.AccessToken
+	}
+	if s := contextSession(ctx); s != nil {
+		// sessions are also identified by their cookies
+		auth += fmt.Sprintf("\x00session:%p", s)
+	}
+	// headers changing the contents of the response
+	r := &http.Request{Header: make(http.Header)}
+	if v := apiVersion(ctx); v != "" {
+		r.Header.Set("Sec-Rest-Version", v)
+	}
+	setLocaleHeaders(ctx, r)
+	var hdr strings.Builder
+	r.Header.Write(&hdr)
+
+	return backendURL(ctx).String() + "\x00" + path + "\x00" + string(data) + "\x00" + auth + "\x00" + hdr.String(), nil
+}
+
+// coalesce runs f, unless a call with the same key is already running in
+// which case its result is returned. f runs in the background with a
+// context that is only cancelled after timeout, or once no caller waits for
+// it anymore. The status and sizes of the request are added to info.
+func coalesce(ctx context.Context, key string, timeout time.Duration, info *RequestInfo, f func(ctx context.Context, info *RequestInfo) (*Response, error)) (*Response, error) {
+	inflightLk.Lock()
+	c, ok := inflight[key]
+	if !ok {
+		c = &inflightCall{done: make(chan struct{}), info: RequestInfo{Method: info.Method, Path: info.Path}}
+		var sctx context.Context
+		sctx, c.cancel = withDefaultTimeout(context.WithoutCancel(ctx), timeout)
+		inflight[key] = c
+		go func() {
+			defer c.cancel()
+			c.res, c.err = f(sctx, &c.info)
+			inflightLk.Lock()
+			if inflight[key] == c {
+				delete(inflight, key)
+			}
+			inflightLk.Unlock()
+			close(c.done)
+		}()
+	}
+	c.waiters += 1
+	inflightLk.Unlock()
+
+	select {
+	case <-c.done:
+		info.Status = c.info.Status
+		info.BytesIn += c.info.BytesIn
+		info.BytesOut += c.info.BytesOut
+		return c.res, c.err
+	case <-ctx.Done():
+		inflightLk.Lock()
+		c.waiters -= 1
+		if c.waiters == 0 {
+			// nobody is interested in the result anymore
+			c.cancel()
+			if inflight[key] == c {
+				delete(inflight, key)
+			}
+		}
+		inflightLk.Unlock()
+		return nil, ctx.Err()
+	}
+}
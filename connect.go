@@ -0,0 +1,82 @@
+package rest
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	connectTo     map[string]string
+	connectToLk   sync.RWMutex
+	connectToOnce sync.Once
+)
+
+// ConnectTo makes connections to host go to addr instead, while requests
+// keep using host for the Host header and the TLS server name, like curl's
+// --connect-to option. This allows testing a backend behind a shared load
+// balancer with its real name. host is a host name, optionally with a port,
+// and addr is a host:port or an address using the same port as requests.
+// It applies to API requests and uploads, but not to traffic going through
+// a proxy. An empty addr removes the override.
+//
+// The DialContext of RestHttpTransport and UploadHttpTransport are wrapped
+// on first use, so custom dialers must be set before calling ConnectTo.
+func ConnectTo(host, addr string) {
+	connectToOnce.Do(func() {
+		RestHttpTransport.DialContext = connectToDialer(RestHttpTransport.DialContext)
+		UploadHttpTransport.DialContext = connectToDialer(UploadHttpTransport.DialContext)
+	})
+
+	connectToLk.Lock()
+	defer connectToLk.Unlock()
+
+	host = strings.ToLower(host)
+	if addr == "" {
+		delete(connectTo, host)
+		return
+	}
+	if connectTo == nil {
+		connectTo = make(map[string]string)
+	}
+	connectTo[host] = addr
+}
+
+// connectTarget returns the address to dial instead of addr, if any
+func connectTarget(addr string) string {
+	connectToLk.RLock()
+	defer connectToLk.RUnlock()
+
+	if len(connectTo) == 0 {
+		return addr
+	}
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	host = strings.ToLower(host)
+	target, ok := connectTo[net.JoinHostPort(host, port)]
+	if !ok {
+		if target, ok = connectTo[host]; !ok {
+			return addr
+		}
+	}
+	if _, _, err := net.SplitHostPort(target); err != nil {
+		// no port given, keep the one of the request
+		target = net.JoinHostPort(target, port)
+	}
+	return target
+}
+
+// connectToDialer wraps dial, or a default dialer if nil, to apply ConnectTo
+// overrides
+func connectToDialer(dial func(ctx context.Context, network, addr string) (net.Conn, error)) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	if dial == nil {
+		dial = (&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}).DialContext
+	}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return dial(ctx, network, connectTarget(addr))
+	}
+}
@@ -0,0 +1,39 @@
+package rest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestConnectTo(t *testing.T) {
+	var host string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host = r.Host
+		w.Write([]byte(`{"result":"success","data":{}}`))
+	}))
+	defer srv.Close()
+	addr, _ := url.Parse(srv.URL)
+
+	ConnectTo("staging.example.com", addr.Host)
+	defer ConnectTo("staging.example.com", "")
+
+	ctx := WithBackend(context.Background(), &url.URL{Scheme: "http", Host: "staging.example.com:" + addr.Port()})
+	if _, err := Do(ctx, "Test", "GET", nil); err != nil {
+		t.Fatalf("request failed: %s", err)
+	}
+	if host != "staging.example.com:"+addr.Port() {
+		t.Errorf("unexpected Host header %q", host)
+	}
+
+	if got := connectTarget("other.example.com:443"); got != "other.example.com:443" {
+		t.Errorf("unexpected override for other host: %s", got)
+	}
+	ConnectTo("lb.example.com", "10.0.0.1")
+	defer ConnectTo("lb.example.com", "")
+	if got := connectTarget("LB.example.com:443"); got != "10.0.0.1:443" {
+		t.Errorf("unexpected target %s", got)
+	}
+}
@@ -0,0 +1,30 @@
+package rest
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"hash"
+)
+
+// CryptoProvider supplies the cryptographic primitives used by this package.
+// Builds requiring FIPS-validated modules or another crypto backend can set
+// Crypto to their own implementation.
+type CryptoProvider interface {
+	// NewSHA256 returns a new SHA-256 hash, used to sign uploads
+	NewSHA256() hash.Hash
+	// SignEd25519 signs message with the given ed25519 private key
+	SignEd25519(key ed25519.PrivateKey, message []byte) ([]byte, error)
+}
+
+// Crypto is the CryptoProvider in use, defaulting to the standard library
+var Crypto CryptoProvider = stdCrypto{}
+
+type stdCrypto struct{}
+
+func (stdCrypto) NewSHA256() hash.Hash {
+	return sha256.New()
+}
+
+func (stdCrypto) SignEd25519(key ed25519.PrivateKey, message []byte) ([]byte, error) {
+	return ed25519.Sign(key, message), nil
+}
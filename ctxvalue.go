@@ -1,8 +1,136 @@
 package rest
 
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// ContextRequest values are keys for per-call options stored in a context.
+// The With* functions should be preferred to setting them directly.
 type ContextRequest int
 
 const (
-	BackendURL   ContextRequest = 1
-	SkipDebugLog ContextRequest = 2
+	BackendURL        ContextRequest = 1  // *url.URL, see WithBackend
+	SkipDebugLog      ContextRequest = 2  // bool, see WithoutDebugLog
+	UploadProgress    ContextRequest = 3  // UploadProgressFunc called as uploads progress, replaced by nested values, see WithProgress
+	Redirect          ContextRequest = 4  // RedirectMode, see WithRedirectMode
+	Version           ContextRequest = 5  // string, see WithAPIVersion
+	Locale            ContextRequest = 6  // string, see WithLocale
+	Timezone          ContextRequest = 7  // *time.Location, see WithTimezone
+	ProgressListeners ContextRequest = 8  // []UploadProgressListener, see WithProgressListener
+	Journal           ContextRequest = 9  // UploadJournal recording completed uploads, see WithUploadJournal
+	UploadPath        ContextRequest = 10 // string, path of the uploaded file recorded in journals, see WithUploadPath
+	ErrorLocale       ContextRequest = 11 // string, see WithErrorLocale
 )
+
+// WithBackend returns a context sending API requests (including the ones
+// proxied by SystemProxy) to backend instead of Scheme://Host
+func WithBackend(ctx context.Context, backend *url.URL) context.Context {
+	return context.WithValue(ctx, BackendURL, backend)
+}
+
+// WithProgress returns a context in which uploads and mail sending report
+// their progress to f, in addition to callbacks set in parent contexts
+func WithProgress(ctx context.Context, f UploadProgressFunc) context.Context {
+	return WithProgressListener(ctx, func(ev *UploadProgressEvent) { f(ev.Sent, ev.Total) })
+}
+
+// WithRedirectMode returns a context in which redirect results are handled
+// according to m instead of Redirects
+func WithRedirectMode(ctx context.Context, m RedirectMode) context.Context {
+	return context.WithValue(ctx, Redirect, m)
+}
+
+// WithLocale returns a context in which the API is asked to return fields
+// localized for locale, such as "ja-JP". Error messages use the same locale
+// unless set with WithErrorLocale.
+func WithLocale(ctx context.Context, locale string) context.Context {
+	return context.WithValue(ctx, Locale, locale)
+}
+
+// WithErrorLocale returns a context in which the API is asked to return
+// error messages in locale, independently of the locale of returned data.
+// This allows showing data in the locale of an end user while logging
+// errors in the locale of the operator.
+func WithErrorLocale(ctx context.Context, locale string) context.Context {
+	return context.WithValue(ctx, ErrorLocale, locale)
+}
+
+// WithTimezone returns a context in which the API is asked to use timezone
+// loc, and Time values decoded from responses are set to loc
+func WithTimezone(ctx context.Context, loc *time.Location) context.Context {
+	return context.WithValue(ctx, Timezone, loc)
+}
+
+// WithoutDebugLog returns a context in which requests are not logged, even
+// if Debug is enabled
+func WithoutDebugLog(ctx context.Context) context.Context {
+	return context.WithValue(ctx, SkipDebugLog, true)
+}
+
+// WithRequestMutator returns a context in which f is called with each API
+// request (from Do, or proxied by SystemProxy) right before it is sent, and
+// can alter it, for example to add headers. Mutators set in parent contexts
+// are called first. Requests sending file contents to storage during uploads
+// are not passed to f.
+func WithRequestMutator(ctx context.Context, f func(req *http.Request)) context.Context {
+	return &requestMutator{Context: ctx, f: f}
+}
+
+type requestMutator struct {
+	context.Context
+	f func(req *http.Request)
+}
+
+type requestMutatorKey struct{}
+
+func (m *requestMutator) Value(v any) any {
+	switch v := v.(type) {
+	case *http.Request:
+		m.Context.Value(v)
+		m.f(v)
+		return nil
+	case requestMutatorKey:
+		return true
+	}
+	return m.Context.Value(v)
+}
+
+// hasRequestMutator returns true if requests made with ctx are altered by a
+// mutator, see WithRequestMutator
+func hasRequestMutator(ctx context.Context) bool {
+	return ctx.Value(requestMutatorKey{}) != nil
+}
+
+// mutateRequest lets the context alter req, see WithRequestMutator
+func mutateRequest(ctx context.Context, req *http.Request) {
+	ctx.Value(req)
+}
+
+// setLocaleHeaders sets the headers for the locale, error locale and timezone
+// of ctx on req
+func setLocaleHeaders(ctx context.Context, req *http.Request) {
+	if l, ok := ctx.Value(Locale).(string); ok && l != "" {
+		req.Header.Set("Accept-Language", l)
+	}
+	if l, ok := ctx.Value(ErrorLocale).(string); ok && l != "" {
+		req.Header.Set("Sec-Error-Language", l)
+	}
+	if loc := contextTimezone(ctx); loc != nil {
+		req.Header.Set("Sec-Time-Zone", loc.String())
+	}
+}
+
+// contextTimezone returns the timezone set with WithTimezone, if any
+func contextTimezone(ctx context.Context) *time.Location {
+	loc, _ := ctx.Value(Timezone).(*time.Location)
+	return loc
+}
+
+// skipDebugLog returns true if requests should not be logged for ctx
+func skipDebugLog(ctx context.Context) bool {
+	v, ok := ctx.Value(SkipDebugLog).(bool)
+	return ok && v
+}
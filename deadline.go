@@ -0,0 +1,26 @@
+package rest
+
+import (
+	"context"
+	"time"
+)
+
+// DefaultTimeout, if not zero, is the timeout of API calls made with a
+// context that has no deadline
+var DefaultTimeout time.Duration
+
+// DefaultUploadTimeout, if not zero, is the timeout of uploads made with a
+// context that has no deadline, from the initial query to completion
+var DefaultUploadTimeout time.Duration
+
+// withDefaultTimeout returns ctx with a timeout of d if d is not zero and
+// ctx has no deadline yet
+func withDefaultTimeout(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	if d <= 0 {
+		return ctx, func() {}
+	}
+	if _, ok := ctx.Deadline(); ok {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, d)
+}
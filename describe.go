@@ -0,0 +1,139 @@
+package rest
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ObjectInfo describes an API object, as returned by an OPTIONS request on
+// the object's path
+type ObjectInfo struct {
+	Name    string        `json:"name,omitempty"`
+	Access  string        `json:"access,omitempty"` // access level required to use the object
+	Fields  []*FieldInfo  `json:"fields,omitempty"`
+	Methods []*MethodInfo `json:"methods,omitempty"`
+}
+
+// FieldInfo describes a field of an API object, or a parameter of a method
+type FieldInfo struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"` // database type, for example "varchar(64)" or "datetime"
+	Null     bool   `json:"null,omitempty"`
+	Required bool   `json:"required,omitempty"`
+}
+
+// MethodInfo describes a method that can be called on an API object, as
+// "Object:method"
+type MethodInfo struct {
+	Name   string       `json:"name"`
+	Verbs  []string     `json:"verbs,omitempty"`  // allowed HTTP methods
+	Access string       `json:"access,omitempty"` // access level required to call the method
+	Static bool         `json:"static,omitempty"` // method is called on the object itself rather than an instance
+	Params []*FieldInfo `json:"params,omitempty"`
+}
+
+// ValidationError is returned by ObjectInfo.Validate when parameters do not
+// match the description of an endpoint, and by ParamBuilder.Build when
+// required parameters are missing
+type ValidationError struct {
+	Path    string
+	Missing []string // required parameters not provided
+	Unknown []string // parameters the endpoint does not accept
+}
+
+func (e *ValidationError) Error() string {
+	var msg []string
+	if len(e.Missing) > 0 {
+		msg = append(msg, "missing "+strings.Join(e.Missing, ", "))
+	}
+	if len(e.Unknown) > 0 {
+		msg = append(msg, "unknown "+strings.Join(e.Unknown, ", "))
+	}
+	if e.Path == "" {
+		return "invalid parameters: " + strings.Join(msg, "; ")
+	}
+	return fmt.Sprintf("invalid parameters for %s: %s", e.Path, strings.Join(msg, "; "))
+}
+
+// Describe returns the description of the API object at path. Method names
+// and ids in path are ignored, "Blog/Article:list" describes Blog/Article.
+func Describe(ctx context.Context, path string) (*ObjectInfo, error) {
+	path, _, _ = strings.Cut(path, ":")
+
+	var info *ObjectInfo
+	if err := Apply(ctx, path, "OPTIONS", nil, &info); err != nil {
+		return nil, err
+	}
+	if info == nil {
+		return nil, fmt.Errorf("no description available for %s", path)
+	}
+	return info, nil
+}
+
+// Field returns the field with the given name, or nil
+func (o *ObjectInfo) Field(name string) *FieldInfo {
+	for _, f := range o.Fields {
+		if f.Name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// Method returns the method with the given name, or nil
+func (o *ObjectInfo) Method(name string) *MethodInfo {
+	for _, m := range o.Methods {
+		if m.Name == name {
+			return m
+		}
+	}
+	return nil
+}
+
+// Validate checks param against the description of the endpoint called with
+// path and method. Calls to methods ("Object:method") are checked against
+// the method's parameters, POST and PATCH requests against the object's
+// fields. Other requests are not checked.
+func (o *ObjectInfo) Validate(path, method string, param Param) error {
+	var fields []*FieldInfo
+	checkRequired := true
+
+	if _, name, ok := strings.Cut(path, ":"); ok {
+		m := o.Method(name)
+		if m == nil {
+			return fmt.Errorf("unknown method %s for %s", name, path)
+		}
+		fields = m.Params
+	} else {
+		switch method {
+		case "POST":
+			fields = o.Fields
+		case "PATCH":
+			fields = o.Fields
+			checkRequired = false
+		default:
+			return nil
+		}
+	}
+
+	verr := &ValidationError{Path: path}
+	known := make(map[string]bool)
+	for _, f := range fields {
+		known[f.Name] = true
+		if _, found := param[f.Name]; checkRequired && f.Required && !found {
+			verr.Missing = append(verr.Missing, f.Name)
+		}
+	}
+	for k := range param {
+		if !known[k] {
+			verr.Unknown = append(verr.Unknown, k)
+		}
+	}
+	if len(verr.Missing) == 0 && len(verr.Unknown) == 0 {
+		return nil
+	}
+	sort.Strings(verr.Unknown)
+	return verr
+}
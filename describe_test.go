@@ -0,0 +1,35 @@
+package rest
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestObjectInfoValidate(t *testing.T) {
+	info := &ObjectInfo{
+		Fields: []*FieldInfo{{Name: "Title", Required: true}, {Name: "Body"}},
+		Methods: []*MethodInfo{
+			{Name: "publish", Params: []*FieldInfo{{Name: "date"}}},
+		},
+	}
+
+	if err := info.Validate("Blog/Article", "POST", Param{"Title": "x", "Body": "y"}); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if err := info.Validate("Blog/Article/abc", "PATCH", Param{"Body": "y"}); err != nil {
+		t.Errorf("unexpected error for PATCH: %s", err)
+	}
+
+	var verr *ValidationError
+	err := info.Validate("Blog/Article", "POST", Param{"Body": "y", "Foo": 1})
+	if !errors.As(err, &verr) || len(verr.Missing) != 1 || verr.Missing[0] != "Title" || len(verr.Unknown) != 1 || verr.Unknown[0] != "Foo" {
+		t.Errorf("expected missing Title and unknown Foo, got %v", err)
+	}
+
+	if err := info.Validate("Blog/Article/abc:publish", "POST", Param{"date": "now"}); err != nil {
+		t.Errorf("unexpected error for method: %s", err)
+	}
+	if err := info.Validate("Blog/Article/abc:delete", "POST", nil); err == nil {
+		t.Errorf("expected error for unknown method")
+	}
+}
@@ -0,0 +1,139 @@
+package rest
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"sync"
+	"time"
+)
+
+// DNSCache is a caching resolver. Use its DialContext method as the
+// DialContext of RestHttpTransport to avoid a DNS lookup for each new
+// connection to the API host:
+//
+//	rest.RestHttpTransport.DialContext = rest.NewDNSCache(5 * time.Minute).DialContext
+type DNSCache struct {
+	// TTL is how long lookup results are used for
+	TTL time.Duration
+	// StaleTTL is how long expired results are still used while they are
+	// refreshed in the background. Zero means expired results are not used.
+	StaleTTL time.Duration
+	// LookupHost is the function used to resolve hosts, defaults to
+	// net.DefaultResolver.LookupHost
+	LookupHost func(ctx context.Context, host string) ([]string, error)
+	// Dialer is used to open connections, a default net.Dialer is used if nil
+	Dialer *net.Dialer
+
+	lk      sync.Mutex
+	entries map[string]*dnsCacheEntry
+}
+
+type dnsCacheEntry struct {
+	addrs      []string
+	expires    time.Time
+	refreshing bool
+}
+
+// NewDNSCache returns a DNSCache keeping results for ttl, and using expired
+// results for another ttl while they are refreshed
+func NewDNSCache(ttl time.Duration) *DNSCache {
+	return &DNSCache{TTL: ttl, StaleTTL: ttl}
+}
+
+// Lookup returns the addresses of host, from the cache if possible
+func (c *DNSCache) Lookup(ctx context.Context, host string) ([]string, error) {
+	now := now()
+
+	c.lk.Lock()
+	e, ok := c.entries[host]
+	if ok && now.Before(e.expires) {
+		c.lk.Unlock()
+		return e.addrs, nil
+	}
+	if ok && now.Before(e.expires.Add(c.StaleTTL)) {
+		if !e.refreshing {
+			e.refreshing = true
+			go c.refresh(host)
+		}
+		c.lk.Unlock()
+		return e.addrs, nil
+	}
+	c.lk.Unlock()
+
+	return c.resolve(ctx, host)
+}
+
+func (c *DNSCache) refresh(host string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if _, err := c.resolve(ctx, host); err != nil {
+		if Debug {
+			slog.Debug(fmt.Sprintf("[rest] failed to refresh %s: %s", host, err), "event", "rest:dns_refresh_fail")
+		}
+		c.lk.Lock()
+		if e, ok := c.entries[host]; ok {
+			e.refreshing = false
+		}
+		c.lk.Unlock()
+	}
+}
+
+// resolve performs a lookup of host and stores the result
+func (c *DNSCache) resolve(ctx context.Context, host string) ([]string, error) {
+	lookup := c.LookupHost
+	if lookup == nil {
+		lookup = net.DefaultResolver.LookupHost
+	}
+	addrs, err := lookup(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	c.lk.Lock()
+	defer c.lk.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[string]*dnsCacheEntry)
+	}
+	c.entries[host] = &dnsCacheEntry{addrs: addrs, expires: now().Add(c.TTL)}
+	return addrs, nil
+}
+
+// DialContext connects to addr, resolving its host through the cache
+func (c *DNSCache) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	dialer := c.Dialer
+	if dialer == nil {
+		dialer = &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	}
+
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	if net.ParseIP(host) != nil {
+		return dialer.DialContext(ctx, network, addr)
+	}
+
+	addrs, err := c.Lookup(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	// try each address in turn
+	for _, ip := range addrs {
+		var conn net.Conn
+		conn, err = dialer.DialContext(ctx, network, net.JoinHostPort(ip, port))
+		if err == nil {
+			return conn, nil
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = fmt.Errorf("no address found for %s", host)
+	}
+	return nil, err
+}
@@ -0,0 +1,46 @@
+package rest
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDNSCache(t *testing.T) {
+	var lookups atomic.Int32
+	c := &DNSCache{
+		TTL:      time.Hour,
+		StaleTTL: time.Hour,
+		LookupHost: func(ctx context.Context, host string) ([]string, error) {
+			lookups.Add(1)
+			return []string{"127.0.0.1"}, nil
+		},
+	}
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		addrs, err := c.Lookup(ctx, "example.com")
+		if err != nil || len(addrs) != 1 || addrs[0] != "127.0.0.1" {
+			t.Fatalf("unexpected lookup result %v %v", addrs, err)
+		}
+	}
+	if n := lookups.Load(); n != 1 {
+		t.Errorf("expected 1 lookup, got %d", n)
+	}
+
+	// expire the entry, stale result should be returned and refreshed
+	c.lk.Lock()
+	c.entries["example.com"].expires = time.Now().Add(-time.Minute)
+	c.lk.Unlock()
+
+	if _, err := c.Lookup(ctx, "example.com"); err != nil {
+		t.Fatalf("stale lookup failed: %s", err)
+	}
+	for i := 0; i < 100 && lookups.Load() < 2; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := lookups.Load(); n != 2 {
+		t.Errorf("expected background refresh, got %d lookups", n)
+	}
+}
@@ -0,0 +1,255 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DownloadPart is a part of an object stored as several parts
+type DownloadPart struct {
+	URL               string
+	Size              int64  // size of the part, -1 if unknown
+	Checksum          string // expected checksum, hex or base64 encoded, not checked if empty
+	ChecksumAlgorithm string // algorithm of Checksum, defaults to sha256
+}
+
+// Downloader downloads objects stored as several parts, fetching parts
+// concurrently and writing them in order. Parts are fetched with
+// UploadHttpClient, so downloads are only limited in time by their context.
+type Downloader struct {
+	Parallel   int           // number of parts downloaded at once, defaults to 3
+	Retries    int           // number of times a failed part is retried, defaults to 0
+	RetryDelay time.Duration // delay before the first retry, doubled on each retry (defaults to 1s)
+}
+
+// DownloadIntegrityError is returned when a downloaded part does not have
+// the expected size or checksum
+type DownloadIntegrityError struct {
+	Part     int    // part number, starting at 1
+	Field    string // "size", or the checksum algorithm such as "sha256"
+	Expected string
+	Got      string
+}
+
+func (e *DownloadIntegrityError) Error() string {
+	return fmt.Sprintf("[rest] download integrity check failed: part %d has %s %s, expected %s", e.Part, e.Field, e.Got, e.Expected)
+}
+
+// Download downloads parts and writes them in order to w, returning the
+// number of bytes written. If w is an io.WriterAt and all part sizes are
+// known, parts are written at their offset as they arrive. Otherwise up to
+// Parallel parts are kept in memory until they can be written.
+func (d *Downloader) Download(ctx context.Context, parts []*DownloadPart, w io.Writer) (int64, error) {
+	if wa, ok := w.(io.WriterAt); ok {
+		if offsets, total, ok := partOffsets(parts); ok {
+			return total, d.downloadAt(ctx, parts, wa, offsets)
+		}
+	}
+	return d.downloadOrdered(ctx, parts, w)
+}
+
+// DownloadFile downloads parts to a new file fn, preallocated when all
+// part sizes are known. The file is removed if the download fails.
+func (d *Downloader) DownloadFile(ctx context.Context, parts []*DownloadPart, fn string) (int64, error) {
+	f, err := os.Create(fn)
+	if err != nil {
+		return 0, err
+	}
+	if _, total, ok := partOffsets(parts); ok {
+		if err := f.Truncate(total); err != nil {
+			f.Close()
+			os.Remove(fn)
+			return 0, err
+		}
+	}
+	n, err := d.Download(ctx, parts, f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(fn)
+		return 0, err
+	}
+	return n, nil
+}
+
+// partOffsets returns the offset of each part, and the total size, or false
+// if some sizes are unknown
+func partOffsets(parts []*DownloadPart) ([]int64, int64, bool) {
+	offsets := make([]int64, len(parts))
+	var total int64
+	for i, p := range parts {
+		if p.Size < 0 {
+			return nil, 0, false
+		}
+		offsets[i] = total
+		total += p.Size
+	}
+	return offsets, total, true
+}
+
+func (d *Downloader) parallel() int {
+	if d.Parallel <= 0 {
+		return 3
+	}
+	return d.Parallel
+}
+
+// downloadAt downloads parts concurrently, writing them at offsets
+func (d *Downloader) downloadAt(ctx context.Context, parts []*DownloadPart, w io.WriterAt, offsets []int64) error {
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	sem := make(chan struct{}, d.parallel())
+	var wg sync.WaitGroup
+	for i, p := range parts {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func(i int, p *DownloadPart) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			err := d.fetch(ctx, i+1, len(parts), p, func() io.Writer {
+				return io.NewOffsetWriter(w, offsets[i])
+			})
+			if err != nil {
+				cancel(err)
+			}
+		}(i, p)
+	}
+	wg.Wait()
+	return downloadErr(ctx)
+}
+
+// downloadOrdered downloads parts concurrently into memory, and writes them
+// to w in order
+func (d *Downloader) downloadOrdered(ctx context.Context, parts []*DownloadPart, w io.Writer) (int64, error) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	bufs := make([]chan *bytes.Buffer, len(parts))
+	for i := range bufs {
+		bufs[i] = make(chan *bytes.Buffer, 1)
+	}
+	sem := make(chan struct{}, d.parallel())
+
+	// start downloads in order, as slots are released by the writer
+	go func() {
+		for i, p := range parts {
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			go func(i int, p *DownloadPart) {
+				buf := &bytes.Buffer{}
+				err := d.fetch(ctx, i+1, len(parts), p, func() io.Writer {
+					buf.Reset()
+					return buf
+				})
+				if err != nil {
+					cancel(err)
+					return
+				}
+				bufs[i] <- buf
+			}(i, p)
+		}
+	}()
+
+	var total int64
+	for i := range parts {
+		select {
+		case buf := <-bufs[i]:
+			n, err := buf.WriteTo(w)
+			total += n
+			if err != nil {
+				cancel(err)
+				return total, err
+			}
+			<-sem
+		case <-ctx.Done():
+			return total, downloadErr(ctx)
+		}
+	}
+	return total, nil
+}
+
+// downloadErr returns the error that cancelled a download
+func downloadErr(ctx context.Context) error {
+	if err := context.Cause(ctx); err != nil && !errors.Is(err, context.Canceled) {
+		return err
+	}
+	return ctx.Err()
+}
+
+// fetch downloads part number no out of count to the writer returned by dst, which is
+// called again for each attempt, and verifies its size and checksum
+func (d *Downloader) fetch(ctx context.Context, no, count int, p *DownloadPart, dst func() io.Writer) error {
+	algo := p.ChecksumAlgorithm
+	if algo == "" {
+		algo = "sha256"
+	}
+	newHash, ok := checksumAlgorithms[algo]
+	if !ok && p.Checksum != "" {
+		return fmt.Errorf("part %d/%d: unsupported checksum algorithm %s", no, count, algo)
+	}
+
+	policy := &EndpointPolicy{Retries: d.Retries, RetryDelay: d.RetryDelay}
+	_, err := policy.retryTemporary(ctx, fmt.Sprintf("download of part %d", no), func() (*Response, error) {
+		var h hash.Hash
+		w := dst()
+		if p.Checksum != "" {
+			h = newHash()
+			w = io.MultiWriter(w, h)
+		}
+		n, err := d.get(ctx, p.URL, w)
+		if err != nil {
+			return nil, err
+		}
+		if p.Size >= 0 && n != p.Size {
+			return nil, &DownloadIntegrityError{Part: no, Field: "size", Expected: strconv.FormatInt(p.Size, 10), Got: strconv.FormatInt(n, 10)}
+		}
+		if h != nil {
+			if sum := h.Sum(nil); !checksumEqual(p.Checksum, sum) {
+				return nil, &DownloadIntegrityError{Part: no, Field: algo, Expected: p.Checksum, Got: fmt.Sprintf("%x", sum)}
+			}
+		}
+		return nil, nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to download part %d/%d: %w", no, count, err)
+	}
+	return nil
+}
+
+// get downloads u to w
+func (d *Downloader) get(ctx context.Context, u string, w io.Writer) (int64, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", u, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := UploadHttpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return 0, newHttpError(resp, body, nil)
+	}
+	return io.Copy(w, resp.Body)
+}
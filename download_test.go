@@ -0,0 +1,75 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDownloader(t *testing.T) {
+	data := map[string]string{"/1": "first part,", "/2": "second part,", "/3": "third"}
+	var fails atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/2" && fails.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(data[r.URL.Path]))
+	}))
+	defer srv.Close()
+
+	parts := func(size bool) []*DownloadPart {
+		var res []*DownloadPart
+		for _, k := range []string{"/1", "/2", "/3"} {
+			sum := sha256.Sum256([]byte(data[k]))
+			p := &DownloadPart{URL: srv.URL + k, Size: -1, Checksum: hex.EncodeToString(sum[:])}
+			if size {
+				p.Size = int64(len(data[k]))
+			}
+			res = append(res, p)
+		}
+		return res
+	}
+	d := &Downloader{Parallel: 2, Retries: 1, RetryDelay: 1}
+	// parts are not downloaded with the API client and its timeout
+	ctx := (&Client{HTTPClient: &http.Client{Timeout: time.Nanosecond}}).Use(context.Background())
+	want := "first part,second part,third"
+
+	// in memory, sizes unknown
+	var buf bytes.Buffer
+	if n, err := d.Download(ctx, parts(false), &buf); err != nil || n != int64(len(want)) || buf.String() != want {
+		t.Errorf("unexpected download %q (%d bytes): %v", buf.String(), n, err)
+	}
+
+	// to a preallocated file
+	fn := filepath.Join(t.TempDir(), "out")
+	fails.Store(0)
+	if _, err := d.DownloadFile(ctx, parts(true), fn); err != nil {
+		t.Fatalf("download failed: %s", err)
+	}
+	if got, _ := os.ReadFile(fn); string(got) != want {
+		t.Errorf("unexpected file contents %q", got)
+	}
+
+	// checksum mismatch
+	p := parts(true)
+	p[2].Checksum = strings.Repeat("0", 64)
+	_, err := d.DownloadFile(ctx, p, fn)
+	var integrityErr *DownloadIntegrityError
+	if !errors.As(err, &integrityErr) || integrityErr.Part != 3 {
+		t.Errorf("expected integrity error on part 3, got %v", err)
+	}
+	if _, err := os.Stat(fn); !os.IsNotExist(err) {
+		t.Errorf("failed download was not removed")
+	}
+}
@@ -0,0 +1,102 @@
+package rest
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DuplicateGuard detects identical mutating requests (POST, PUT, PATCH or
+// DELETE on the same backend and path, with the same parameters and token)
+// issued within Window of each other, such as double submits from a UI or
+// jobs retried at a higher level.
+//
+// While a guard is set, mutating requests carry an Idempotency-Key header.
+// Duplicates are either rejected with a *DuplicateRequestError, or sent with
+// the key of the first request so the server can recognize them.
+type DuplicateGuard struct {
+	Window time.Duration // how long a request is remembered, defaults to 10s
+	Block  bool          // reject duplicates instead of reusing the idempotency key
+
+	lk   sync.Mutex
+	seen map[[sha256.Size]byte]*guardEntry
+}
+
+type guardEntry struct {
+	key string
+	at  time.Time
+}
+
+// Duplicates, if set, is the guard checking mutating requests performed with
+// Do and Apply
+var Duplicates *DuplicateGuard
+
+// DuplicateRequestError is returned when a DuplicateGuard blocks a request
+type DuplicateRequestError struct {
+	Method         string
+	Path           string
+	IdempotencyKey string // key of the original request
+	Since          time.Duration
+}
+
+func (e *DuplicateRequestError) Error() string {
+	return fmt.Sprintf("[rest] duplicate request %s %s blocked, same request was issued %s ago", e.Method, e.Path, e.Since)
+}
+
+// check sets the idempotency key of a mutating request in info, and returns
+// an error if the request is a duplicate to be blocked
+func (g *DuplicateGuard) check(ctx context.Context, method, path string, param any, info *RequestInfo) error {
+	if g == nil {
+		return nil
+	}
+	switch method {
+	case "POST", "PUT", "PATCH", "DELETE":
+	default:
+		return nil
+	}
+	k, err := requestKey(ctx, path, param)
+	if err != nil {
+		return err
+	}
+	h := sha256.Sum256([]byte(method + "\x00" + k))
+
+	window := g.Window
+	if window <= 0 {
+		window = 10 * time.Second
+	}
+	t := now()
+
+	g.lk.Lock()
+	defer g.lk.Unlock()
+
+	if g.seen == nil {
+		g.seen = make(map[[sha256.Size]byte]*guardEntry)
+	}
+	if e, ok := g.seen[h]; ok && t.Sub(e.at) < window {
+		if g.Block {
+			return &DuplicateRequestError{Method: method, Path: path, IdempotencyKey: e.key, Since: t.Sub(e.at)}
+		}
+		info.IdempotencyKey = e.key
+		return nil
+	}
+
+	// forget expired requests
+	for eh, e := range g.seen {
+		if t.Sub(e.at) >= window {
+			delete(g.seen, eh)
+		}
+	}
+	info.IdempotencyKey = newIdempotencyKey()
+	g.seen[h] = &guardEntry{key: info.IdempotencyKey, at: t}
+	return nil
+}
+
+func newIdempotencyKey() string {
+	var b [16]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
@@ -0,0 +1,48 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestDuplicateGuard(t *testing.T) {
+	var keys []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get("Idempotency-Key"))
+		w.Write([]byte(`{"result":"success","data":{}}`))
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+	ctx := context.WithValue(context.Background(), BackendURL, u)
+
+	Duplicates = &DuplicateGuard{}
+	defer func() { Duplicates = nil }()
+
+	for i := 0; i < 2; i++ {
+		if _, err := Do(ctx, "Order", "POST", Param{"item": "a"}); err != nil {
+			t.Fatalf("request failed: %s", err)
+		}
+	}
+	if _, err := Do(ctx, "Order", "POST", Param{"item": "b"}); err != nil {
+		t.Fatalf("request failed: %s", err)
+	}
+	if _, err := Do(ctx, "Order", "GET", Param{"item": "b"}); err != nil {
+		t.Fatalf("request failed: %s", err)
+	}
+	if len(keys) != 4 || keys[0] == "" || keys[0] != keys[1] || keys[2] == keys[0] || keys[3] != "" {
+		t.Errorf("unexpected idempotency keys %q", keys)
+	}
+
+	Duplicates.Block = true
+	var dupErr *DuplicateRequestError
+	if _, err := Do(ctx, "Order", "POST", Param{"item": "a"}); !errors.As(err, &dupErr) || dupErr.IdempotencyKey != keys[0] {
+		t.Errorf("expected duplicate request error, got %v", err)
+	}
+	if len(keys) != 4 {
+		t.Errorf("blocked request was sent")
+	}
+}
@@ -0,0 +1,96 @@
+package rest
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// PathPrefix is the prefix of API paths in urls
+const PathPrefix = "/_special/rest/"
+
+// Endpoint is an API path, such as "User:get" or "Blog/Article/<id>". It
+// is made of slash-separated segments starting with a module name, and an
+// optional method after a colon in the last segment.
+type Endpoint string
+
+// well-known endpoints
+const (
+	OAuth2Token Endpoint = "OAuth2:token"
+	MTASend     Endpoint = "MTA:send"
+	UserGet     Endpoint = "User:get"
+)
+
+// ErrInvalidPath is matched by errors returned for malformed API paths
+var ErrInvalidPath = errors.New("invalid API path")
+
+// InvalidPathError is returned when a call is made to a malformed API path,
+// before anything is sent
+type InvalidPathError struct {
+	Path   string
+	Reason string
+}
+
+func (e *InvalidPathError) Error() string {
+	return fmt.Sprintf("invalid API path %q: %s", e.Path, e.Reason)
+}
+
+func (e *InvalidPathError) Unwrap() error {
+	return ErrInvalidPath
+}
+
+// NewEndpoint returns the endpoint made of the given segments, for example
+// NewEndpoint("Blog", "Article", id)
+func NewEndpoint(segments ...string) Endpoint {
+	return Endpoint(strings.Join(segments, "/"))
+}
+
+// Method returns the endpoint calling method name on e
+func (e Endpoint) Method(name string) Endpoint {
+	return e + Endpoint(":"+name)
+}
+
+func (e Endpoint) String() string {
+	return string(e)
+}
+
+// Validate returns an *InvalidPathError if e is malformed
+func (e Endpoint) Validate() error {
+	return ValidatePath(string(e))
+}
+
+// ValidatePath returns an *InvalidPathError if path is not a valid API path.
+// Do and the other calls validate their path before sending the request.
+func ValidatePath(path string) error {
+	fail := func(reason string) error {
+		return &InvalidPathError{Path: path, Reason: reason}
+	}
+	if path == "" {
+		return fail("empty path")
+	}
+	if path[0] == '/' {
+		return fail("path must not start with a slash")
+	}
+	if i := strings.IndexFunc(path, func(r rune) bool { return r <= ' ' || r == 0x7f || r == '?' || r == '#' }); i != -1 {
+		return fail(fmt.Sprintf("invalid character %q", path[i]))
+	}
+
+	obj, method, hasMethod := strings.Cut(path, ":")
+	for _, seg := range strings.Split(obj, "/") {
+		if seg == "" {
+			return fail("empty path segment")
+		}
+	}
+	if !hasMethod {
+		return nil
+	}
+	if method == "" {
+		return fail("empty method name")
+	}
+	for _, r := range method {
+		if !(r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9') {
+			return fail(fmt.Sprintf("invalid method name %q", method))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package rest
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// DefaultToken, if set, is used for requests whose context carries no token
+var DefaultToken *Token
+
+// ConfigFromEnv configures the package from environment variables. It is not
+// called automatically, so that importing the package does not change its
+// behavior; programs wanting it, such as the command line tools, call it on
+// startup. The following variables are used when set:
+//
+//	REST_HOST     API host (Host)
+//	REST_SCHEME   API scheme (Scheme)
+//	REST_TOKEN    access token used when none is set in the context (DefaultToken)
+//	REST_DEBUG    enable debug logging (Debug), as accepted by strconv.ParseBool
+//	REST_TIMEOUT  timeout of API requests, as a duration ("30s") or a number of seconds
+func ConfigFromEnv() error {
+	if v := os.Getenv("REST_HOST"); v != "" {
+		Host = v
+	}
+	if v := os.Getenv("REST_SCHEME"); v != "" {
+		Scheme = v
+	}
+	if v := os.Getenv("REST_TOKEN"); v != "" {
+		DefaultToken = &Token{AccessToken: v}
+	}
+	if v := os.Getenv("REST_DEBUG"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("REST_DEBUG: %w", err)
+		}
+		Debug = b
+	}
+	if v := os.Getenv("REST_TIMEOUT"); v != "" {
+		d, err := parseEnvDuration(v)
+		if err != nil {
+			return fmt.Errorf("REST_TIMEOUT: %w", err)
+		}
+		RestHttpClient.Timeout = d
+	}
+	return nil
+}
+
+// parseEnvDuration parses a duration, accepting a plain number of seconds
+func parseEnvDuration(v string) (time.Duration, error) {
+	if n, err := strconv.ParseFloat(v, 64); err == nil {
+		return time.Duration(n * float64(time.Second)), nil
+	}
+	return time.ParseDuration(v)
+}
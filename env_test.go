@@ -0,0 +1,69 @@
+package rest
+
+import (
+	"testing"
+	"time"
+)
+
+func TestConfigFromEnv(t *testing.T) {
+	defer func(host, scheme string, tok *Token, debug bool, timeout time.Duration) {
+		Host, Scheme, DefaultToken, Debug, RestHttpClient.Timeout = host, scheme, tok, debug, timeout
+	}(Host, Scheme, DefaultToken, Debug, RestHttpClient.Timeout)
+
+	t.Setenv("REST_HOST", "api.example.com")
+	t.Setenv("REST_SCHEME", "http")
+	t.Setenv("REST_TOKEN", "env-token")
+	t.Setenv("REST_DEBUG", "true")
+	t.Setenv("REST_TIMEOUT", "1.5")
+	if err := ConfigFromEnv(); err != nil {
+		t.Fatalf("ConfigFromEnv failed: %s", err)
+	}
+	if Host != "api.example.com" || Scheme != "http" || !Debug {
+		t.Errorf("unexpected configuration host=%q scheme=%q debug=%v", Host, Scheme, Debug)
+	}
+	if DefaultToken == nil || DefaultToken.AccessToken != "env-token" {
+		t.Errorf("unexpected default token %+v", DefaultToken)
+	}
+	if RestHttpClient.Timeout != 1500*time.Millisecond {
+		t.Errorf("unexpected timeout %s", RestHttpClient.Timeout)
+	}
+
+	// unset variables leave the configuration untouched
+	t.Setenv("REST_HOST", "")
+	t.Setenv("REST_TIMEOUT", "")
+	if err := ConfigFromEnv(); err != nil || Host != "api.example.com" || RestHttpClient.Timeout != 1500*time.Millisecond {
+		t.Errorf("configuration changed by unset variables: %q, %s, %v", Host, RestHttpClient.Timeout, err)
+	}
+
+	t.Setenv("REST_DEBUG", "maybe")
+	if err := ConfigFromEnv(); err == nil {
+		t.Errorf("expected error for invalid REST_DEBUG")
+	}
+	t.Setenv("REST_DEBUG", "")
+	t.Setenv("REST_TIMEOUT", "soon")
+	if err := ConfigFromEnv(); err == nil {
+		t.Errorf("expected error for invalid REST_TIMEOUT")
+	}
+}
+
+func TestParseEnvDuration(t *testing.T) {
+	tests := []struct {
+		in  string
+		out time.Duration
+		ok  bool
+	}{
+		{"30", 30 * time.Second, true},
+		{"0.5", 500 * time.Millisecond, true},
+		{"2m", 2 * time.Minute, true},
+		{"1h30m", 90 * time.Minute, true},
+		{"", 0, false},
+		{"soon", 0, false},
+		{"10 s", 0, false},
+	}
+	for _, tt := range tests {
+		d, err := parseEnvDuration(tt.in)
+		if (err == nil) != tt.ok || d != tt.out {
+			t.Errorf("parseEnvDuration(%q) = %s, %v", tt.in, d, err)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package rest
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+
+	"github.com/KarpelesLab/pjson"
+)
+
+// envelopeField returns a pointer to the field of r the top level response
+// field key is decoded into, or nil if key is not known to this version of
+// the library. Keys are matched case insensitively, as encoding/json does.
+func (r *Response) envelopeField(key string) any {
+	switch strings.ToLower(key) {
+	case "result":
+		return &r.Result
+	case "data":
+		return &r.Data
+	case "error":
+		return &r.Error
+	case "code":
+		return &r.Code
+	case "extra":
+		return &r.Extra
+	case "token":
+		return &r.Token
+	case "paging":
+		return &r.Paging
+	case "job":
+		return &r.Job
+	case "time":
+		return &r.Time
+	case "access":
+		return &r.Access
+	case "exception":
+		return &r.Exception
+	case "redirect_url":
+		return &r.RedirectUrl
+	case "redirect_code":
+		return &r.RedirectCode
+	}
+	return nil
+}
+
+// UnmarshalJSON decodes a response envelope, keeping top level fields not
+// known to this version of the library in ExtraFields. This allows accessing
+// metadata added by newer backends.
+func (r *Response) UnmarshalJSON(b []byte) error {
+	return decodeEnvelope(b, r, nil)
+}
+
+// UnmarshalJSON decodes the envelope the same way as Response, with the data
+// field decoded into the target
+func (e *dataEnvelope) UnmarshalJSON(b []byte) error {
+	return decodeEnvelope(b, e.Response, e)
+}
+
+// decodeEnvelope decodes the envelope b into r in a single pass. If e is not
+// nil, the data field is decoded into e.Data instead of r.Data.
+func decodeEnvelope(b []byte, r *Response, e *dataEnvelope) error {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if tok == nil {
+		// null leaves the response untouched
+		return nil
+	}
+	if tok != json.Delim('{') {
+		return &json.UnmarshalTypeError{Value: jsonKind(tok), Type: reflect.TypeOf(r).Elem(), Offset: dec.InputOffset()}
+	}
+
+	var firstErr error
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		k, _ := tok.(string)
+		dst := r.envelopeField(k)
+		if dst == nil {
+			var v json.RawMessage
+			if err := dec.Decode(&v); err != nil {
+				return err
+			}
+			if r.ExtraFields == nil {
+				r.ExtraFields = make(map[string]pjson.RawMessage)
+			}
+			r.ExtraFields[k] = pjson.RawMessage(v)
+			continue
+		}
+		if e != nil && strings.EqualFold(k, "data") {
+			// null data sets e.Data to nil
+			dst = &e.Data
+		}
+		if err := dec.Decode(dst); err != nil {
+			if _, ok := err.(*json.UnmarshalTypeError); !ok {
+				return err
+			}
+			// like encoding/json, keep decoding the other fields and
+			// report the first type mismatch
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+// jsonKind returns the kind of json value starting with tok, for errors
+func jsonKind(tok json.Token) string {
+	switch tok.(type) {
+	case json.Delim:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "bool"
+	default:
+		return "number"
+	}
+}
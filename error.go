@@ -1,17 +1,52 @@
 package rest
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io/fs"
+	"net/http"
+	"net/url"
 	"os"
+	"strconv"
+	"time"
 )
 
-var ErrLoginRequired = errors.New("login required")
+var (
+	ErrLoginRequired     = errors.New("login required")
+	ErrConflict          = errors.New("conflict")
+	ErrRateLimited       = errors.New("rate limited")
+	ErrServerUnavailable = errors.New("server unavailable")
+	// ErrTimeout matches context.DeadlineExceeded with errors.Is
+	ErrTimeout = fmt.Errorf("request timed out: %w", context.DeadlineExceeded)
+)
+
+// statusError returns the error matching a HTTP status code, if any
+func statusError(code int) error {
+	switch {
+	case code == 401:
+		return ErrLoginRequired
+	case code == 403:
+		return os.ErrPermission
+	case code == 404:
+		return fs.ErrNotExist
+	case code == 408 || code == 504:
+		return ErrTimeout
+	case code == 409:
+		return ErrConflict
+	case code == 429:
+		return ErrRateLimited
+	case code >= 500:
+		return ErrServerUnavailable
+	default:
+		return nil
+	}
+}
 
 type Error struct {
-	Response *Response
-	parent   error
+	Response   *Response
+	RetryAfter time.Duration // delay requested by the server when rate limited, if any
+	parent     error
 }
 
 func (r *Error) Error() string {
@@ -23,26 +58,118 @@ func (r *Error) Unwrap() error {
 		return r.parent
 	}
 	// check for various type of errors
-	switch r.Response.Code {
-	case 403:
-		return os.ErrPermission
-	case 404:
-		return fs.ErrNotExist
-	default:
-		return nil
+	return statusError(r.Response.Code)
+}
+
+// IsTemporary returns true if the request may succeed if retried later. Not
+// all errors matching ErrServerUnavailable are temporary, for example 501
+// Not Implemented is not.
+func (r *Error) IsTemporary() bool {
+	return temporaryStatus(r.Response.Code) || errors.Is(r.parent, ErrRateLimited)
+}
+
+// Is allows matching the error for the status code of the response with
+// errors.Is, even when the error has another parent
+func (r *Error) Is(target error) bool {
+	err := statusError(r.Response.Code)
+	return err != nil && errors.Is(err, target)
+}
+
+// LoginRequiredError is returned when the server requires the user to log
+// in, and matches ErrLoginRequired
+type LoginRequiredError struct {
+	URL      *url.URL // where to send the user to login, may be nil
+	Code     int      // redirect HTTP status code
+	ClientID string   // OAuth2 client_id from the login URL, if any
+	Scope    string   // OAuth2 scope from the login URL, if any
+	Response *Response
+}
+
+// newLoginRequiredError returns a LoginRequiredError for a login redirect
+// response
+func newLoginRequiredError(r *Response) *LoginRequiredError {
+	e := &LoginRequiredError{Code: r.RedirectCode, Response: r}
+	if u, err := url.Parse(r.RedirectUrl); err == nil && r.RedirectUrl != "" {
+		e.URL = u
+		q := u.Query()
+		e.ClientID = q.Get("client_id")
+		e.Scope = q.Get("scope")
+	}
+	return e
+}
+
+func (e *LoginRequiredError) Error() string {
+	if e.URL != nil {
+		return fmt.Sprintf("%s (login at %s)", ErrLoginRequired, e.URL)
 	}
+	return ErrLoginRequired.Error()
+}
+
+func (e *LoginRequiredError) Unwrap() error {
+	return ErrLoginRequired
 }
 
 type HttpError struct {
-	Code int
-	Body []byte
-	e    error // unwrap error
+	Code        int
+	Body        []byte
+	Header      http.Header   // response headers
+	RetryAfter  time.Duration // delay requested with the Retry-After header, if any
+	RequestID   string        // request id assigned by the server, if any
+	ContentType string
+	e           error // unwrap error
+}
+
+// newHttpError returns a HttpError for resp, whose body was read in body
+func newHttpError(resp *http.Response, body []byte, e error) *HttpError {
+	err := &HttpError{
+		Code:        resp.StatusCode,
+		Body:        body,
+		Header:      resp.Header,
+		ContentType: resp.Header.Get("Content-Type"),
+		e:           e,
+	}
+	for _, h := range []string{"X-Request-Id", "X-Amz-Request-Id"} {
+		if v := resp.Header.Get(h); v != "" {
+			err.RequestID = v
+			break
+		}
+	}
+	if v := resp.Header.Get("Retry-After"); v != "" {
+		if n, perr := strconv.Atoi(v); perr == nil {
+			err.RetryAfter = time.Duration(n) * time.Second
+		} else if t, perr := http.ParseTime(v); perr == nil {
+			err.RetryAfter = max(t.Sub(now()), 0)
+		}
+	}
+	return err
 }
 
 func (e *HttpError) Error() string {
 	return fmt.Sprintf("HTTP Error %d: %s", e.Code, e.Body)
 }
 
+// IsTemporary returns true if the request may succeed if retried later
+func (e *HttpError) IsTemporary() bool {
+	return temporaryStatus(e.Code)
+}
+
 func (e *HttpError) Unwrap() error {
 	return e.e
 }
+
+// Is allows matching the error for the status code of e, such as
+// ErrRateLimited, with errors.Is
+func (e *HttpError) Is(target error) bool {
+	err := statusError(e.Code)
+	return err != nil && errors.Is(err, target)
+}
+
+// temporaryStatus returns true for HTTP status codes of requests that may
+// succeed if retried later
+func temporaryStatus(code int) bool {
+	switch code {
+	case 408, 429, 502, 503, 504:
+		return true
+	}
+	return false
+}
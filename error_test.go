@@ -0,0 +1,98 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestStatusErrors(t *testing.T) {
+	cases := []struct {
+		code int
+		err  error
+	}{
+		{401, ErrLoginRequired},
+		{404, fs.ErrNotExist},
+		{408, context.DeadlineExceeded},
+		{409, ErrConflict},
+		{429, ErrRateLimited},
+		{500, ErrServerUnavailable},
+		{501, ErrServerUnavailable},
+		{503, ErrServerUnavailable},
+		{504, ErrTimeout},
+	}
+	for _, c := range cases {
+		if err := error(&Error{Response: &Response{Code: c.code}}); !errors.Is(err, c.err) {
+			t.Errorf("Error with code %d does not match %s", c.code, c.err)
+		}
+		if err := error(&HttpError{Code: c.code}); !errors.Is(err, c.err) {
+			t.Errorf("HttpError with code %d does not match %s", c.code, c.err)
+		}
+	}
+	retry := map[int]bool{400: false, 404: false, 500: false, 501: false, 502: true, 503: true, 504: true, 429: true}
+	for code, temp := range retry {
+		if isTemporary(&HttpError{Code: code}) != temp {
+			t.Errorf("HttpError with code %d: expected temporary=%v", code, temp)
+		}
+		if isTemporary(&Error{Response: &Response{Code: code}}) != temp {
+			t.Errorf("Error with code %d: expected temporary=%v", code, temp)
+		}
+	}
+	if err := error(&HttpError{Code: 400}); errors.Is(err, ErrServerUnavailable) {
+		t.Errorf("HttpError with code 400 matches %s", ErrServerUnavailable)
+	}
+	if err := error(&HttpError{Code: 503, e: ErrNoRefreshToken}); !errors.Is(err, ErrNoRefreshToken) || !errors.Is(err, ErrServerUnavailable) {
+		t.Errorf("HttpError does not match both its cause and status")
+	}
+}
+
+func TestLoginRequiredError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"result":"redirect","exception":"Exception\\Login","redirect_url":"https://example.com/login?client_id=abc&scope=profile","redirect_code":302}`))
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+	ctx := context.WithValue(context.Background(), BackendURL, u)
+
+	_, err := Do(ctx, "Test/obj", "GET", nil)
+	if !errors.Is(err, ErrLoginRequired) {
+		t.Fatalf("expected ErrLoginRequired, got %v", err)
+	}
+	var loginErr *LoginRequiredError
+	if !errors.As(err, &loginErr) {
+		t.Fatalf("expected LoginRequiredError, got %T", err)
+	}
+	if loginErr.URL == nil || loginErr.URL.Host != "example.com" || loginErr.ClientID != "abc" || loginErr.Scope != "profile" {
+		t.Errorf("unexpected login error %+v", loginErr)
+	}
+}
+
+func TestHttpErrorHeaders(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set("Retry-After", "3")
+		w.Header().Set("X-Request-Id", "req-123")
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(`slow down`))
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+	ctx := context.WithValue(context.Background(), BackendURL, u)
+
+	_, err := Do(ctx, "Test/obj", "GET", nil)
+	var httpErr *HttpError
+	if !errors.As(err, &httpErr) {
+		t.Fatalf("expected HttpError, got %v", err)
+	}
+	if httpErr.RetryAfter != 3*time.Second || httpErr.RequestID != "req-123" || httpErr.ContentType != "text/plain" || !httpErr.IsTemporary() {
+		t.Errorf("unexpected error %+v", httpErr)
+	}
+	if (&HttpError{Code: 400}).IsTemporary() {
+		t.Errorf("400 errors should not be temporary")
+	}
+}
@@ -0,0 +1,143 @@
+package rest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrInjectedFault is returned by FaultInjector for injected transport errors
+var ErrInjectedFault = errors.New("injected fault")
+
+// FaultInjector is a http.RoundTripper injecting faults in requests, for use
+// in integration tests checking how applications handle failures:
+//
+//	rest.RestHttpClient.Transport = &rest.FaultInjector{ErrorRate: 0.1, StatusRate: 0.1, Status: 503}
+//
+// Each rate is a probability between 0 and 1.
+type FaultInjector struct {
+	// Transport performs the actual requests, http.DefaultTransport if nil
+	Transport http.RoundTripper
+
+	// ErrorRate is the probability of failing with ErrInjectedFault
+	ErrorRate float64
+	// Latency is added to requests, plus a random duration up to Jitter
+	Latency time.Duration
+	Jitter  time.Duration
+	// StatusRate is the probability of answering with Status (429 by
+	// default) instead of performing the request
+	StatusRate float64
+	Status     int
+	// RetryAfter, if set, is sent as Retry-After header with injected statuses
+	RetryAfter time.Duration
+	// TruncateRate is the probability of cutting the response body halfway
+	TruncateRate float64
+
+	// Rand is the source of randomness, allowing reproducible tests
+	Rand   *rand.Rand
+	randLk sync.Mutex
+}
+
+func (f *FaultInjector) RoundTrip(req *http.Request) (*http.Response, error) {
+	if d := f.Latency + f.duration(f.Jitter); d > 0 {
+		select {
+		case <-time.After(d):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	}
+
+	if f.chance(f.ErrorRate) {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, ErrInjectedFault
+	}
+
+	if f.chance(f.StatusRate) {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return f.statusResponse(req), nil
+	}
+
+	t := f.Transport
+	if t == nil {
+		t = http.DefaultTransport
+	}
+	resp, err := t.RoundTrip(req)
+	if err != nil || !f.chance(f.TruncateRate) {
+		return resp, err
+	}
+
+	// truncate body
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body[:len(body)/2]), errReader{io.ErrUnexpectedEOF}))
+	return resp, nil
+}
+
+func (f *FaultInjector) statusResponse(req *http.Request) *http.Response {
+	code := f.Status
+	if code == 0 {
+		code = http.StatusTooManyRequests
+	}
+	body := fmt.Sprintf(`{"result":"error","error":"injected fault","code":%d}`, code)
+
+	h := make(http.Header)
+	h.Set("Content-Type", "application/json")
+	if f.RetryAfter > 0 {
+		h.Set("Retry-After", strconv.Itoa(int(f.RetryAfter.Seconds())))
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", code, http.StatusText(code)),
+		StatusCode:    code,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        h,
+		Body:          io.NopCloser(bytes.NewReader([]byte(body))),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+func (f *FaultInjector) chance(rate float64) bool {
+	if rate <= 0 {
+		return false
+	}
+	return f.float64() < rate
+}
+
+func (f *FaultInjector) duration(max time.Duration) time.Duration {
+	if max <= 0 {
+		return 0
+	}
+	return time.Duration(f.float64() * float64(max))
+}
+
+func (f *FaultInjector) float64() float64 {
+	if f.Rand == nil {
+		return rand.Float64()
+	}
+	f.randLk.Lock()
+	defer f.randLk.Unlock()
+	return f.Rand.Float64()
+}
+
+type errReader struct {
+	err error
+}
+
+func (e errReader) Read(p []byte) (int, error) {
+	return 0, e.err
+}
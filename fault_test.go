@@ -0,0 +1,38 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestFaultInjector(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"result":"success","data":{"Value":1}}`))
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+	ctx := context.WithValue(context.Background(), BackendURL, u)
+
+	orig := RestHttpClient.Transport
+	defer func() { RestHttpClient.Transport = orig }()
+
+	RestHttpClient.Transport = &FaultInjector{ErrorRate: 1}
+	if _, err := Do(ctx, "Test:fault", "GET", nil); !errors.Is(err, ErrInjectedFault) {
+		t.Errorf("expected injected fault, got %v", err)
+	}
+
+	RestHttpClient.Transport = &FaultInjector{StatusRate: 1, Status: 503}
+	var restErr *Error
+	if _, err := Do(ctx, "Test:fault", "GET", nil); !errors.As(err, &restErr) || restErr.Response.Code != 503 {
+		t.Errorf("expected 503 error, got %v", err)
+	}
+
+	RestHttpClient.Transport = &FaultInjector{TruncateRate: 1}
+	if _, err := Do(ctx, "Test:fault", "GET", nil); err == nil {
+		t.Errorf("expected truncated response to fail")
+	}
+}
@@ -0,0 +1,351 @@
+//go:build js && wasm
+
+package rest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"syscall/js"
+)
+
+// FetchTransport is a http.RoundTripper performing requests with the Fetch
+// API, for use in browsers and other javascript environments. It is used by
+// default for RestHttpClient and UploadHttpClient when fetch is available.
+type FetchTransport struct {
+	// Credentials is the fetch credentials mode (omit, same-origin or
+	// include), defaults to same-origin
+	Credentials string
+	// Mode is the fetch request mode (cors, no-cors or same-origin), defaults
+	// to cors
+	Mode string
+	// StreamRequests causes request bodies to be streamed instead of being
+	// read in memory before the request is sent, if the runtime supports it.
+	// Browsers only support streaming over HTTP/2 and later.
+	StreamRequests bool
+}
+
+// fetchStreamingSupported is true if fetch accepts ReadableStream request bodies
+var fetchStreamingSupported = detectFetchStreaming()
+
+func init() {
+	if !js.Global().Get("fetch").Truthy() {
+		return
+	}
+	RestHttpClient.Transport = &FetchTransport{}
+	// browsers can only stream over HTTP/2, which storage endpoints may not
+	// offer, but server runtimes such as node stream over any protocol
+	browser := js.Global().Get("document").Truthy()
+	UploadHttpClient = &http.Client{Transport: &FetchTransport{StreamRequests: !browser}}
+}
+
+// detectFetchStreaming checks if fetch supports streaming request bodies. On
+// runtimes that don't, the stream is turned into a string and a text/plain
+// content type is set on the request.
+func detectFetchStreaming() (ok bool) {
+	defer func() {
+		if recover() != nil {
+			ok = false
+		}
+	}()
+
+	rs := js.Global().Get("ReadableStream")
+	if !rs.Truthy() {
+		return false
+	}
+	opt := js.Global().Get("Object").New()
+	opt.Set("method", "POST")
+	opt.Set("body", rs.New())
+	opt.Set("duplex", "half")
+	req := js.Global().Get("Request").New("http://localhost/", opt)
+	return !req.Get("headers").Call("has", "Content-Type").Bool()
+}
+
+func (t *FetchTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	opt := js.Global().Get("Object").New()
+	opt.Set("method", req.Method)
+	opt.Set("credentials", fetchOption(t.Credentials, "same-origin"))
+	opt.Set("mode", fetchOption(t.Mode, "cors"))
+
+	ac := js.Global().Get("AbortController")
+	if ac.Truthy() {
+		ac = ac.New()
+		opt.Set("signal", ac.Get("signal"))
+	}
+
+	headers := js.Global().Get("Headers").New()
+	for k, vs := range req.Header {
+		for _, v := range vs {
+			headers.Call("append", k, v)
+		}
+	}
+	opt.Set("headers", headers)
+
+	release := func() {}
+	if req.Body != nil && req.Body != http.NoBody {
+		if t.StreamRequests && fetchStreamingSupported {
+			var stream js.Value
+			stream, release = fetchReadableStream(req.Body)
+			opt.Set("body", stream)
+			opt.Set("duplex", "half")
+		} else {
+			body, err := io.ReadAll(req.Body)
+			req.Body.Close()
+			if err != nil {
+				return nil, err
+			}
+			if len(body) > 0 {
+				buf := js.Global().Get("Uint8Array").New(len(body))
+				js.CopyBytesToJS(buf, body)
+				opt.Set("body", buf)
+			}
+		}
+	}
+	defer release()
+
+	respCh := make(chan *http.Response, 1)
+	errCh := make(chan error, 1)
+
+	success := js.FuncOf(func(this js.Value, args []js.Value) any {
+		respCh <- fetchResponse(req, args[0])
+		return nil
+	})
+	defer success.Release()
+	failure := js.FuncOf(func(this js.Value, args []js.Value) any {
+		errCh <- fmt.Errorf("fetch failed: %s", jsErrorMessage(args[0]))
+		return nil
+	})
+	defer failure.Release()
+
+	js.Global().Call("fetch", req.URL.String(), opt).Call("then", success, failure)
+
+	select {
+	case <-req.Context().Done():
+		if ac.Truthy() {
+			ac.Call("abort")
+			// wait for the promise to settle before releasing callbacks
+			select {
+			case resp := <-respCh:
+				resp.Body.Close()
+			case <-errCh:
+			}
+		}
+		return nil, req.Context().Err()
+	case resp := <-respCh:
+		return resp, nil
+	case err := <-errCh:
+		return nil, err
+	}
+}
+
+// fetchResponse converts a fetch Response object to a http.Response
+func fetchResponse(req *http.Request, res js.Value) *http.Response {
+	header := make(http.Header)
+	it := res.Get("headers").Call("entries")
+	for {
+		n := it.Call("next")
+		if n.Get("done").Bool() {
+			break
+		}
+		pair := n.Get("value")
+		header.Add(pair.Index(0).String(), pair.Index(1).String())
+	}
+
+	contentLength := int64(-1)
+	if cl, err := strconv.ParseInt(header.Get("Content-Length"), 10, 64); err == nil {
+		contentLength = cl
+	}
+
+	var body io.ReadCloser
+	if b := res.Get("body"); b.Truthy() {
+		body = &fetchStreamReader{reader: b.Call("getReader")}
+	} else {
+		// streaming responses not supported
+		body = &fetchArrayReader{promise: res.Call("arrayBuffer")}
+	}
+
+	code := res.Get("status").Int()
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", code, http.StatusText(code)),
+		StatusCode:    code,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          body,
+		ContentLength: contentLength,
+		Request:       req,
+	}
+}
+
+// fetchReadableStream returns a ReadableStream reading from body. The
+// returned function must be called once the stream is not needed anymore.
+func fetchReadableStream(body io.ReadCloser) (js.Value, func()) {
+	pull := js.FuncOf(func(this js.Value, args []js.Value) any {
+		controller := args[0]
+		var executor js.Func
+		executor = js.FuncOf(func(this js.Value, pargs []js.Value) any {
+			resolve := pargs[0]
+			// reading may block, do it outside of the js callback
+			go func() {
+				buf := make([]byte, 64*1024)
+				n, err := body.Read(buf)
+				if n > 0 {
+					arr := js.Global().Get("Uint8Array").New(n)
+					js.CopyBytesToJS(arr, buf[:n])
+					controller.Call("enqueue", arr)
+				}
+				switch {
+				case err == io.EOF:
+					controller.Call("close")
+				case err != nil:
+					controller.Call("error", js.Global().Get("Error").New(err.Error()))
+				}
+				resolve.Invoke()
+			}()
+			return nil
+		})
+		// the executor is called synchronously by the Promise constructor
+		p := js.Global().Get("Promise").New(executor)
+		executor.Release()
+		return p
+	})
+	cancel := js.FuncOf(func(this js.Value, args []js.Value) any {
+		body.Close()
+		return nil
+	})
+
+	src := js.Global().Get("Object").New()
+	src.Set("pull", pull)
+	src.Set("cancel", cancel)
+	stream := js.Global().Get("ReadableStream").New(src)
+
+	return stream, func() {
+		pull.Release()
+		cancel.Release()
+		body.Close()
+	}
+}
+
+// fetchStreamReader reads a response body from a ReadableStreamDefaultReader
+type fetchStreamReader struct {
+	reader  js.Value
+	pending []byte
+	err     error
+}
+
+func (r *fetchStreamReader) Read(p []byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	if len(r.pending) == 0 {
+		bufCh := make(chan []byte, 1)
+		errCh := make(chan error, 1)
+		success := js.FuncOf(func(this js.Value, args []js.Value) any {
+			result := args[0]
+			if result.Get("done").Bool() {
+				errCh <- io.EOF
+				return nil
+			}
+			value := result.Get("value")
+			buf := make([]byte, value.Get("byteLength").Int())
+			js.CopyBytesToGo(buf, value)
+			bufCh <- buf
+			return nil
+		})
+		defer success.Release()
+		failure := js.FuncOf(func(this js.Value, args []js.Value) any {
+			errCh <- errors.New(jsErrorMessage(args[0]))
+			return nil
+		})
+		defer failure.Release()
+
+		r.reader.Call("read").Call("then", success, failure)
+		select {
+		case buf := <-bufCh:
+			r.pending = buf
+		case err := <-errCh:
+			r.err = err
+			return 0, err
+		}
+	}
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
+
+func (r *fetchStreamReader) Close() error {
+	if r.err == nil {
+		r.err = errors.New("read on closed response body")
+		r.reader.Call("cancel")
+	}
+	return nil
+}
+
+// fetchArrayReader reads a response body from a promise resolving to an
+// ArrayBuffer, for runtimes lacking streaming support
+type fetchArrayReader struct {
+	promise js.Value
+	r       *bytes.Reader
+	err     error
+}
+
+func (r *fetchArrayReader) Read(p []byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	if r.r == nil {
+		bufCh := make(chan []byte, 1)
+		errCh := make(chan error, 1)
+		success := js.FuncOf(func(this js.Value, args []js.Value) any {
+			arr := js.Global().Get("Uint8Array").New(args[0])
+			buf := make([]byte, arr.Get("byteLength").Int())
+			js.CopyBytesToGo(buf, arr)
+			bufCh <- buf
+			return nil
+		})
+		defer success.Release()
+		failure := js.FuncOf(func(this js.Value, args []js.Value) any {
+			errCh <- errors.New(jsErrorMessage(args[0]))
+			return nil
+		})
+		defer failure.Release()
+
+		r.promise.Call("then", success, failure)
+		select {
+		case buf := <-bufCh:
+			r.r = bytes.NewReader(buf)
+		case err := <-errCh:
+			r.err = err
+			return 0, err
+		}
+	}
+	return r.r.Read(p)
+}
+
+func (r *fetchArrayReader) Close() error {
+	if r.err == nil {
+		r.err = errors.New("read on closed response body")
+	}
+	return nil
+}
+
+func fetchOption(v, def string) string {
+	if v == "" {
+		return def
+	}
+	return v
+}
+
+// jsErrorMessage returns the message of a javascript error value
+func jsErrorMessage(v js.Value) string {
+	if v.Type() == js.TypeObject {
+		if msg := v.Get("message"); msg.Type() == js.TypeString {
+			return msg.String()
+		}
+	}
+	return v.String()
+}
@@ -0,0 +1,77 @@
+package rest
+
+import (
+	"context"
+	"io"
+)
+
+// Result holds the typed data of a response along with the response itself,
+// which gives access to the envelope (paging, job, access, timing)
+type Result[T any] struct {
+	Data     T
+	Response *Response
+}
+
+// As performs a request and returns the returned data decoded as T
+func As[T any](ctx context.Context, path, method string, param any) (T, error) {
+	var res T
+	err := Apply(ctx, path, method, param, &res)
+	return res, err
+}
+
+// AsFull performs a request and returns the returned data decoded as T, as
+// well as the response envelope. The data is decoded directly from the
+// response body, so Response.Data is not set.
+func AsFull[T any](ctx context.Context, path, method string, param any) (*Result[T], error) {
+	res := &Result[T]{}
+	resp, err := do(ctx, path, method, param, &res.Data)
+	if err != nil {
+		return nil, err
+	}
+	res.Response = resp
+	return res, nil
+}
+
+// Get performs a GET request and returns the returned data decoded as T
+func Get[T any](ctx context.Context, path string, param any) (T, error) {
+	return As[T](ctx, path, "GET", param)
+}
+
+// Post performs a POST request and returns the returned data decoded as T
+func Post[T any](ctx context.Context, path string, param any) (T, error) {
+	return As[T](ctx, path, "POST", param)
+}
+
+// Put performs a PUT request and returns the returned data decoded as T
+func Put[T any](ctx context.Context, path string, param any) (T, error) {
+	return As[T](ctx, path, "PUT", param)
+}
+
+// Patch performs a PATCH request and returns the returned data decoded as T
+func Patch[T any](ctx context.Context, path string, param any) (T, error) {
+	return As[T](ctx, path, "PATCH", param)
+}
+
+// Delete performs a DELETE request and returns the returned data decoded as
+// T. Use Do to ignore the returned data.
+func Delete[T any](ctx context.Context, path string, param any) (T, error) {
+	return As[T](ctx, path, "DELETE", param)
+}
+
+// UploadApply performs an upload like Upload, and decodes the data returned
+// on completion into target
+func UploadApply(ctx context.Context, req, method string, param Param, f io.Reader, mimeType string, target any) error {
+	res, err := Upload(ctx, req, method, param, f, mimeType)
+	if err != nil {
+		return err
+	}
+	return applyData(ctx, res, target)
+}
+
+// UploadAs performs an upload like Upload, and returns the data returned on
+// completion decoded as T
+func UploadAs[T any](ctx context.Context, req, method string, param Param, f io.Reader, mimeType string) (T, error) {
+	var res T
+	err := UploadApply(ctx, req, method, param, f, mimeType, &res)
+	return res, err
+}
@@ -0,0 +1,289 @@
+package rest
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// HARRecorder is a http.RoundTripper recording requests and responses, which
+// can be saved as a HTTP Archive (HAR) file for analysis tools:
+//
+//	har := rest.NewHARRecorder(rest.RestHttpClient.Transport)
+//	rest.RestHttpClient.Transport = har
+//	defer har.Save("session.har")
+type HARRecorder struct {
+	// Transport performs the requests
+	Transport http.RoundTripper
+	// RedactHeaders lists the headers whose value is not recorded, defaults
+	// to the global RedactHeaders. Bodies, urls and query parameters are
+	// redacted according to RedactParams and RedactPatterns.
+	RedactHeaders []string
+	// MaxBodySize is the maximum number of bytes of each body recorded,
+	// defaults to 1MB
+	MaxBodySize int64
+
+	lk      sync.Mutex
+	entries []*harEntry
+}
+
+// NewHARRecorder returns a HARRecorder recording requests performed by t
+func NewHARRecorder(t http.RoundTripper) *HARRecorder {
+	if t == nil {
+		t = http.DefaultTransport
+	}
+	return &HARRecorder{Transport: t}
+}
+
+type harLog struct {
+	Version string      `json:"version"`
+	Creator harCreator  `json:"creator"`
+	Entries []*harEntry `json:"entries"`
+}
+
+type harCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type harEntry struct {
+	StartedDateTime time.Time   `json:"startedDateTime"`
+	Time            float64     `json:"time"` // ms
+	Request         harRequest  `json:"request"`
+	Response        harResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         harTimings  `json:"timings"`
+	Comment         string      `json:"comment,omitempty"`
+
+	start time.Time
+}
+
+type harNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type harRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Headers     []harNameValue `json:"headers"`
+	QueryString []harNameValue `json:"queryString"`
+	Cookies     []harNameValue `json:"cookies"`
+	PostData    *harPostData   `json:"postData,omitempty"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+}
+
+type harPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+	Encoding string `json:"encoding,omitempty"`
+}
+
+type harResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Headers     []harNameValue `json:"headers"`
+	Cookies     []harNameValue `json:"cookies"`
+	Content     harContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+}
+
+type harContent struct {
+	Size     int64  `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+}
+
+type harTimings struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+func (h *HARRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	e := &harEntry{StartedDateTime: time.Now(), start: time.Now()}
+	e.Request = harRequest{
+		Method:      req.Method,
+		URL:         redactURL(req.URL),
+		HTTPVersion: "HTTP/1.1",
+		Headers:     h.headers(req.Header),
+		QueryString: []harNameValue{},
+		Cookies:     []harNameValue{},
+		HeadersSize: -1,
+		BodySize:    req.ContentLength,
+	}
+	for k, vs := range req.URL.Query() {
+		for _, v := range vs {
+			if isRedactedParam(k) {
+				v = redacted
+			}
+			e.Request.QueryString = append(e.Request.QueryString, harNameValue{k, Redact(v)})
+		}
+	}
+
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody != nil {
+		// record a copy of the body
+		if body, err := req.GetBody(); err == nil {
+			buf, _ := io.ReadAll(io.LimitReader(body, h.maxBodySize()))
+			body.Close()
+			text, enc := harText(buf)
+			if enc == "" {
+				text = Redact(text)
+			}
+			e.Request.PostData = &harPostData{MimeType: req.Header.Get("Content-Type"), Text: text, Encoding: enc}
+		}
+	}
+
+	h.lk.Lock()
+	h.entries = append(h.entries, e)
+	h.lk.Unlock()
+
+	resp, err := h.Transport.RoundTrip(req)
+	wait := time.Since(e.start)
+
+	h.lk.Lock()
+	defer h.lk.Unlock()
+	e.Timings.Wait = ms(wait)
+	e.Time = ms(wait)
+	if err != nil {
+		e.Comment = Redact(err.Error())
+		return nil, err
+	}
+
+	e.Response = harResponse{
+		Status:      resp.StatusCode,
+		StatusText:  http.StatusText(resp.StatusCode),
+		HTTPVersion: resp.Proto,
+		Headers:     h.headers(resp.Header),
+		Cookies:     []harNameValue{},
+		Content:     harContent{Size: resp.ContentLength, MimeType: resp.Header.Get("Content-Type")},
+		RedirectURL: resp.Header.Get("Location"),
+		HeadersSize: -1,
+		BodySize:    resp.ContentLength,
+	}
+	resp.Body = &harBody{ReadCloser: resp.Body, h: h, e: e, limit: h.maxBodySize()}
+	return resp, nil
+}
+
+func (h *HARRecorder) maxBodySize() int64 {
+	if h.MaxBodySize > 0 {
+		return h.MaxBodySize
+	}
+	return 1024 * 1024
+}
+
+func (h *HARRecorder) headers(hdr http.Header) []harNameValue {
+	res := []harNameValue{}
+	for k, vs := range hdr {
+		for _, v := range vs {
+			res = append(res, harNameValue{k, redactHeader(k, v, h.RedactHeaders)})
+		}
+	}
+	return res
+}
+
+// WriteTo writes the recorded traffic to w as a HAR document
+func (h *HARRecorder) WriteTo(w io.Writer) (int64, error) {
+	h.lk.Lock()
+	doc := struct {
+		Log harLog `json:"log"`
+	}{harLog{Version: "1.2", Creator: harCreator{Name: "github.com/KarpelesLab/rest", Version: "1"}, Entries: h.entries}}
+	if doc.Log.Entries == nil {
+		doc.Log.Entries = []*harEntry{}
+	}
+	buf, err := json.MarshalIndent(doc, "", "  ")
+	h.lk.Unlock()
+	if err != nil {
+		return 0, err
+	}
+	n, err := w.Write(buf)
+	return int64(n), err
+}
+
+// Save writes the recorded traffic to a HAR file at path
+func (h *HARRecorder) Save(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := h.WriteTo(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Reset discards the recorded traffic
+func (h *HARRecorder) Reset() {
+	h.lk.Lock()
+	defer h.lk.Unlock()
+	h.entries = nil
+}
+
+// harBody records the response body as it is read
+type harBody struct {
+	io.ReadCloser
+	h     *HARRecorder
+	e     *harEntry
+	buf   bytes.Buffer
+	size  int64
+	limit int64
+	once  sync.Once
+}
+
+func (b *harBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if remain := b.limit - int64(b.buf.Len()); remain > 0 {
+		b.buf.Write(p[:min(int64(n), remain)])
+	}
+	b.size += int64(n)
+	if err == io.EOF {
+		b.done()
+	}
+	return n, err
+}
+
+func (b *harBody) Close() error {
+	b.done()
+	return b.ReadCloser.Close()
+}
+
+func (b *harBody) done() {
+	b.once.Do(func() {
+		b.h.lk.Lock()
+		defer b.h.lk.Unlock()
+		total := time.Since(b.e.start)
+		b.e.Timings.Receive = ms(total) - b.e.Timings.Wait
+		b.e.Time = ms(total)
+		b.e.Response.Content.Size = b.size
+		b.e.Response.BodySize = b.size
+		b.e.Response.Content.Text, b.e.Response.Content.Encoding = harText(b.buf.Bytes())
+		if b.e.Response.Content.Encoding == "" {
+			b.e.Response.Content.Text = Redact(b.e.Response.Content.Text)
+		}
+	})
+}
+
+// harText returns buf as text, base64 encoded if it isn't valid utf-8
+func harText(buf []byte) (string, string) {
+	if utf8.Valid(buf) {
+		return string(buf), ""
+	}
+	return base64.StdEncoding.EncodeToString(buf), "base64"
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
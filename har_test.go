@@ -0,0 +1,56 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestHARRecorder(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"result":"success","data":{"Value":1}}`))
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+
+	har := NewHARRecorder(RestHttpClient.Transport)
+	orig := RestHttpClient.Transport
+	RestHttpClient.Transport = har
+	defer func() { RestHttpClient.Transport = orig }()
+
+	ctx := context.WithValue(context.Background(), BackendURL, u)
+	ctx = (&Token{AccessToken: "secret", Type: "Bearer"}).Use(ctx)
+	if _, err := Do(ctx, "Test/obj:update", "POST", Param{"a": 1}); err != nil {
+		t.Fatalf("Do failed: %s", err)
+	}
+
+	var buf bytes.Buffer
+	if _, err := har.WriteTo(&buf); err != nil {
+		t.Fatalf("WriteTo failed: %s", err)
+	}
+	if bytes.Contains(buf.Bytes(), []byte("secret")) {
+		t.Errorf("token not redacted from HAR")
+	}
+
+	var doc struct {
+		Log harLog `json:"log"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("invalid HAR: %s", err)
+	}
+	if len(doc.Log.Entries) != 1 {
+		t.Fatalf("expected 1 entry, got %d", len(doc.Log.Entries))
+	}
+	e := doc.Log.Entries[0]
+	if e.Request.Method != "POST" || e.Request.PostData == nil || e.Request.PostData.Text != `{"a":1}` {
+		t.Errorf("unexpected request %+v", e.Request)
+	}
+	if e.Response.Status != 200 || e.Response.Content.Text != `{"result":"success","data":{"Value":1}}` {
+		t.Errorf("unexpected response %+v", e.Response)
+	}
+}
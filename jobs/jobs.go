@@ -0,0 +1,218 @@
+// Package jobs implements helpers for server-side asynchronous jobs.
+//
+// Endpoints performing long-running work return a job reference in the
+// response (see rest.Response.Job). The job can then be polled until it
+// completes:
+//
+//	job, err := jobs.Run(ctx, "Some/Object:longOperation", "POST", param, nil)
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/KarpelesLab/pjson"
+	"github.com/KarpelesLab/rest"
+)
+
+var (
+	// Endpoint is the API path of job objects
+	Endpoint = "Job"
+
+	// PollInterval is the initial delay between status checks, which grows
+	// up to MaxPollInterval
+	PollInterval    = time.Second
+	MaxPollInterval = 15 * time.Second
+
+	ErrNoJob     = errors.New("response does not contain a job")
+	ErrCancelled = errors.New("job was cancelled")
+)
+
+// Job is the state of a server-side job
+type Job struct {
+	ID       string
+	Status   string  // pending, running, done, failed or cancelled
+	Progress float64 // between 0 and 1, if reported by the server
+	Message  string
+	Error    string
+	Result   pjson.RawMessage
+}
+
+// JobError is returned when a job fails
+type JobError struct {
+	Job *Job
+}
+
+func (e *JobError) Error() string {
+	return fmt.Sprintf("job %s failed: %s", e.Job.ID, e.Job.Error)
+}
+
+// Done returns true if the job is not running anymore
+func (j *Job) Done() bool {
+	switch j.Status {
+	case "done", "complete", "completed", "success", "failed", "error", "cancelled", "canceled":
+		return true
+	}
+	return false
+}
+
+// Err returns the error of a finished job, or nil if it succeeded or is
+// still running
+func (j *Job) Err() error {
+	switch j.Status {
+	case "failed", "error":
+		return &JobError{Job: j}
+	case "cancelled", "canceled":
+		return ErrCancelled
+	}
+	return nil
+}
+
+// Apply decodes the result of the job into v
+func (j *Job) Apply(v any) error {
+	return pjson.Unmarshal(j.Result, v)
+}
+
+// FromResponse returns the job referenced by res
+func FromResponse(res *rest.Response) (*Job, error) {
+	if res == nil || res.Job == nil {
+		return nil, ErrNoJob
+	}
+	return parseJob(res.Job)
+}
+
+// Submit performs a request starting a job, and returns the job
+func Submit(ctx context.Context, path, method string, param any) (*Job, error) {
+	res, err := rest.Do(ctx, path, method, param)
+	if err != nil {
+		return nil, err
+	}
+	return FromResponse(res)
+}
+
+// Status fetches the current state of the job with the given id
+func Status(ctx context.Context, id string) (*Job, error) {
+	res, err := rest.Do(ctx, Endpoint+"/"+id, "GET", nil)
+	if err != nil {
+		return nil, err
+	}
+	v, err := res.Value()
+	if err != nil {
+		return nil, err
+	}
+	job, err := parseJob(v)
+	if err != nil {
+		return nil, err
+	}
+	if job.ID == "" {
+		job.ID = id
+	}
+	return job, nil
+}
+
+// Cancel requests the cancellation of the job with the given id
+func Cancel(ctx context.Context, id string) error {
+	_, err := rest.Do(ctx, Endpoint+"/"+id+":cancel", "POST", nil)
+	return err
+}
+
+// Wait polls the job with the given id until it completes or ctx is done.
+// progress, if not nil, is called with each state of the job. The returned
+// error is a *JobError if the job failed.
+func Wait(ctx context.Context, id string, progress func(*Job)) (*Job, error) {
+	delay := PollInterval
+	for {
+		job, err := Status(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if progress != nil {
+			progress(job)
+		}
+		if job.Done() {
+			return job, job.Err()
+		}
+
+		t := rest.DefaultClock.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return job, ctx.Err()
+		case <-t.C():
+		}
+		delay = min(delay*2, MaxPollInterval)
+	}
+}
+
+// Run submits a job and waits for it to complete
+func Run(ctx context.Context, path, method string, param any, progress func(*Job)) (*Job, error) {
+	job, err := Submit(ctx, path, method, param)
+	if err != nil {
+		return nil, err
+	}
+	if job.Done() {
+		if progress != nil {
+			progress(job)
+		}
+		return job, job.Err()
+	}
+	return Wait(ctx, job.ID, progress)
+}
+
+// parseJob reads a job from a reference returned by the API, either an id
+// or an object
+func parseJob(v any) (*Job, error) {
+	switch v := v.(type) {
+	case string:
+		return &Job{ID: v, Status: "pending"}, nil
+	case map[string]any:
+		job := &Job{
+			ID:      lookupString(v, "Job__", "Job_Id", "id", "ID"),
+			Status:  strings.ToLower(lookupString(v, "Status", "status")),
+			Message: lookupString(v, "Message", "message"),
+			Error:   lookupString(v, "Error", "error"),
+		}
+		if p := lookupString(v, "Progress", "progress"); p != "" {
+			job.Progress, _ = strconv.ParseFloat(p, 64)
+			if job.Progress > 1 {
+				// percentage
+				job.Progress /= 100
+			}
+		}
+		for _, k := range []string{"Result", "result"} {
+			if r, ok := v[k]; ok {
+				job.Result, _ = pjson.Marshal(r)
+				break
+			}
+		}
+		if job.ID == "" {
+			return nil, ErrNoJob
+		}
+		if job.Status == "" {
+			job.Status = "pending"
+		}
+		return job, nil
+	default:
+		return nil, ErrNoJob
+	}
+}
+
+func lookupString(m map[string]any, keys ...string) string {
+	for _, k := range keys {
+		switch v := m[k].(type) {
+		case string:
+			return v
+		case float64:
+			return strconv.FormatFloat(v, 'f', -1, 64)
+		case int64:
+			return strconv.FormatInt(v, 10)
+		case int:
+			return strconv.Itoa(v)
+		}
+	}
+	return ""
+}
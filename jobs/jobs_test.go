@@ -0,0 +1,56 @@
+package jobs
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/KarpelesLab/rest"
+)
+
+func TestRun(t *testing.T) {
+	var polls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/_special/rest/Test:start":
+			w.Write([]byte(`{"result":"success","data":null,"job":{"Job__":"job-1","Status":"pending"}}`))
+		case "/_special/rest/Job/job-1":
+			if polls.Add(1) < 3 {
+				w.Write([]byte(`{"result":"success","data":{"Job__":"job-1","Status":"running","Progress":50}}`))
+				return
+			}
+			w.Write([]byte(`{"result":"success","data":{"Job__":"job-1","Status":"done","Result":{"Value":42}}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+	ctx := rest.WithBackend(context.Background(), u)
+
+	PollInterval = time.Millisecond
+	defer func() { PollInterval = time.Second }()
+
+	var updates int
+	job, err := Run(ctx, "Test:start", "POST", nil, func(j *Job) {
+		updates++
+		if j.Status == "running" && j.Progress != 0.5 {
+			t.Errorf("unexpected progress %f", j.Progress)
+		}
+	})
+	if err != nil {
+		t.Fatalf("Run failed: %s", err)
+	}
+	if updates != 3 {
+		t.Errorf("expected 3 progress updates, got %d", updates)
+	}
+
+	var res struct{ Value int }
+	if err := job.Apply(&res); err != nil || res.Value != 42 {
+		t.Errorf("unexpected job result %+v %v", res, err)
+	}
+}
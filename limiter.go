@@ -0,0 +1,150 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+var ErrRetryBudgetExhausted = errors.New("retry budget exhausted")
+
+var (
+	// Limiter, if set, caps the number of requests in flight, both API
+	// requests and upload requests, for clients without their own limiter
+	Limiter *ConcurrencyLimiter
+
+	// Retries, if set, limits how many retries can be performed relative to
+	// the number of requests
+	Retries *RetryBudget
+)
+
+// ConcurrencyLimiter is a semaphore capping concurrent requests
+type ConcurrencyLimiter struct {
+	sem chan struct{}
+}
+
+// NewConcurrencyLimiter returns a limiter allowing n requests at a time
+func NewConcurrencyLimiter(n int) *ConcurrencyLimiter {
+	return &ConcurrencyLimiter{sem: make(chan struct{}, n)}
+}
+
+// Acquire waits until a request can be performed, or ctx is done. Release
+// must be called once the request has completed. A nil limiter does not
+// limit anything.
+func (l *ConcurrencyLimiter) Acquire(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+	select {
+	case l.sem <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Release marks a request started with Acquire as completed
+func (l *ConcurrencyLimiter) Release() {
+	if l == nil {
+		return
+	}
+	<-l.sem
+}
+
+// hold acquires a slot like Acquire, and returns a function releasing it
+// that can safely be called more than once
+func (l *ConcurrencyLimiter) hold(ctx context.Context) (func(), error) {
+	if err := l.Acquire(ctx); err != nil {
+		return nil, err
+	}
+	var once sync.Once
+	return func() { once.Do(l.Release) }, nil
+}
+
+// InFlight returns the number of requests currently in flight
+func (l *ConcurrencyLimiter) InFlight() int {
+	if l == nil {
+		return 0
+	}
+	return len(l.sem)
+}
+
+// RetryBudget allows retries as long as they stay under a fraction of the
+// requests performed during a time window, so retries cannot amplify an
+// outage by multiplying the load on the server.
+type RetryBudget struct {
+	// Ratio is the maximum fraction of requests that may be retries
+	Ratio float64
+	// MinRetries is the number of retries always allowed per window, so
+	// low-traffic clients can still retry
+	MinRetries int
+	// Window is the period over which requests and retries are counted,
+	// defaults to 10 seconds
+	Window time.Duration
+
+	lk       sync.Mutex
+	start    time.Time
+	requests int
+	retries  int
+}
+
+// NewRetryBudget returns a budget allowing retries for ratio of requests,
+// plus minRetries every 10 seconds
+func NewRetryBudget(ratio float64, minRetries int) *RetryBudget {
+	return &RetryBudget{Ratio: ratio, MinRetries: minRetries}
+}
+
+// rotate resets the counters if the window has elapsed, must be called
+// with the lock held
+func (b *RetryBudget) rotate() {
+	w := b.Window
+	if w <= 0 {
+		w = 10 * time.Second
+	}
+	if now := now(); now.Sub(b.start) > w {
+		b.start = now
+		b.requests = 0
+		b.retries = 0
+	}
+}
+
+// request records a request
+func (b *RetryBudget) request() {
+	if b == nil {
+		return
+	}
+	b.lk.Lock()
+	defer b.lk.Unlock()
+	b.rotate()
+	b.requests += 1
+}
+
+// allow records a retry and returns true if it fits in the budget
+func (b *RetryBudget) allow() bool {
+	if b == nil {
+		return true
+	}
+	b.lk.Lock()
+	defer b.lk.Unlock()
+	b.rotate()
+	if b.retries >= b.MinRetries && float64(b.retries) >= b.Ratio*float64(b.requests) {
+		return false
+	}
+	b.retries += 1
+	return true
+}
+
+// releaseBody releases a limiter once the response body is closed
+type releaseBody struct {
+	io.ReadCloser
+	l    *ConcurrencyLimiter
+	once sync.Once
+}
+
+func (b *releaseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.l.Release)
+	return err
+}
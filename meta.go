@@ -0,0 +1,91 @@
+package rest
+
+import (
+	"context"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ResponseMeta holds the information returned in headers, which is all HEAD
+// requests, and usually OPTIONS requests, return
+type ResponseMeta struct {
+	Status        int
+	Header        http.Header
+	Allow         []string  // allowed methods, from Allow or Access-Control-Allow-Methods
+	ContentType   string    // type of the content, if any
+	ContentLength int64     // length of the content, or -1 if unknown
+	ETag          string    // entity tag, if any
+	LastModified  time.Time // zero if unknown
+}
+
+// Head performs a HEAD request and returns the response headers
+func Head(ctx context.Context, path string, param any) (*ResponseMeta, error) {
+	return metaRequest(ctx, path, "HEAD", param)
+}
+
+// Options performs an OPTIONS request and returns the response headers,
+// notably the methods allowed on path
+func Options(ctx context.Context, path string, param any) (*ResponseMeta, error) {
+	return metaRequest(ctx, path, "OPTIONS", param)
+}
+
+func metaRequest(ctx context.Context, path, method string, param any) (*ResponseMeta, error) {
+	res, err := Do(ctx, path, method, param)
+	if err != nil {
+		return nil, err
+	}
+	if res.Meta == nil {
+		// the server returned data rather than only headers
+		return &ResponseMeta{ContentLength: -1}, nil
+	}
+	return res.Meta, nil
+}
+
+// headerOnly returns true if the response to method has no json body to
+// parse: always for HEAD, and for OPTIONS unless json is returned
+func headerOnly(method string, resp *http.Response) bool {
+	switch method {
+	case "HEAD":
+		return true
+	case "OPTIONS":
+		typ, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+		return typ != "application/json"
+	default:
+		return false
+	}
+}
+
+// metaResponse returns a response holding the headers of resp
+func metaResponse(resp *http.Response, info *RequestInfo) (*Response, error) {
+	// drain a possible body so the connection can be reused
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	info.BytesIn += int64(len(body))
+	if resp.StatusCode >= 400 {
+		return nil, newHttpError(resp, body, nil)
+	}
+
+	meta := &ResponseMeta{
+		Status:        resp.StatusCode,
+		Header:        resp.Header,
+		ContentType:   resp.Header.Get("Content-Type"),
+		ContentLength: resp.ContentLength,
+		ETag:          resp.Header.Get("ETag"),
+	}
+	for _, h := range []string{"Allow", "Access-Control-Allow-Methods"} {
+		if v := resp.Header.Get(h); v != "" {
+			for _, m := range strings.Split(v, ",") {
+				if m = strings.TrimSpace(m); m != "" {
+					meta.Allow = append(meta.Allow, strings.ToUpper(m))
+				}
+			}
+			break
+		}
+	}
+	if v := resp.Header.Get("Last-Modified"); v != "" {
+		meta.LastModified, _ = http.ParseTime(v)
+	}
+	return &Response{Result: "success", Meta: meta}, nil
+}
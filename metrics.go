@@ -0,0 +1,33 @@
+package rest
+
+import (
+	"context"
+	"time"
+)
+
+// MetricsCollector receives information about completed requests, both the
+// ones performed through Do and the ones proxied by the Router
+type MetricsCollector interface {
+	ObserveRequest(info *RequestInfo)
+}
+
+// RequestInfo describes a completed request
+type RequestInfo struct {
+	Method         string
+	Path           string
+	Status         int // HTTP status code, zero if no response was received
+	Duration       time.Duration
+	BytesIn        int64  // response body bytes, over all attempts
+	BytesOut       int64  // request body bytes, over all attempts
+	Proxied        bool   // request went through the Router
+	Attempts       int    // number of attempts, including retries
+	IdempotencyKey string // Idempotency-Key header sent, see DuplicateGuard
+	Err            error
+}
+
+// Metrics, if set, is notified of every completed request
+var Metrics MetricsCollector
+
+// OnError, if set, is called for every failed API call (Do, Apply and the
+// initial query of uploads) with info.Err set to the final error
+var OnError func(ctx context.Context, info *RequestInfo)
@@ -0,0 +1,85 @@
+package rest
+
+import (
+	"context"
+	"math/rand"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/KarpelesLab/pjson"
+)
+
+// MirrorConfig describes how API requests are mirrored to a secondary
+// backend, for example to validate a staging environment with production
+// shaped traffic. Mirrored requests are sent asynchronously with the same
+// parameters and token, and their responses are discarded. Only GET, HEAD
+// and OPTIONS requests are mirrored unless Mutating is set.
+type MirrorConfig struct {
+	Backend     *url.URL
+	Percent     float64 // share of requests to mirror, between 0 and 100
+	Mutating    bool    // also mirror other methods, which repeats their side effects on the mirror backend
+	MaxInFlight int     // mirrored requests running at once, others are skipped (defaults to 16)
+
+	// OnError, if set, is called when a mirrored request fails
+	OnError func(ctx context.Context, info *RequestInfo)
+
+	once sync.Once
+	sem  chan struct{}
+}
+
+// Mirror, if set, causes requests performed with Do and Apply to be mirrored
+var Mirror *MirrorConfig
+
+// mirror sends a copy of a request to the mirror backend, if selected
+func (m *MirrorConfig) mirror(ctx context.Context, path, method string, param any) {
+	if m == nil || m.Backend == nil || rand.Float64()*100 >= m.Percent {
+		return
+	}
+	if !m.Mutating {
+		switch method {
+		case "GET", "HEAD", "OPTIONS":
+		default:
+			return
+		}
+	}
+	// the caller may modify param once the call returns
+	data, err := pjson.MarshalContext(ctx, param)
+	if err != nil {
+		return
+	}
+	param = pjson.RawMessage(data)
+	m.once.Do(func() {
+		n := m.MaxInFlight
+		if n <= 0 {
+			n = 16
+		}
+		m.sem = make(chan struct{}, n)
+	})
+	select {
+	case m.sem <- struct{}{}:
+	default:
+		// too many mirrored requests running, skip this one
+		return
+	}
+
+	ctx = WithoutDebugLog(WithBackend(context.WithoutCancel(ctx), m.Backend))
+	go func() {
+		defer func() {
+			<-m.sem
+			// mirrored requests must never affect the caller
+			recover()
+		}()
+		ctx, cancel := withDefaultTimeout(ctx, DefaultTimeout)
+		defer cancel()
+
+		info := &RequestInfo{Method: method, Path: path, Attempts: 1}
+		start := time.Now()
+		_, err := doRequest(ctx, path, method, param, nil, info)
+		info.Duration = time.Since(start)
+		info.Err = err
+		if err != nil && m.OnError != nil {
+			m.OnError(ctx, info)
+		}
+	}()
+}
@@ -0,0 +1,78 @@
+package rest
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestMirror(t *testing.T) {
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"result":"success","data":"primary"}`))
+	}))
+	defer primary.Close()
+	mirrored := make(chan string, 1)
+	bodies := make(chan string, 1)
+	secondary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			b, _ := io.ReadAll(r.Body)
+			bodies <- string(b)
+		}
+		mirrored <- r.URL.Path
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer secondary.Close()
+
+	pu, _ := url.Parse(primary.URL)
+	su, _ := url.Parse(secondary.URL)
+	failed := make(chan *RequestInfo, 1)
+	Mirror = &MirrorConfig{Backend: su, Percent: 100, OnError: func(ctx context.Context, info *RequestInfo) { failed <- info }}
+	defer func() { Mirror = nil }()
+
+	ctx := context.WithValue(context.Background(), BackendURL, pu)
+	var s string
+	if err := Apply(ctx, "Misc/Debug:mirror", "GET", nil, &s); err != nil || s != "primary" {
+		t.Fatalf("unexpected response %q: %v", s, err)
+	}
+
+	select {
+	case p := <-mirrored:
+		if p != "/_special/rest/Misc/Debug:mirror" {
+			t.Errorf("unexpected mirrored path %s", p)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("request was not mirrored")
+	}
+	select {
+	case info := <-failed:
+		if info.Err == nil || info.Path != "Misc/Debug:mirror" {
+			t.Errorf("unexpected error report %+v", info)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("mirror error was not reported")
+	}
+
+	// other methods are only mirrored when enabled
+	Do(ctx, "Misc/Debug:mirror", "POST", nil)
+	select {
+	case <-mirrored:
+		t.Errorf("POST request was mirrored")
+	case <-time.After(100 * time.Millisecond):
+	}
+	Mirror.Mutating = true
+	p := Param{"a": 1}
+	Do(ctx, "Misc/Debug:mirror", "POST", p)
+	p["a"] = 2
+	select {
+	case <-mirrored:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("POST request was not mirrored")
+	}
+	if b := <-bodies; b != `{"a":1}` {
+		t.Errorf("unexpected mirrored body %s", b)
+	}
+}
@@ -0,0 +1,29 @@
+package rest
+
+import (
+	"fmt"
+	"runtime/debug"
+)
+
+// PanicError is returned when a callback (such as a progress function or a
+// request mutator) or a goroutine started by this package panicked, instead
+// of crashing the process
+type PanicError struct {
+	Value any    // value passed to panic
+	Stack []byte // stack trace of the goroutine that panicked
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("[rest] panic: %v", e.Value)
+}
+
+// Unwrap returns the value passed to panic if it was an error
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// newPanicError returns a *PanicError for a value returned by recover
+func newPanicError(r any) error {
+	return &PanicError{Value: r, Stack: debug.Stack()}
+}
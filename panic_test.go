@@ -0,0 +1,28 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestPanicRecovery(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"result":"success","data":null}`))
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+	ctx := context.WithValue(context.Background(), BackendURL, u)
+
+	errBoom := errors.New("boom")
+	ctx = WithRequestMutator(ctx, func(req *http.Request) { panic(errBoom) })
+
+	_, err := Do(ctx, "Test/obj", "GET", nil)
+	var perr *PanicError
+	if !errors.As(err, &perr) || !errors.Is(err, errBoom) || len(perr.Stack) == 0 {
+		t.Errorf("expected PanicError wrapping boom, got %v", err)
+	}
+}
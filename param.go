@@ -0,0 +1,144 @@
+package rest
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// ParamBuilder builds request parameters, checking required fields before
+// anything is sent to the server:
+//
+//	p, err := rest.NewParam().
+//		Set("Name", "test").
+//		SetPath("Meta.color", "red").
+//		AddToList("Tags", "a", "b").
+//		Require("Name").
+//		Build()
+type ParamBuilder struct {
+	p        Param
+	required []string
+	err      error
+}
+
+// NewParam returns a new empty ParamBuilder
+func NewParam() *ParamBuilder {
+	return &ParamBuilder{p: make(Param)}
+}
+
+// Set sets the top level parameter k to v
+func (b *ParamBuilder) Set(k string, v any) *ParamBuilder {
+	b.p[k] = v
+	return b
+}
+
+// SetPath sets the value at a dot separated path, creating intermediate
+// objects as needed. "a.b" sets b in the object a.
+func (b *ParamBuilder) SetPath(path string, v any) *ParamBuilder {
+	parent, k, err := b.parent(path)
+	if err != nil {
+		b.fail(err)
+		return b
+	}
+	parent[k] = v
+	return b
+}
+
+// AddToList appends values to the list at a dot separated path, creating it
+// if needed
+func (b *ParamBuilder) AddToList(path string, v ...any) *ParamBuilder {
+	parent, k, err := b.parent(path)
+	if err != nil {
+		b.fail(err)
+		return b
+	}
+	var list []any
+	if cur, found := parent[k]; found && cur != nil {
+		rv := reflect.ValueOf(cur)
+		if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+			b.fail(fmt.Errorf("parameter %s is a %T, not a list", path, cur))
+			return b
+		}
+		for i := 0; i < rv.Len(); i++ {
+			list = append(list, rv.Index(i).Interface())
+		}
+	}
+	parent[k] = append(list, v...)
+	return b
+}
+
+// Require marks the given dot separated paths as required. Build fails if
+// they are not set, or set to nil.
+func (b *ParamBuilder) Require(path ...string) *ParamBuilder {
+	b.required = append(b.required, path...)
+	return b
+}
+
+// Build returns the parameters, or an error if they are invalid. A
+// *ValidationError is returned if required parameters are missing.
+func (b *ParamBuilder) Build() (Param, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	verr := &ValidationError{}
+	for _, path := range b.required {
+		if v, found := b.lookup(path); !found || v == nil {
+			verr.Missing = append(verr.Missing, path)
+		}
+	}
+	if len(verr.Missing) > 0 {
+		return nil, verr
+	}
+	return b.p, nil
+}
+
+// fail records the first error encountered
+func (b *ParamBuilder) fail(err error) {
+	if b.err == nil {
+		b.err = err
+	}
+}
+
+// parent returns the object holding the value at path and its key in it,
+// creating intermediate objects as needed
+func (b *ParamBuilder) parent(path string) (map[string]any, string, error) {
+	keys := strings.Split(path, ".")
+	cur := map[string]any(b.p)
+	for i, k := range keys[:len(keys)-1] {
+		switch sub := cur[k].(type) {
+		case nil:
+			m := make(map[string]any)
+			cur[k] = m
+			cur = m
+		case map[string]any:
+			cur = sub
+		case Param:
+			cur = sub
+		default:
+			return nil, "", fmt.Errorf("parameter %s is a %T, not an object", strings.Join(keys[:i+1], "."), sub)
+		}
+	}
+	return cur, keys[len(keys)-1], nil
+}
+
+// lookup returns the value at a dot separated path
+func (b *ParamBuilder) lookup(path string) (any, bool) {
+	var cur any = map[string]any(b.p)
+	for _, k := range strings.Split(path, ".") {
+		var m map[string]any
+		switch sub := cur.(type) {
+		case map[string]any:
+			m = sub
+		case Param:
+			m = sub
+		default:
+			return nil, false
+		}
+		v, found := m[k]
+		if !found {
+			return nil, false
+		}
+		cur = v
+	}
+	return cur, true
+}
@@ -0,0 +1,37 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestParamBuilder(t *testing.T) {
+	p, err := NewParam().
+		Set("Name", "test").
+		SetPath("Meta.color", "red").
+		SetPath("Meta.size.width", 10).
+		Set("Tags", []string{"a"}).
+		AddToList("Tags", "b", "c").
+		AddToList("Meta.list", 1).
+		Require("Name", "Meta.color").
+		Build()
+	if err != nil {
+		t.Fatalf("Build failed: %s", err)
+	}
+	buf, _ := json.Marshal(p)
+	expect := `{"Meta":{"color":"red","list":[1],"size":{"width":10}},"Name":"test","Tags":["a","b","c"]}`
+	if string(buf) != expect {
+		t.Errorf("unexpected params %s", buf)
+	}
+
+	_, err = NewParam().Set("Name", nil).Require("Name", "Meta.color").Build()
+	var verr *ValidationError
+	if !errors.As(err, &verr) || len(verr.Missing) != 2 {
+		t.Errorf("expected missing parameters, got %v", err)
+	}
+
+	if _, err = NewParam().Set("Name", "x").SetPath("Name.sub", 1).Build(); err == nil {
+		t.Errorf("expected error setting a path under a string")
+	}
+}
@@ -0,0 +1,58 @@
+package rest
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+var (
+	// PingEndpoint is the endpoint called by Ping, which should be cheap
+	PingEndpoint = "Misc/Debug:serverTime"
+
+	// PingMethod is the method used by Ping, HEAD only checks the endpoint
+	// answers without fetching data
+	PingMethod = "GET"
+
+	// PingTimeout is the timeout of Ping when ctx has no deadline
+	PingTimeout = 5 * time.Second
+)
+
+// PingResult is the outcome of a successful Ping
+type PingResult struct {
+	Latency    time.Duration // time taken by the call
+	ServerTime time.Time     // time reported by the server, zero if unknown
+}
+
+// Ping performs a call to PingEndpoint with the token of ctx, if any, and
+// returns its latency and the server time. It bypasses the response cache
+// and retries, and fails once Shutdown was called, so it can be used as a
+// readiness probe for services depending on the API.
+func Ping(ctx context.Context) (*PingResult, error) {
+	ctx, done, err := trackCall(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+	ctx, cancel := withDefaultTimeout(ctx, PingTimeout)
+	defer cancel()
+
+	info := &RequestInfo{Method: PingMethod, Path: PingEndpoint, Attempts: 1}
+	start := now()
+	res, err := doRequest(WithoutDebugLog(ctx), PingEndpoint, PingMethod, nil, nil, info)
+	if err != nil {
+		return nil, err
+	}
+	pr := &PingResult{Latency: now().Sub(start)}
+	if t, ok := res.ServerTime(); ok {
+		pr.ServerTime = t.Time
+	} else if res.Meta != nil {
+		pr.ServerTime, _ = http.ParseTime(res.Meta.Header.Get("Date"))
+	}
+	return pr, nil
+}
+
+// Ping performs a Ping with c
+func (c *Client) Ping(ctx context.Context) (*PingResult, error) {
+	return Ping(c.Use(ctx))
+}
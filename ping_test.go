@@ -0,0 +1,44 @@
+package rest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestPing(t *testing.T) {
+	defer func() {
+		serverClock.offset, serverClock.samples = 0, 0
+	}()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "HEAD" {
+			w.Header().Set("Date", "Mon, 02 Jan 2006 15:04:05 GMT")
+			return
+		}
+		w.Write([]byte(`{"result":"success","data":null,"time":{"unix":1700000000,"us":0}}`))
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+	ctx := context.WithValue(context.Background(), BackendURL, u)
+
+	res, err := Ping(ctx)
+	if err != nil {
+		t.Fatalf("ping failed: %s", err)
+	}
+	if res.ServerTime.Unix() != 1700000000 || res.Latency <= 0 {
+		t.Errorf("unexpected ping result %+v", res)
+	}
+
+	PingMethod = "HEAD"
+	defer func() { PingMethod = "GET" }()
+	res, err = Ping(ctx)
+	if err != nil {
+		t.Fatalf("ping failed: %s", err)
+	}
+	if !res.ServerTime.Equal(time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)) {
+		t.Errorf("unexpected server time %s", res.ServerTime)
+	}
+}
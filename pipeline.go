@@ -0,0 +1,211 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrPipelineCycle is returned when the steps of a Pipeline depend on each
+// other in a loop
+var ErrPipelineCycle = errors.New("pipeline steps have a dependency cycle")
+
+// Pipeline runs a set of dependent calls, each step starting as soon as the
+// steps it depends on have completed. For example to call A, then B and C
+// with the output of A, then D:
+//
+//	var p rest.Pipeline
+//	p.Call("a", nil, func(*rest.PipelineResults) (string, string, any) { return "A", "GET", nil })
+//	p.Step("b", []string{"a"}, func(ctx context.Context, res *rest.PipelineResults) (*rest.Response, error) { ... })
+//	p.Step("c", []string{"a"}, ...)
+//	p.Step("d", []string{"b", "c"}, ...)
+//	res, err := p.Run(ctx)
+//
+// The first failing step cancels the context of all the other steps.
+type Pipeline struct {
+	steps []*PipelineStep
+}
+
+// PipelineStep is a step of a Pipeline
+type PipelineStep struct {
+	Name  string
+	After []string // steps that must complete before this one starts
+	Run   func(ctx context.Context, res *PipelineResults) (*Response, error)
+
+	// Policy, if set, sets the timeout of the step and how it is retried
+	// when failing with a temporary error. Only Timeout, Retries and
+	// RetryDelay are used. The step is retried whatever the method of the
+	// calls it performs.
+	Policy *EndpointPolicy
+}
+
+// PipelineStepError is returned by Pipeline.Run when a step fails
+type PipelineStepError struct {
+	Step string
+	Err  error
+}
+
+func (e *PipelineStepError) Error() string {
+	return fmt.Sprintf("pipeline step %s failed: %s", e.Step, e.Err)
+}
+
+func (e *PipelineStepError) Unwrap() error {
+	return e.Err
+}
+
+// PipelineResults holds the responses of the completed steps of a Pipeline
+type PipelineResults struct {
+	lk  sync.RWMutex
+	res map[string]*Response
+}
+
+// Get returns the response of the given step, or nil if it has not
+// completed. Steps can access the results of the steps they depend on.
+func (r *PipelineResults) Get(name string) *Response {
+	r.lk.RLock()
+	defer r.lk.RUnlock()
+	return r.res[name]
+}
+
+// Apply decodes the data returned by the given step into v
+func (r *PipelineResults) Apply(name string, v any) error {
+	res := r.Get(name)
+	if res == nil {
+		return fmt.Errorf("pipeline step %s has no result", name)
+	}
+	return res.Apply(v)
+}
+
+func (r *PipelineResults) set(name string, res *Response) {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+	r.res[name] = res
+}
+
+// Step adds a step running f once the steps in after have completed
+func (p *Pipeline) Step(name string, after []string, f func(ctx context.Context, res *PipelineResults) (*Response, error)) *PipelineStep {
+	s := &PipelineStep{Name: name, After: after, Run: f}
+	p.steps = append(p.steps, s)
+	return s
+}
+
+// Call adds a step performing the request returned by req once the steps in
+// after have completed
+func (p *Pipeline) Call(name string, after []string, req func(res *PipelineResults) (path, method string, param any)) *PipelineStep {
+	return p.Step(name, after, func(ctx context.Context, res *PipelineResults) (*Response, error) {
+		path, method, param := req(res)
+		return Do(ctx, path, method, param)
+	})
+}
+
+// Run runs all the steps of the pipeline and returns their results. If a
+// step fails, the remaining steps are cancelled and a *PipelineStepError is
+// returned along with the results of the steps that completed.
+func (p *Pipeline) Run(ctx context.Context) (*PipelineResults, error) {
+	if err := p.check(); err != nil {
+		return nil, err
+	}
+	parent := ctx
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	res := &PipelineResults{res: make(map[string]*Response)}
+	done := make(map[string]chan struct{})
+	for _, s := range p.steps {
+		done[s.Name] = make(chan struct{})
+	}
+
+	var (
+		once     sync.Once
+		firstErr error
+		wg       sync.WaitGroup
+	)
+	for _, s := range p.steps {
+		wg.Add(1)
+		go func(s *PipelineStep) {
+			defer wg.Done()
+			defer close(done[s.Name])
+			for _, dep := range s.After {
+				select {
+				case <-done[dep]:
+				case <-ctx.Done():
+					return
+				}
+			}
+			if ctx.Err() != nil {
+				// a step failed, or the pipeline was cancelled
+				return
+			}
+			r, err := s.run(ctx, res)
+			if err != nil {
+				once.Do(func() {
+					firstErr = &PipelineStepError{Step: s.Name, Err: err}
+					cancel()
+				})
+				return
+			}
+			res.set(s.Name, r)
+		}(s)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return res, firstErr
+	}
+	return res, parent.Err()
+}
+
+// run runs the step as allowed by its policy
+func (s *PipelineStep) run(ctx context.Context, res *PipelineResults) (*Response, error) {
+	if t := s.Policy.timeout(0); t > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, t)
+		defer cancel()
+	}
+	return s.Policy.retryTemporary(ctx, "pipeline step "+s.Name, func() (*Response, error) {
+		return s.Run(ctx, res)
+	})
+}
+
+// check makes sure step names are unique, and that dependencies exist and
+// do not form a cycle
+func (p *Pipeline) check() error {
+	steps := make(map[string]*PipelineStep)
+	for _, s := range p.steps {
+		if _, found := steps[s.Name]; found {
+			return fmt.Errorf("duplicate pipeline step %s", s.Name)
+		}
+		steps[s.Name] = s
+	}
+
+	// depth first search, 1 = visiting, 2 = visited
+	state := make(map[string]int)
+	var visit func(s *PipelineStep) error
+	visit = func(s *PipelineStep) error {
+		switch state[s.Name] {
+		case 1:
+			return fmt.Errorf("%w: %s", ErrPipelineCycle, s.Name)
+		case 2:
+			return nil
+		}
+		state[s.Name] = 1
+		for _, dep := range s.After {
+			d, ok := steps[dep]
+			if !ok {
+				return fmt.Errorf("pipeline step %s depends on unknown step %s", s.Name, dep)
+			}
+			if err := visit(d); err != nil {
+				return err
+			}
+		}
+		state[s.Name] = 2
+		return nil
+	}
+	for _, s := range p.steps {
+		if err := visit(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestPipeline(t *testing.T) {
+	var bCalls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch strings.TrimPrefix(r.URL.Path, "/_special/rest/") {
+		case "A":
+			w.Write([]byte(`{"result":"success","data":{"id":"42"}}`))
+		case "B/42":
+			if bCalls.Add(1) == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.Write([]byte(`{"result":"success","data":"b"}`))
+		case "C/42":
+			w.Write([]byte(`{"result":"success","data":"c"}`))
+		default:
+			w.Write([]byte(`{"result":"error","error":"not found","code":404}`))
+		}
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+	ctx := context.WithValue(context.Background(), BackendURL, u)
+
+	id := func(res *PipelineResults) string {
+		var a struct{ Id string }
+		res.Apply("a", &a)
+		return a.Id
+	}
+
+	var p Pipeline
+	p.Call("a", nil, func(*PipelineResults) (string, string, any) { return "A", "GET", nil })
+	p.Call("b", []string{"a"}, func(res *PipelineResults) (string, string, any) { return "B/" + id(res), "POST", nil }).Policy = &EndpointPolicy{Retries: 1, RetryDelay: 1}
+	p.Call("c", []string{"a"}, func(res *PipelineResults) (string, string, any) { return "C/" + id(res), "GET", nil })
+	p.Step("d", []string{"b", "c"}, func(ctx context.Context, res *PipelineResults) (*Response, error) {
+		var b, c string
+		res.Apply("b", &b)
+		res.Apply("c", &c)
+		return &Response{Data: []byte(`"` + b + c + `"`)}, nil
+	})
+
+	res, err := p.Run(ctx)
+	if err != nil {
+		t.Fatalf("pipeline failed: %s", err)
+	}
+	var d string
+	if err := res.Apply("d", &d); err != nil || d != "bc" {
+		t.Errorf("unexpected result %q: %v", d, err)
+	}
+
+	// a failing step cancels the steps depending on it
+	var q Pipeline
+	q.Call("a", nil, func(*PipelineResults) (string, string, any) { return "Missing", "GET", nil })
+	q.Step("b", []string{"a"}, func(ctx context.Context, res *PipelineResults) (*Response, error) {
+		t.Errorf("step b should not run")
+		return nil, nil
+	})
+	res, err = q.Run(ctx)
+	var stepErr *PipelineStepError
+	if !errors.As(err, &stepErr) || stepErr.Step != "a" {
+		t.Errorf("unexpected error %v", err)
+	}
+	if res.Get("b") != nil {
+		t.Errorf("step b has a result")
+	}
+
+	var r Pipeline
+	r.Step("a", []string{"b"}, nil)
+	r.Step("b", []string{"a"}, nil)
+	if _, err := r.Run(ctx); !errors.Is(err, ErrPipelineCycle) {
+		t.Errorf("expected cycle error, got %v", err)
+	}
+}
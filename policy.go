@@ -0,0 +1,181 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EndpointPolicy overrides the behavior of calls to some endpoints, see
+// SetPolicy. Zero values keep the default behavior.
+type EndpointPolicy struct {
+	// Timeout replaces DefaultTimeout, or DefaultUploadTimeout for uploads,
+	// for calls made with a context that has no deadline
+	Timeout time.Duration
+	// Retries is the number of times calls failing with a network error or
+	// a temporary server error are retried. Only GET, HEAD, OPTIONS, PUT and
+	// DELETE calls are retried. Rate limited calls are left to the
+	// RateLimitPolicy when there is one. For uploads, it is used when
+	// UploadInfo.Retries is not set.
+	Retries int
+	// RetryDelay is the delay before the first retry, doubled on each retry,
+	// defaults to DefaultUploadRetryDelay
+	RetryDelay time.Duration
+	// CacheTTL replaces the TTL of Cache for GET calls, a negative value
+	// disables caching
+	CacheTTL time.Duration
+	// RateLimit is the maximum number of requests per second, shared by all
+	// the endpoints the policy applies to
+	RateLimit float64
+
+	lk   sync.Mutex
+	next time.Time // next time a request can be sent under RateLimit
+}
+
+var (
+	policiesLk sync.RWMutex
+	policies   map[string]*EndpointPolicy
+)
+
+// SetPolicy sets the policy applied to endpoints whose path starts with
+// prefix. The policy with the longest matching prefix is used. A nil policy
+// removes the policy for prefix.
+func SetPolicy(prefix string, p *EndpointPolicy) {
+	policiesLk.Lock()
+	defer policiesLk.Unlock()
+	if p == nil {
+		delete(policies, prefix)
+		return
+	}
+	if policies == nil {
+		policies = make(map[string]*EndpointPolicy)
+	}
+	policies[prefix] = p
+}
+
+// policyFor returns the policy applying to path, or nil
+func policyFor(path string) *EndpointPolicy {
+	policiesLk.RLock()
+	defer policiesLk.RUnlock()
+
+	var res *EndpointPolicy
+	best := -1
+	for prefix, p := range policies {
+		if len(prefix) > best && strings.HasPrefix(path, prefix) {
+			res, best = p, len(prefix)
+		}
+	}
+	return res
+}
+
+// timeout returns the timeout for calls under the policy, or def
+func (p *EndpointPolicy) timeout(def time.Duration) time.Duration {
+	if p == nil || p.Timeout == 0 {
+		return def
+	}
+	return p.Timeout
+}
+
+// wait waits until a request can be sent under RateLimit, or ctx is done
+func (p *EndpointPolicy) wait(ctx context.Context) error {
+	if p == nil || p.RateLimit <= 0 {
+		return nil
+	}
+	p.lk.Lock()
+	t := now()
+	if p.next.After(t) {
+		t = p.next
+	}
+	p.next = t.Add(time.Duration(float64(time.Second) / p.RateLimit))
+	p.lk.Unlock()
+
+	if d := t.Sub(now()); d > 0 {
+		return sleep(ctx, d)
+	}
+	return nil
+}
+
+// retry runs f, retrying it as allowed by the policy for method
+func (p *EndpointPolicy) retry(ctx context.Context, method, path string, f func() (*Response, error)) (*Response, error) {
+	if p == nil || p.Retries <= 0 {
+		return f()
+	}
+	switch method {
+	case "GET", "HEAD", "OPTIONS", "PUT", "DELETE":
+	default:
+		return f()
+	}
+	return p.retryTemporary(ctx, method+" "+path, f)
+}
+
+// retryTemporary runs f, retrying it on temporary errors regardless of the
+// method. name describes the operation in logs.
+func (p *EndpointPolicy) retryTemporary(ctx context.Context, name string, f func() (*Response, error)) (*Response, error) {
+	if p == nil || p.Retries <= 0 {
+		return f()
+	}
+	delay := p.RetryDelay
+	if delay <= 0 {
+		delay = DefaultUploadRetryDelay
+	}
+	for attempt := 0; ; attempt++ {
+		res, err := f()
+		if err == nil || attempt >= p.Retries || ctx.Err() != nil || !isTemporary(err) {
+			return res, err
+		}
+		if errors.Is(err, ErrRateLimited) && contextClient(ctx).rateLimit().enabled() {
+			// waited for and retried by the rate limit policy
+			return res, err
+		}
+		if !Retries.allow() {
+			return nil, fmt.Errorf("%w: %w", ErrRetryBudgetExhausted, err)
+		}
+		if debugEnabled(ctx) {
+			slog.DebugContext(ctx, fmt.Sprintf("[rest] %s failed, retrying in %s: %s", name, delay, Redact(err.Error())), "event", "rest:retry")
+		}
+		if sleep(ctx, retryDelay(err, delay)) != nil {
+			return res, err
+		}
+		delay *= 2
+	}
+}
+
+// isTemporary returns true for errors that may not happen again on retry
+func isTemporary(err error) bool {
+	if errors.Is(err, ErrQuotaExceeded) {
+		// will not succeed before the quota resets
+		return false
+	}
+	var httpErr *HttpError
+	if errors.As(err, &httpErr) {
+		return httpErr.IsTemporary()
+	}
+	var apiErr *Error
+	if errors.As(err, &apiErr) && apiErr.Response != nil {
+		return apiErr.IsTemporary()
+	}
+	if errors.Is(err, ErrRateLimited) || errors.Is(err, ErrTimeout) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// retryDelay returns how long to wait before retrying after err: delay, or
+// the delay requested by the server if longer
+func retryDelay(err error, delay time.Duration) time.Duration {
+	var httpErr *HttpError
+	if errors.As(err, &httpErr) && httpErr.RetryAfter > delay {
+		return httpErr.RetryAfter
+	}
+	var apiErr *Error
+	if errors.As(err, &apiErr) && apiErr.RetryAfter > delay {
+		return apiErr.RetryAfter
+	}
+	return delay
+}
@@ -0,0 +1,49 @@
+package rest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestEndpointPolicy(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if hits.Add(1)%3 != 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`unavailable`))
+			return
+		}
+		w.Write([]byte(`{"result":"success","data":null}`))
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+	ctx := context.WithValue(context.Background(), BackendURL, u)
+
+	SetPolicy("Heavy", &EndpointPolicy{Retries: 2, RetryDelay: time.Millisecond, CacheTTL: time.Minute})
+	defer SetPolicy("Heavy", nil)
+	Cache = NewResponseCache(0)
+	defer func() { Cache = nil }()
+
+	if _, err := Do(ctx, "Heavy/obj", "GET", nil); err != nil {
+		t.Fatalf("Do failed: %s", err)
+	}
+	if n := hits.Load(); n != 3 {
+		t.Errorf("expected 3 requests, got %d", n)
+	}
+	// cached
+	if _, err := Do(ctx, "Heavy/obj", "GET", nil); err != nil {
+		t.Fatalf("Do failed: %s", err)
+	}
+	if n := hits.Load(); n != 3 {
+		t.Errorf("expected response to be cached, got %d requests", n)
+	}
+	// no policy, no retries
+	if _, err := Do(ctx, "Light/obj", "GET", nil); err == nil {
+		t.Errorf("expected error without retries")
+	}
+}
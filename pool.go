@@ -0,0 +1,40 @@
+package rest
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+// maxPooledBufferSize is the largest buffer kept in bufPool, larger buffers
+// are left to the garbage collector so one big response doesn't pin memory
+const maxPooledBufferSize = 1024 * 1024
+
+var bufPool = sync.Pool{
+	New: func() any {
+		return new(bytes.Buffer)
+	},
+}
+
+func getBuffer() *bytes.Buffer {
+	return bufPool.Get().(*bytes.Buffer)
+}
+
+func putBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > maxPooledBufferSize {
+		return
+	}
+	buf.Reset()
+	bufPool.Put(buf)
+}
+
+// readBody reads r into a pooled buffer, which must be released with
+// putBuffer once its contents are not needed anymore
+func readBody(r io.Reader, size int64) (*bytes.Buffer, error) {
+	buf := getBuffer()
+	if size > 0 && size <= maxPooledBufferSize {
+		buf.Grow(int(size))
+	}
+	_, err := buf.ReadFrom(r)
+	return buf, err
+}
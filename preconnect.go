@@ -0,0 +1,51 @@
+package rest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Preconnect opens a connection to the backend ahead of time, so that DNS
+// resolution and TCP/TLS handshakes do not delay the first request. The
+// connection is kept in the idle pool of the http client of ctx.
+func Preconnect(ctx context.Context) error {
+	backend := backendURL(ctx)
+	u := &url.URL{Scheme: backend.Scheme, Host: backend.Host, Path: "/"}
+	req, err := http.NewRequestWithContext(ctx, "HEAD", u.String(), nil)
+	if err != nil {
+		return err
+	}
+	mutateRequest(ctx, req)
+
+	resp, err := httpClient(ctx).Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %w", backend.Host, err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	return nil
+}
+
+// KeepWarm calls Preconnect every interval until ctx is cancelled, so that a
+// connection to the backend stays available. interval should be shorter than
+// the IdleConnTimeout of the transport.
+func KeepWarm(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		if err := Preconnect(ctx); err != nil && debugEnabled(ctx) {
+			slog.DebugContext(ctx, fmt.Sprintf("[rest] preconnect failed: %s", err), "event", "rest:preconnect_fail")
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package rest
+
+import (
+	"bytes"
+	"strconv"
+
+	"github.com/KarpelesLab/pjson"
+)
+
+// Price is a price value as returned by the API
+type Price struct {
+	Value    string `json:"value"`               // decimal value, for example "12.34000"
+	ValueInt string `json:"value_int,omitempty"` // value multiplied by Unit
+	Unit     string `json:"unit,omitempty"`
+	Currency string `json:"currency,omitempty"`
+	Display  string `json:"display,omitempty"` // formatted value, for example "$12.34"
+	HasVat   bool   `json:"has_vat,omitempty"`
+}
+
+type priceInternal Price
+
+func (p *Price) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	switch {
+	case string(data) == "null":
+		return nil
+	case len(data) > 0 && data[0] == '{':
+		return pjson.Unmarshal(data, (*priceInternal)(p))
+	case len(data) > 0 && data[0] == '"':
+		// plain value as string
+		return pjson.Unmarshal(data, &p.Value)
+	default:
+		// plain numeric value
+		p.Value = string(data)
+		return nil
+	}
+}
+
+// Float64 returns the value of the price as a float
+func (p Price) Float64() float64 {
+	v, _ := strconv.ParseFloat(p.Value, 64)
+	return v
+}
+
+func (p Price) String() string {
+	if p.Display != "" {
+		return p.Display
+	}
+	if p.Currency != "" {
+		return p.Value + " " + p.Currency
+	}
+	return p.Value
+}
@@ -0,0 +1,68 @@
+package rest
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"strconv"
+)
+
+// UploadProgressEvent describes the progress of an upload
+type UploadProgressEvent struct {
+	ID    string // identifies the upload, see UploadInfo.ID
+	Part  int    // part that progressed, zero if the file is sent at once
+	Sent  int64  // bytes sent so far
+	Total int64  // total size, or -1 if unknown
+}
+
+// UploadProgressListener is called with each progress event of uploads, see
+// WithProgressListener
+type UploadProgressListener func(ev *UploadProgressEvent)
+
+// WithProgressListener returns a context in which uploads and mail sending
+// report their progress to l. Listeners set in parent contexts, including
+// with WithProgress, keep being called, so code wrapping uploads can watch
+// them without replacing the application's callbacks.
+func WithProgressListener(ctx context.Context, l UploadProgressListener) context.Context {
+	parent := progressListeners(ctx)
+	listeners := make([]UploadProgressListener, len(parent), len(parent)+1)
+	copy(listeners, parent)
+	return context.WithValue(ctx, ProgressListeners, append(listeners, l))
+}
+
+// progressListeners returns the progress listeners of ctx, including a
+// callback set directly as UploadProgress
+func progressListeners(ctx context.Context) []UploadProgressListener {
+	listeners, _ := ctx.Value(ProgressListeners).([]UploadProgressListener)
+	if f := getUploadProgress(ctx); f != nil {
+		return append(listeners[:len(listeners):len(listeners)], func(ev *UploadProgressEvent) { f(ev.Sent, ev.Total) })
+	}
+	return listeners
+}
+
+// newUploadID returns a random UUID identifying an upload that was not
+// given an ID
+func newUploadID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40 // version 4
+	b[8] = b[8]&0x3f | 0x80 // variant
+	h := hex.EncodeToString(b[:])
+	return h[:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:]
+}
+
+// shortID returns the beginning of the upload ID, for messages
+func (u *UploadInfo) shortID() string {
+	if len(u.ID) > 8 {
+		return u.ID[:8]
+	}
+	return u.ID
+}
+
+// partID returns an ID for a part of the upload, unique across uploads
+func (u *UploadInfo) partID(part int) string {
+	if part == 0 {
+		return u.ID
+	}
+	return u.ID + "/" + strconv.Itoa(part)
+}
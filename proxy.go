@@ -1,40 +1,158 @@
-//go:build !wasm
+//go:build !wasm && !tinygo
 
 package rest
 
 import (
+	"errors"
+	"io"
+	"log/slog"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
+	"strings"
+
+	"github.com/KarpelesLab/pjson"
 )
 
+// ProxyHeaderPolicy controls which headers of inbound requests are passed to
+// the backend by SystemProxy
+type ProxyHeaderPolicy struct {
+	// Allow, if not empty, lists the only headers that will be forwarded.
+	// Note that this also applies to headers set by the Router, such as
+	// Authorization.
+	Allow []string
+	// Strip lists headers that are removed from forwarded requests
+	Strip []string
+	// Set lists headers that are added to forwarded requests, replacing any
+	// existing value
+	Set http.Header
+}
+
 var (
 	SystemProxy = &httputil.ReverseProxy{
-		Director:  systemProxyDirector,
-		Transport: RestHttpClient.Transport,
+		Director:     systemProxyDirector,
+		Transport:    proxyTransport{},
+		ErrorHandler: ProxyErrorHandler,
+	}
+
+	// FailoverURL, if set, is the backend idempotent requests are sent to
+	// when the primary backend cannot be reached or answers 502/503
+	FailoverURL *url.URL
+
+	// ProxyHeaders is the header policy applied by SystemProxy. By default
+	// cookies are not forwarded, and compression is left to the transport.
+	ProxyHeaders = &ProxyHeaderPolicy{
+		Strip: []string{"Cookie", "Accept-Encoding"},
 	}
 )
 
 func systemProxyDirector(req *http.Request) {
-	if bk, ok := req.Context().Value(BackendURL).(*url.URL); ok && bk != nil {
-		req.URL.Scheme = bk.Scheme
-		req.URL.Host = bk.Host
-	} else {
-		req.URL.Scheme = Scheme
-		req.URL.Host = Host
+	bk := backendURL(req.Context())
+	req.URL.Scheme = bk.Scheme
+	req.URL.Host = bk.Host
+	if ProxyHeaders != nil {
+		ProxyHeaders.filter(req.Header)
 	}
+
 	//req.Host = Host
 	req.Header.Set("Host", req.URL.Host)
 	req.Header.Set("Sec-Rest-Http", "true")
-	req.Header.Del("Accept-Encoding")
 
 	if _, ok := req.Header["User-Agent"]; !ok {
 		// explicitly disable User-Agent so it's not set to default value
 		req.Header.Set("User-Agent", "")
 	}
-	if _, ok := req.Header["Cookie"]; ok {
-		req.Header.Del("Cookie")
+	if ProxyHeaders != nil {
+		for k, v := range ProxyHeaders.Set {
+			req.Header[http.CanonicalHeaderKey(k)] = v
+		}
 	}
 	// let context alter request as needed
-	req.Context().Value(req)
+	mutateRequest(req.Context(), req)
+}
+
+// filter removes headers from hdr according to the policy
+func (p *ProxyHeaderPolicy) filter(hdr http.Header) {
+	if len(p.Allow) > 0 {
+		allow := make(map[string]bool)
+		for _, k := range p.Allow {
+			allow[http.CanonicalHeaderKey(k)] = true
+		}
+		for k := range hdr {
+			if !allow[k] {
+				delete(hdr, k)
+			}
+		}
+	}
+	for _, k := range p.Strip {
+		hdr.Del(k)
+	}
+}
+
+// proxyTransport performs proxied requests using RestHttpClient's transport,
+// retrying on FailoverURL when possible
+type proxyTransport struct{}
+
+func (proxyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := RestHttpClient.Transport.RoundTrip(req)
+	if FailoverURL == nil || !canFailover(req) {
+		return resp, err
+	}
+	if err == nil {
+		if resp.StatusCode != http.StatusBadGateway && resp.StatusCode != http.StatusServiceUnavailable {
+			return resp, nil
+		}
+		// discard primary response
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+	if Debug {
+		slog.DebugContext(req.Context(), "[rest] primary backend failed, retrying on failover", "event", "rest:proxy_failover", "rest:request", req.URL.Path)
+	}
+
+	req = req.Clone(req.Context())
+	req.URL.Scheme = FailoverURL.Scheme
+	req.URL.Host = FailoverURL.Host
+	req.Header.Set("Host", req.URL.Host)
+	return RestHttpClient.Transport.RoundTrip(req)
+}
+
+// canFailover returns true if req can safely be sent a second time
+func canFailover(req *http.Request) bool {
+	switch req.Method {
+	case "GET", "HEAD", "OPTIONS":
+		return req.Body == nil || req.Body == http.NoBody
+	default:
+		return false
+	}
+}
+
+// ProxyErrorHandler is the default SystemProxy error handler, answering with
+// a rest error response. Details of err are only logged, as they may reveal
+// internal addresses to clients.
+func ProxyErrorHandler(w http.ResponseWriter, req *http.Request, err error) {
+	if Debug {
+		slog.ErrorContext(req.Context(), "[rest] proxy error: "+err.Error(), "event", "rest:proxy_error", "rest:request", req.URL.Path)
+	}
+	code := http.StatusBadGateway
+	var mbe *http.MaxBytesError
+	if errors.As(err, &mbe) {
+		code = http.StatusRequestEntityTooLarge
+	}
+	writeErrorResponse(w, code)
+}
+
+// writeErrorResponse sends a generic rest error response for code to the
+// client
+func writeErrorResponse(w http.ResponseWriter, code int) {
+	res := &Response{
+		Result: "error",
+		Error:  strings.ToLower(http.StatusText(code)),
+		Code:   code,
+	}
+	buf, _ := pjson.Marshal(res)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(buf)
 }
@@ -0,0 +1,116 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// QuotaEndpoint is the endpoint returning the usage and quotas of the
+// current account, see Usage
+var QuotaEndpoint = "Quota"
+
+// ErrQuotaExceeded is matched by errors returned when a usage quota is
+// exhausted
+var ErrQuotaExceeded = errors.New("quota exceeded")
+
+// Quota is the usage of a resource with a limit
+type Quota struct {
+	Resource string
+	Limit    int64
+	Used     int64
+	Reset    Time // when usage is reset, zero if never
+}
+
+// Remaining returns how much of the quota is left
+func (q *Quota) Remaining() int64 {
+	return max(q.Limit-q.Used, 0)
+}
+
+// Usage returns the quotas of the current account and their usage
+func Usage(ctx context.Context) ([]*Quota, error) {
+	return As[[]*Quota](ctx, QuotaEndpoint, "GET", nil)
+}
+
+// QuotaExceededError is returned when a call fails because a usage quota is
+// exhausted. It matches ErrQuotaExceeded and the underlying *Error with
+// errors.Is and errors.As.
+type QuotaExceededError struct {
+	Resource string    // name of the quota, if reported
+	Limit    int64     // zero if unknown
+	Reset    time.Time // when the quota resets, zero if unknown
+	Err      *Error
+}
+
+func (e *QuotaExceededError) Error() string {
+	msg := "quota exceeded"
+	if e.Resource != "" {
+		msg += " for " + e.Resource
+	}
+	if e.Limit > 0 {
+		msg += fmt.Sprintf(" (limit %d)", e.Limit)
+	}
+	if !e.Reset.IsZero() {
+		msg += ", resets at " + e.Reset.Format(time.RFC3339)
+	}
+	return msg + ": " + e.Err.Response.Error
+}
+
+func (e *QuotaExceededError) Unwrap() []error {
+	return []error{ErrQuotaExceeded, e.Err}
+}
+
+// Wait waits until the quota resets, or ctx is done. It returns immediately
+// if the reset time is unknown.
+func (e *QuotaExceededError) Wait(ctx context.Context) error {
+	if e.Reset.IsZero() {
+		return nil
+	}
+	return sleep(ctx, e.Reset.Sub(now()))
+}
+
+// responseError returns the error for an error response, a
+// *QuotaExceededError if it is about an exhausted quota
+func responseError(resp *http.Response, result *Response) error {
+	e := &Error{Response: result}
+	if isRateLimited(result) {
+		e.RetryAfter = newHttpError(resp, nil, nil).RetryAfter
+		if result.Code != 429 {
+			e.parent = ErrRateLimited
+		}
+	}
+	if !isQuotaError(resp, result) {
+		return e
+	}
+
+	qe := &QuotaExceededError{Err: e}
+	if v, err := result.Get("Resource"); err == nil {
+		qe.Resource, _ = v.(string)
+	}
+	if n, ok := responseInt(result, "Limit"); ok {
+		qe.Limit = n
+	} else if n, err := strconv.ParseInt(resp.Header.Get("X-Quota-Limit"), 10, 64); err == nil {
+		qe.Limit = n
+	}
+	if t, err := result.GetTime("Reset"); err == nil && !t.IsZero() {
+		qe.Reset = t.Time
+	} else if n, err := strconv.ParseInt(resp.Header.Get("X-Quota-Reset"), 10, 64); err == nil {
+		qe.Reset = time.Unix(n, 0)
+	} else if e.RetryAfter > 0 {
+		qe.Reset = now().Add(e.RetryAfter)
+	}
+	return qe
+}
+
+// isQuotaError returns true if the error response is about an exhausted
+// quota
+func isQuotaError(resp *http.Response, r *Response) bool {
+	if strings.Contains(strings.ToLower(r.Token), "quota") || strings.Contains(strings.ToLower(r.Extra), "quota") {
+		return true
+	}
+	return r.Code == 429 && resp.Header.Get("X-Quota-Limit") != ""
+}
@@ -0,0 +1,61 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestQuota(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/_special/rest/Quota":
+			w.Write([]byte(`{"result":"success","data":[{"Resource":"storage","Limit":100,"Used":120,"Reset":{"unix":1700000000,"us":0}}]}`))
+		case "/_special/rest/Batch":
+			w.Write([]byte(`{"result":"error","error":"too many calls","code":429,"token":"error_quota_exceeded","data":{"Resource":"calls","Limit":1000,"Reset":1700000000}}`))
+		case "/_special/rest/Header":
+			w.Header().Set("X-Quota-Limit", "50")
+			w.Header().Set("Retry-After", "30")
+			w.Write([]byte(`{"result":"error","error":"slow down","code":429}`))
+		default:
+			w.Write([]byte(`{"result":"error","error":"not found","code":404}`))
+		}
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+	ctx := context.WithValue(context.Background(), BackendURL, u)
+
+	quotas, err := Usage(ctx)
+	if err != nil {
+		t.Fatalf("usage failed: %s", err)
+	}
+	if len(quotas) != 1 || quotas[0].Resource != "storage" || quotas[0].Remaining() != 0 || quotas[0].Reset.Unix() != 1700000000 {
+		t.Errorf("unexpected quotas %+v", quotas)
+	}
+
+	_, err = Do(ctx, "Batch", "POST", nil)
+	var qe *QuotaExceededError
+	if !errors.As(err, &qe) || !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("expected quota error, got %v", err)
+	}
+	if qe.Resource != "calls" || qe.Limit != 1000 || qe.Reset.Unix() != 1700000000 || !errors.Is(err, ErrRateLimited) {
+		t.Errorf("unexpected quota error %+v", qe)
+	}
+	if isTemporary(err) {
+		t.Errorf("quota errors should not be retried")
+	}
+
+	_, err = Do(ctx, "Header", "POST", nil)
+	if !errors.As(err, &qe) || qe.Limit != 50 || time.Until(qe.Reset) < 20*time.Second {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	_, err = Do(ctx, "Other", "GET", nil)
+	if err == nil || errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("unexpected error %v", err)
+	}
+}
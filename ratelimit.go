@@ -0,0 +1,81 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// RateLimitPolicy controls how rate limited calls (HTTP 429 or a rate_limit
+// error) are handled. Instead of returning ErrRateLimited, calls wait for the
+// delay requested by the server with Retry-After and are retried, as long as
+// the total wait of the call stays within MaxWait.
+type RateLimitPolicy struct {
+	MaxWait      time.Duration // total time a call may spend waiting
+	DefaultDelay time.Duration // wait when the server gives no delay, defaults to 1s
+}
+
+// RateLimit is the policy for clients without their own, nil to return rate
+// limit errors immediately
+var RateLimit *RateLimitPolicy
+
+// wait runs f, waiting and retrying while it is rate limited and the wait
+// budget allows it. name describes the operation in logs.
+func (p *RateLimitPolicy) wait(ctx context.Context, name string, f func() (*Response, error)) (*Response, error) {
+	if !p.enabled() {
+		return f()
+	}
+	var waited time.Duration
+	for {
+		res, err := f()
+		if err == nil || !errors.Is(err, ErrRateLimited) {
+			return res, err
+		}
+		d := p.delay(err)
+		if waited+d > p.MaxWait || ctx.Err() != nil {
+			return res, err
+		}
+		if debugEnabled(ctx) {
+			slog.DebugContext(ctx, fmt.Sprintf("[rest] %s rate limited, retrying in %s", name, d), "event", "rest:rate_limit")
+		}
+		if sleep(ctx, d) != nil {
+			return res, err
+		}
+		waited += d
+	}
+}
+
+// enabled returns true if rate limited calls are retried
+func (p *RateLimitPolicy) enabled() bool {
+	return p != nil && p.MaxWait > 0
+}
+
+// delay returns how long to wait before retrying after err
+func (p *RateLimitPolicy) delay(err error) time.Duration {
+	var qe *QuotaExceededError
+	if errors.As(err, &qe) && !qe.Reset.IsZero() {
+		return max(qe.Reset.Sub(now()), 0)
+	}
+	d := p.DefaultDelay
+	if d <= 0 {
+		d = time.Second
+	}
+	var httpErr *HttpError
+	if errors.As(err, &httpErr) && httpErr.RetryAfter > 0 {
+		return httpErr.RetryAfter
+	}
+	var apiErr *Error
+	if errors.As(err, &apiErr) && apiErr.RetryAfter > 0 {
+		return apiErr.RetryAfter
+	}
+	return d
+}
+
+// isRateLimited returns true if the error response reports that calls are
+// rate limited
+func isRateLimited(r *Response) bool {
+	return r.Code == 429 || strings.Contains(r.Token, "rate_limit")
+}
@@ -0,0 +1,79 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRateLimit(t *testing.T) {
+	var calls, always atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/_special/rest/Busy":
+			if calls.Add(1) < 3 {
+				w.WriteHeader(http.StatusTooManyRequests)
+				w.Write([]byte(`{"result":"error","error":"slow down","code":429}`))
+				return
+			}
+			w.Write([]byte(`{"result":"success","data":"ok"}`))
+		case "/_special/rest/Always":
+			always.Add(1)
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"result":"error","error":"slow down","code":429}`))
+		case "/_special/rest/Token":
+			w.Write([]byte(`{"result":"error","error":"slow down","code":403,"token":"error_rate_limit"}`))
+		default:
+			w.Header().Set("Retry-After", "60")
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"result":"error","error":"slow down","code":429}`))
+		}
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+	ctx := context.WithValue(context.Background(), BackendURL, u)
+
+	// without a policy, errors are returned immediately
+	_, err := Do(ctx, "Busy", "POST", nil)
+	if !errors.Is(err, ErrRateLimited) || calls.Load() != 1 {
+		t.Fatalf("expected rate limit error, got %v", err)
+	}
+
+	c := &Client{BaseURL: u, RateLimit: &RateLimitPolicy{MaxWait: time.Second, DefaultDelay: 10 * time.Millisecond}}
+	res, err := c.Do(ctx, "Busy", "POST", nil)
+	if err != nil {
+		t.Fatalf("call failed: %s", err)
+	}
+	if s, _ := res.GetString(""); s != "ok" || calls.Load() != 3 {
+		t.Errorf("unexpected result %q after %d calls", s, calls.Load())
+	}
+
+	// the requested delay is larger than the budget
+	start := time.Now()
+	_, err = c.Do(ctx, "Wait", "GET", nil)
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.RetryAfter != time.Minute || time.Since(start) > 500*time.Millisecond {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	// rate limit reported with an error token, the status is still matched
+	c.RateLimit.MaxWait = 50 * time.Millisecond
+	_, err = c.Do(ctx, "Token", "GET", nil)
+	if !errors.Is(err, ErrRateLimited) || !errors.Is(err, os.ErrPermission) {
+		t.Errorf("expected rate limit error, got %v", err)
+	}
+
+	// endpoint retries leave rate limits to the policy
+	SetPolicy("Always", &EndpointPolicy{Retries: 5, RetryDelay: time.Millisecond})
+	defer SetPolicy("Always", nil)
+	c.RateLimit.DefaultDelay = 20 * time.Millisecond
+	if _, err := c.Do(ctx, "Always", "GET", nil); !errors.Is(err, ErrRateLimited) || always.Load() != 3 {
+		t.Errorf("expected 3 calls, got %d: %v", always.Load(), err)
+	}
+}
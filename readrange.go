@@ -0,0 +1,95 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// ErrNoDownloadURL is returned by ReadRange when the object has no download
+// url
+var ErrNoDownloadURL = errors.New("object has no download url")
+
+// ReadRange returns the contents of a stored object starting at offset, up
+// to length bytes or until the end of the object if length is negative.
+// ref is either the download url of the object, or the API path of an
+// object whose data contains its download url as "Url". Only the requested
+// range is downloaded when the storage supports it. Reading at or past the
+// end of the object returns an empty reader. The download is only stopped
+// by ctx, not by the timeout of API calls.
+func ReadRange(ctx context.Context, ref string, offset, length int64) (io.ReadCloser, error) {
+	if offset < 0 {
+		return nil, fmt.Errorf("invalid offset %d", offset)
+	}
+	if length == 0 {
+		return http.NoBody, nil
+	}
+	u, err := resolveDownloadURL(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case length > 0:
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", offset, offset+length-1))
+	case offset > 0:
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+
+	// the body may be read for a long time, only ctx limits it
+	resp, err := UploadHttpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", ref, err)
+	}
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		return resp.Body, nil
+	case http.StatusRequestedRangeNotSatisfiable:
+		resp.Body.Close()
+		return http.NoBody, nil
+	case http.StatusOK:
+		// range not supported, skip to offset
+		if _, err := io.CopyN(io.Discard, resp.Body, offset); err != nil {
+			resp.Body.Close()
+			if err == io.EOF {
+				return http.NoBody, nil
+			}
+			return nil, err
+		}
+		if length > 0 {
+			return struct {
+				io.Reader
+				io.Closer
+			}{io.LimitReader(resp.Body, length), resp.Body}, nil
+		}
+		return resp.Body, nil
+	default:
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, newHttpError(resp, body, nil)
+	}
+}
+
+// resolveDownloadURL returns the download url for ref, see ReadRange
+func resolveDownloadURL(ctx context.Context, ref string) (string, error) {
+	if strings.HasPrefix(ref, "https://") || strings.HasPrefix(ref, "http://") {
+		return ref, nil
+	}
+	res, err := Do(ctx, ref, "GET", nil)
+	if err != nil {
+		return "", err
+	}
+	for _, k := range []string{"Url", "url", "Download_Url"} {
+		if u, err := res.GetString(k); err == nil && u != "" {
+			return u, nil
+		}
+	}
+	return "", fmt.Errorf("%w: %s", ErrNoDownloadURL, ref)
+}
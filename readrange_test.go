@@ -0,0 +1,59 @@
+package rest
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadRange(t *testing.T) {
+	content := strings.NewReader("0123456789")
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/_special/rest/Blob/b1":
+			w.Write([]byte(`{"result":"success","data":{"Url":"` + srv.URL + `/blob"}}`))
+		case "/blob":
+			http.ServeContent(w, r, "blob", now(), content)
+		case "/norange":
+			w.Write([]byte("0123456789"))
+		}
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+	ctx := context.WithValue(context.Background(), BackendURL, u)
+
+	read := func(ref string, off, ln int64) string {
+		r, err := ReadRange(ctx, ref, off, ln)
+		if err != nil {
+			t.Fatalf("ReadRange(%s, %d, %d) failed: %s", ref, off, ln, err)
+		}
+		defer r.Close()
+		buf, _ := io.ReadAll(r)
+		return string(buf)
+	}
+
+	if s := read("Blob/b1", 2, 3); s != "234" {
+		t.Errorf("expected 234, got %q", s)
+	}
+	if s := read(srv.URL+"/blob", 7, -1); s != "789" {
+		t.Errorf("expected 789, got %q", s)
+	}
+	if s := read(srv.URL+"/blob", 20, 5); s != "" {
+		t.Errorf("expected nothing past the end, got %q", s)
+	}
+	if s := read(srv.URL+"/norange", 4, 2); s != "45" {
+		t.Errorf("expected 45 without range support, got %q", s)
+	}
+
+	// storage is not read with the API client and its timeout
+	ctx = (&Client{BaseURL: u, HTTPClient: &http.Client{Timeout: time.Nanosecond}}).Use(ctx)
+	if s := read(srv.URL+"/blob", 0, 2); s != "01" {
+		t.Errorf("expected 01, got %q", s)
+	}
+}
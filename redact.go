@@ -0,0 +1,122 @@
+package rest
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+var (
+	// RedactHeaders lists the headers whose value is hidden in debug output
+	// and HAR recordings
+	RedactHeaders = []string{"Authorization", "Cookie", "Set-Cookie", "X-Amz-Security-Token"}
+
+	// RedactParams lists the parameters, json fields and query parameters,
+	// whose value is hidden in debug output and HAR recordings
+	RedactParams = []string{"_sign", "_key", "access_token", "refresh_token", "client_secret", "password", "authorization"}
+
+	// RedactPatterns are additional patterns whose matches are hidden in
+	// debug output and HAR recordings
+	RedactPatterns []*regexp.Regexp
+)
+
+// redacted replaces hidden values
+const redacted = "[redacted]"
+
+var (
+	// default patterns: bearer tokens and aws signatures
+	bearerPattern    = regexp.MustCompile(`(?i)\bBearer\s+[A-Za-z0-9._~+/-]+=*`)
+	signaturePattern = regexp.MustCompile(`\bSignature=[0-9a-fA-F]+`)
+
+	paramPatternsLk  sync.Mutex
+	paramPatternsKey string
+	paramPatterns    [2]*regexp.Regexp
+)
+
+// Redact returns s with secrets hidden, according to RedactParams and
+// RedactPatterns. It is applied to bodies and messages in debug output.
+func Redact(s string) string {
+	if s == "" {
+		return s
+	}
+	if p := redactParamPatterns(); p[0] != nil {
+		s = p[0].ReplaceAllString(s, `"$1":"`+redacted+`"`)
+		s = p[1].ReplaceAllString(s, `${1}=`+redacted)
+	}
+	s = bearerPattern.ReplaceAllString(s, "Bearer "+redacted)
+	s = signaturePattern.ReplaceAllString(s, "Signature="+redacted)
+	for _, p := range RedactPatterns {
+		s = p.ReplaceAllString(s, redacted)
+	}
+	return s
+}
+
+// redactParamPatterns returns the patterns matching RedactParams in json
+// and in url encoded data, compiled once for each value of RedactParams
+func redactParamPatterns() [2]*regexp.Regexp {
+	key := strings.Join(RedactParams, "\x00")
+
+	paramPatternsLk.Lock()
+	defer paramPatternsLk.Unlock()
+	if key == paramPatternsKey {
+		return paramPatterns
+	}
+	paramPatternsKey = key
+	paramPatterns = [2]*regexp.Regexp{}
+	if len(RedactParams) == 0 {
+		return paramPatterns
+	}
+	names := make([]string, len(RedactParams))
+	for i, p := range RedactParams {
+		names[i] = regexp.QuoteMeta(p)
+	}
+	alt := "(?i)(" + strings.Join(names, "|") + ")"
+	paramPatterns[0] = regexp.MustCompile(`"` + alt + `"\s*:\s*"(?:[^"\\]|\\.)*"`)
+	paramPatterns[1] = regexp.MustCompile(`\b` + alt + `=[^&\s"]*`)
+	return paramPatterns
+}
+
+// redactHeader returns the value of header name as shown in debug output
+func redactHeader(name, v string, headers []string) string {
+	if headers == nil {
+		headers = RedactHeaders
+	}
+	for _, h := range headers {
+		if strings.EqualFold(name, h) {
+			return redacted
+		}
+	}
+	return v
+}
+
+// isRedactedParam returns true if the value of parameter name is hidden
+func isRedactedParam(name string) bool {
+	for _, p := range RedactParams {
+		if strings.EqualFold(name, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactURL returns u as a string, with the values of its query parameters
+// redacted
+func redactURL(u *url.URL) string {
+	if u.RawQuery == "" {
+		return u.String()
+	}
+	q := u.Query()
+	for k, vs := range q {
+		for i, v := range vs {
+			if isRedactedParam(k) {
+				vs[i] = redacted
+			} else {
+				vs[i] = Redact(v)
+			}
+		}
+	}
+	c := *u
+	c.RawQuery = q.Encode()
+	return c.String()
+}
@@ -0,0 +1,38 @@
+package rest
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	tests := map[string]string{
+		`{"grant_type":"refresh_token","refresh_token":"r1","client_id":"c"}`: `{"grant_type":"refresh_token","refresh_token":"[redacted]","client_id":"c"}`,
+		`{"Authorization": "AWS4-HMAC-SHA256 Credential=x, Signature=0a1b"}`:  `{"Authorization":"[redacted]"}`,
+		`path?_key=k1&_sign=s1&a=1`:              `path?_key=[redacted]&_sign=[redacted]&a=1`,
+		`sent Bearer abc.def`:                    `sent Bearer [redacted]`,
+		`SignedHeaders=host, Signature=deadbeef`: `SignedHeaders=host, Signature=[redacted]`,
+	}
+	for in, want := range tests {
+		if got := Redact(in); got != want {
+			t.Errorf("Redact(%s) = %s, expected %s", in, got, want)
+		}
+	}
+
+	RedactPatterns = []*regexp.Regexp{regexp.MustCompile(`card-[0-9]+`)}
+	RedactParams = append(RedactParams, "otp")
+	defer func() {
+		RedactPatterns = nil
+		RedactParams = RedactParams[:len(RedactParams)-1]
+	}()
+	if got := Redact(`{"otp":"123","note":"card-4242"}`); got != `{"otp":"[redacted]","note":"[redacted]"}` {
+		t.Errorf("custom rules not applied: %s", got)
+	}
+
+	u, _ := url.Parse(`https://example.com/x?_=` + url.QueryEscape(`{"password":"p","a":1}`) + `&_key=k`)
+	if got := redactURL(u); strings.Contains(got, "p%22") || strings.Contains(got, "=k") {
+		t.Errorf("url not redacted: %s", got)
+	}
+}
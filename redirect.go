@@ -0,0 +1,101 @@
+package rest
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/KarpelesLab/pjson"
+	"github.com/KarpelesLab/webutil"
+)
+
+// RedirectMode defines how "redirect" results from the API are handled
+type RedirectMode int
+
+const (
+	// RedirectAsError returns redirects as webutil redirect errors
+	RedirectAsError RedirectMode = iota
+	// RedirectFollow follows redirects to other API endpoints on the same
+	// backend for GET and HEAD requests. Other redirects are returned as
+	// webutil redirect errors.
+	RedirectFollow
+	// RedirectReturn returns redirects as *RedirectResult errors
+	RedirectReturn
+)
+
+// Redirects is the default redirect handling, see WithRedirectMode
+var Redirects = RedirectAsError
+
+// MaxRedirects is the maximum number of redirects followed by a single call
+// in RedirectFollow mode
+var MaxRedirects = 10
+
+// RedirectResult is returned as error for redirects in RedirectReturn mode
+type RedirectResult struct {
+	URL  *url.URL
+	Code int // HTTP status code, such as 302
+}
+
+func (r *RedirectResult) Error() string {
+	return fmt.Sprintf("[rest] redirect (%d) to %s", r.Code, r.URL)
+}
+
+// redirectHops is the context key counting followed redirects
+type redirectHops struct{}
+
+// redirectMode returns the redirect handling for ctx
+func redirectMode(ctx context.Context) RedirectMode {
+	if m, ok := ctx.Value(Redirect).(RedirectMode); ok {
+		return m
+	}
+	return Redirects
+}
+
+// handleRedirect handles a redirect result for a request
+func handleRedirect(ctx context.Context, method string, u *url.URL, code int, target any, info *RequestInfo) (*Response, error) {
+	switch redirectMode(ctx) {
+	case RedirectReturn:
+		return nil, &RedirectResult{URL: u, Code: code}
+	case RedirectFollow:
+		hops, _ := ctx.Value(redirectHops{}).(int)
+		if hops >= MaxRedirects {
+			return nil, fmt.Errorf("[rest] stopped after %d redirects", hops)
+		}
+		if path, param, ok := apiRedirect(ctx, method, u); ok {
+			ctx = context.WithValue(ctx, redirectHops{}, hops+1)
+			info.Path = path
+			return doRequest(ctx, path, method, param, target, info)
+		}
+	}
+	return nil, webutil.RedirectErrorCode(u, code)
+}
+
+// apiRedirect returns the API path and parameters of u if it can safely be
+// followed
+func apiRedirect(ctx context.Context, method string, u *url.URL) (string, Param, bool) {
+	if method != "GET" && method != "HEAD" {
+		return "", nil, false
+	}
+	backend := backendURL(ctx)
+	u = backend.ResolveReference(u)
+	if u.Scheme != backend.Scheme || u.Host != backend.Host {
+		return "", nil, false
+	}
+	path, ok := strings.CutPrefix(u.Path, PathPrefix)
+	if !ok {
+		return "", nil, false
+	}
+
+	param := make(Param)
+	for k, v := range u.Query() {
+		if k == "_" {
+			if err := pjson.Unmarshal([]byte(v[0]), &param); err != nil {
+				return "", nil, false
+			}
+			continue
+		}
+		param[k] = v[0]
+	}
+	return path, param, true
+}
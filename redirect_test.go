@@ -0,0 +1,44 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestRedirectMode(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/_special/rest/Test/old":
+			w.Write([]byte(`{"result":"redirect","redirect_url":"/_special/rest/Test/new?id=1","redirect_code":301}`))
+		case "/_special/rest/Test/new":
+			// parameters are passed as json in _
+			w.Write([]byte(`{"result":"success","data":{"Params":` + r.URL.Query().Get("_") + `}}`))
+		}
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+	ctx := context.WithValue(context.Background(), BackendURL, u)
+
+	var redir *RedirectResult
+	_, err := Do(WithRedirectMode(ctx, RedirectReturn), "Test/old", "GET", nil)
+	if !errors.As(err, &redir) || redir.Code != 301 || redir.URL.Path != "/_special/rest/Test/new" {
+		t.Errorf("expected RedirectResult, got %v", err)
+	}
+
+	var res struct{ Params struct{ Id string } }
+	if err := Apply(WithRedirectMode(ctx, RedirectFollow), "Test/old", "GET", nil, &res); err != nil {
+		t.Fatalf("failed to follow redirect: %s", err)
+	}
+	if res.Params.Id != "1" {
+		t.Errorf("unexpected result after redirect: %+v", res)
+	}
+
+	// POST redirects are not followed
+	if _, err := Do(WithRedirectMode(ctx, RedirectFollow), "Test/old", "POST", nil); err == nil || errors.As(err, &redir) {
+		t.Errorf("expected redirect error, got %v", err)
+	}
+}
@@ -0,0 +1,37 @@
+package rest
+
+import (
+	"errors"
+	"io"
+	"net/http"
+)
+
+var errBodyNotReplayable = errors.New("[rest] request body cannot be sent again")
+
+// rewindBody resets the body of r with GetBody so r can be sent again
+func rewindBody(r *http.Request) error {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil
+	}
+	if r.GetBody == nil {
+		return errBodyNotReplayable
+	}
+	body, err := r.GetBody()
+	if err != nil {
+		return err
+	}
+	r.Body = body
+	return nil
+}
+
+// seekGetBody returns a GetBody function returning body after rewinding
+// seeker, the source body reads from. This allows the transport to replay
+// requests, for example when a HTTP/2 connection is shut down.
+func seekGetBody(seeker io.Seeker, body io.Reader) func() (io.ReadCloser, error) {
+	return func() (io.ReadCloser, error) {
+		if _, err := seeker.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+		return io.NopCloser(body), nil
+	}
+}
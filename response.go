@@ -29,6 +29,12 @@ type Response struct {
 	RedirectUrl  string `json:"redirect_url,omitempty"`
 	RedirectCode int    `json:"redirect_code,omitempty"`
 
+	Meta *ResponseMeta `json:"-"` // set for HEAD and OPTIONS requests not returning data
+
+	// ExtraFields holds top level fields of the response not known to this
+	// version of the library, such as metadata added by newer backends
+	ExtraFields map[string]pjson.RawMessage `json:"-"`
+
 	dataParsed any
 	dataError  error
 	dataParse  sync.Once
@@ -60,6 +66,11 @@ func (r *Response) OffsetGet(ctx context.Context, key string) (any, error) {
 		case "exception":
 			return r.Exception, nil
 		}
+		if raw, ok := r.ExtraFields[key[1:]]; ok {
+			var v any
+			err := pjson.UnmarshalContext(ctx, raw, &v)
+			return v, err
+		}
 	}
 
 	// return value
@@ -111,6 +122,12 @@ func (r *Response) FullRaw() (map[string]any, error) {
 	if r.RedirectCode != 0 {
 		resp["redirect_code"] = r.RedirectCode
 	}
+	for k, raw := range r.ExtraFields {
+		var v any
+		if pjson.Unmarshal(raw, &v) == nil {
+			resp[k] = v
+		}
+	}
 
 	return resp, nil
 }
@@ -171,3 +188,24 @@ func (r *Response) GetString(v string) (string, error) {
 	}
 	return str, nil
 }
+
+// GetTime returns the time at path v, which can be a timestamp object as
+// returned by the API or a unix timestamp
+func (r *Response) GetTime(v string) (Time, error) {
+	var t Time
+	res, err := r.Get(v)
+	if err != nil {
+		return t, err
+	}
+	if res == nil {
+		return t, fmt.Errorf("no time found at %s", v)
+	}
+	data, err := pjson.Marshal(res)
+	if err != nil {
+		return t, err
+	}
+	if err := t.UnmarshalJSON(data); err != nil {
+		return t, fmt.Errorf("unexpected value for time %s: %w", v, err)
+	}
+	return t, nil
+}
@@ -0,0 +1,147 @@
+package rest
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// Cache, if set, stores the responses to GET requests made with clients
+// without their own cache. Cached responses are shared between callers and
+// must be treated as read-only.
+var Cache *ResponseCache
+
+// ResponseCache is a client-side cache of API responses, keyed on path,
+// parameters, token, session, version and locale headers. Requests altered with
+// WithRequestMutator are not cached. Successful POST, PUT, PATCH and DELETE requests
+// invalidate cached responses related to their path.
+type ResponseCache struct {
+	// TTL is how long responses are kept for paths without a more specific
+	// TTL. Zero means responses are only cached for paths set with SetTTL.
+	TTL time.Duration
+	// InvalidatePrefix returns the path prefix to invalidate after a
+	// successful mutating request on path, or an empty string to invalidate
+	// nothing. By default the resource and its parent are invalidated, for
+	// example "Blog/Article/abc:update" invalidates "Blog/Article".
+	InvalidatePrefix func(path string) string
+
+	lk      sync.RWMutex
+	ttls    map[string]time.Duration
+	entries map[string]*responseCacheEntry
+}
+
+type responseCacheEntry struct {
+	path    string
+	res     *Response
+	expires time.Time
+}
+
+// NewResponseCache returns a ResponseCache keeping responses for ttl
+func NewResponseCache(ttl time.Duration) *ResponseCache {
+	return &ResponseCache{TTL: ttl}
+}
+
+// SetTTL sets how long responses for paths starting with prefix are kept.
+// The longest matching prefix is used. A TTL of zero disables caching.
+func (c *ResponseCache) SetTTL(prefix string, ttl time.Duration) {
+	c.lk.Lock()
+	defer c.lk.Unlock()
+	if c.ttls == nil {
+		c.ttls = make(map[string]time.Duration)
+	}
+	c.ttls[prefix] = ttl
+}
+
+// Invalidate removes the cached responses for paths starting with pathPrefix
+func (c *ResponseCache) Invalidate(pathPrefix string) {
+	c.lk.Lock()
+	defer c.lk.Unlock()
+	for k, e := range c.entries {
+		if strings.HasPrefix(e.path, pathPrefix) {
+			delete(c.entries, k)
+		}
+	}
+}
+
+// Purge removes all cached responses
+func (c *ResponseCache) Purge() {
+	c.lk.Lock()
+	defer c.lk.Unlock()
+	c.entries = nil
+}
+
+func (c *ResponseCache) ttl(path string) time.Duration {
+	if c == nil {
+		return 0
+	}
+	if p := policyFor(path); p != nil && p.CacheTTL != 0 {
+		return p.CacheTTL
+	}
+	c.lk.RLock()
+	defer c.lk.RUnlock()
+
+	ttl, best := c.TTL, -1
+	for prefix, v := range c.ttls {
+		if len(prefix) > best && strings.HasPrefix(path, prefix) {
+			ttl, best = v, len(prefix)
+		}
+	}
+	return ttl
+}
+
+func (c *ResponseCache) get(key string) *Response {
+	if c == nil {
+		return nil
+	}
+	c.lk.RLock()
+	defer c.lk.RUnlock()
+	if e, ok := c.entries[key]; ok && now().Before(e.expires) {
+		return e.res
+	}
+	return nil
+}
+
+func (c *ResponseCache) set(key, path string, res *Response) {
+	ttl := c.ttl(path)
+	if ttl <= 0 {
+		return
+	}
+	c.lk.Lock()
+	defer c.lk.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[string]*responseCacheEntry)
+	}
+	now := now()
+	for k, e := range c.entries {
+		// drop expired entries
+		if now.After(e.expires) {
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = &responseCacheEntry{path: path, res: res, expires: now.Add(ttl)}
+}
+
+// mutated is called after a successful request which may have changed data
+func (c *ResponseCache) mutated(path string) {
+	if c == nil {
+		return
+	}
+	f := c.InvalidatePrefix
+	if f == nil {
+		f = defaultInvalidatePrefix
+	}
+	if prefix := f(path); prefix != "" {
+		c.Invalidate(prefix)
+	}
+}
+
+// defaultInvalidatePrefix returns the parent of the resource at path
+func defaultInvalidatePrefix(path string) string {
+	if pos := strings.IndexByte(path, ':'); pos != -1 {
+		path = path[:pos]
+	}
+	if pos := strings.LastIndexByte(path, '/'); pos != -1 {
+		return path[:pos]
+	}
+	return path
+}
@@ -12,7 +12,6 @@ import (
 	"time"
 
 	"github.com/KarpelesLab/pjson"
-	"github.com/KarpelesLab/webutil"
 )
 
 var (
@@ -21,37 +20,143 @@ var (
 	Host   = "www.atonline.com"
 )
 
+// Apply performs a request and decodes the returned data into target. The
+// data is decoded directly from the response body, without keeping a raw copy.
 func Apply(ctx context.Context, path, method string, param any, target any) error {
-	res, err := Do(ctx, path, method, param)
+	_, err := do(ctx, path, method, param, target)
+	return err
+}
+
+func Do(ctx context.Context, path, method string, param any) (*Response, error) {
+	return do(ctx, path, method, param, nil)
+}
+
+func do(ctx context.Context, path, method string, param any, target any) (res *Response, err error) {
+	defer func() {
+		// metrics collectors and error hooks are user code
+		if r := recover(); r != nil {
+			res, err = nil, newPanicError(r)
+		}
+	}()
+	ctx, done, err := trackCall(ctx)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	err = pjson.UnmarshalContext(ctx, res.Data, target)
-	if Debug && err != nil {
-		slog.ErrorContext(ctx, fmt.Sprintf("failed to parse json: %s\n%s", err, res.Data), "event", "rest:not_json")
+	defer done()
+	param = sessionParams(ctx, param)
+
+	policy := policyFor(path)
+	ctx, cancel := withDefaultTimeout(ctx, policy.timeout(DefaultTimeout))
+	defer cancel()
+
+	info := &RequestInfo{Method: method, Path: path}
+	start := time.Now()
+
+	err = ValidatePath(path)
+	if err == nil {
+		err = Duplicates.check(ctx, method, path, param, info)
 	}
-	return err
+	if err == nil {
+		Mirror.mirror(ctx, path, method, param)
+		res, err = contextClient(ctx).rateLimit().wait(ctx, method+" "+path, func() (*Response, error) {
+			return policy.retry(ctx, method, path, func() (*Response, error) {
+				info.Attempts += 1
+				return request(ctx, path, method, param, target, info)
+			})
+		})
+	}
+	info.Duration = time.Since(start)
+	info.Err = err
+
+	Traffic.ObserveRequest(info)
+	if t := contextClient(ctx).Traffic; t != nil {
+		t.ObserveRequest(info)
+	}
+	if Metrics != nil {
+		Metrics.ObserveRequest(info)
+	}
+	if err != nil && OnError != nil {
+		OnError(ctx, info)
+	}
+	return res, err
 }
 
-func Do(ctx context.Context, path, method string, param any) (*Response, error) {
-	var backend *url.URL
+// request runs a request through the response cache and GET coalescing
+func request(ctx context.Context, path, method string, param any, target any, info *RequestInfo) (*Response, error) {
+	cache := contextClient(ctx).cache()
+	if method != "GET" || (!CoalesceGET && cache.ttl(path) <= 0) || hasRequestMutator(ctx) {
+		// requests altered by mutators cannot be compared
+		res, err := doRequest(ctx, path, method, param, target, info)
+		if err == nil && method != "GET" && method != "HEAD" && method != "OPTIONS" {
+			cache.mutated(path)
+		}
+		return res, err
+	}
+
+	key, err := requestKey(ctx, path, param)
+	if err != nil {
+		return nil, err
+	}
+	res := cache.get(key)
+	if res == nil {
+		if CoalesceGET {
+			res, err = coalesce(ctx, key, policyFor(path).timeout(DefaultTimeout), info, func(ctx context.Context, info *RequestInfo) (*Response, error) {
+				return doRequest(ctx, path, method, param, nil, info)
+			})
+		} else {
+			res, err = doRequest(ctx, path, method, param, nil, info)
+		}
+		if err != nil {
+			return nil, err
+		}
+		cache.set(key, path, res)
+	}
+	if target != nil {
+		if err := applyData(ctx, res, target); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// backendURL returns the backend to send requests to for ctx
+func backendURL(ctx context.Context) *url.URL {
 	if bk, ok := ctx.Value(BackendURL).(*url.URL); ok && bk != nil {
-		backend = bk
-	} else {
-		backend = &url.URL{Scheme: Scheme, Host: Host}
+		return bk
 	}
+	return contextClient(ctx).backend()
+}
+
+// doRequest performs the request. If target is not nil, the response data is
+// decoded into it instead of being kept in the returned Response.
+func doRequest(ctx context.Context, path, method string, param any, target any, info *RequestInfo) (res *Response, err error) {
+	defer func() {
+		// request mutators, decoders of target and other callbacks may panic
+		if r := recover(); r != nil {
+			res, err = nil, newPanicError(r)
+		}
+	}()
+	backend := backendURL(ctx)
 	// build http request
 	r := &http.Request{
 		Method: method,
 		URL: &url.URL{
 			Scheme: backend.Scheme,
 			Host:   backend.Host,
-			Path:   "/_special/rest/" + path,
+			Path:   PathPrefix + path,
 		},
 		Header: make(http.Header),
 	}
+	r = r.WithContext(ctx)
 
 	r.Header.Set("Sec-Rest-Http", "false")
+	if info.IdempotencyKey != "" {
+		r.Header.Set("Idempotency-Key", info.IdempotencyKey)
+	}
+	if v := apiVersion(ctx); v != "" {
+		r.Header.Set("Sec-Rest-Version", v)
+	}
+	setLocaleHeaders(ctx, r)
 
 	// add parameters (depending on method)
 	switch method {
@@ -82,81 +187,100 @@ func Do(ctx context.Context, path, method string, param any) (*Response, error)
 	}
 
 	// final configuration
-	ctx.Value(r)
+	mutateRequest(ctx, r)
 
 	// check for rest token
 	var token *Token
-	if t, ok := ctx.Value(tokenValue(0)).(*Token); t != nil && ok {
+	if t := contextToken(ctx); t != nil {
 		// set token & authorization header
 		token = t
 		r.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token.AccessToken))
 	}
+	session := contextSession(ctx)
+	if session != nil {
+		session.addCookies(r)
+	}
 
 	t := time.Now()
 
-	resp, err := RestHttpClient.Do(r)
-	if err != nil {
-		return nil, fmt.Errorf("failed to run rest query: %w", err)
-	}
-	defer resp.Body.Close()
+	r, timing := traceRequest(r)
+	defer timing.log(ctx, method, path)
 
-	body, err := ioutil.ReadAll(resp.Body)
+	if err := policyFor(path).wait(ctx); err != nil {
+		return nil, err
+	}
+	// the slot is only held while talking to the server, token renewal and
+	// redirects perform requests of their own
+	release, err := contextClient(ctx).limiter().hold(ctx)
 	if err != nil {
 		return nil, err
 	}
+	defer release()
+	Retries.request()
 
-	//log.Printf(ctx, "[rest] Response to %s %s: %s", method, path, body)
+	sent := now()
+	info.BytesOut += r.ContentLength
+	resp, err := httpClient(ctx).Do(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to run rest query: %w", err)
+	}
+	defer resp.Body.Close()
+	info.Status = resp.StatusCode
+	if session != nil {
+		session.setCookies(resp)
+	}
+	checkDeprecation(ctx, method, path, resp.Header)
+	if headerOnly(method, resp) {
+		return metaResponse(resp, info)
+	}
 
 	result := &Response{}
-	err = pjson.UnmarshalContext(ctx, body, result)
-	if err != nil {
-		if Debug {
-			slog.ErrorContext(ctx, fmt.Sprintf("failed to parse json: %s\n%s", err, body), "event", "rest:not_json")
-		}
-		if resp.StatusCode >= 400 {
-			// this is an error response
-			err = &HttpError{Code: resp.StatusCode, Body: body, e: err}
-		}
+	if err := readResponse(ctx, resp, result, target, info); err != nil {
 		return nil, err
 	}
+	release()
+	observeServerTime(result, sent, now())
 
 	if token != nil && result.Token == "invalid_request_token" && result.Extra == "token_expired" {
 		// token has expired, renew token & re-run process
-		if Debug {
+		if debugEnabled(ctx) {
 			slog.DebugContext(ctx, "Token has expired, requesting renew", "event", "rest:token_renew")
 		}
 		if err := token.renew(ctx); err != nil {
 			// error
-			if Debug {
-				slog.ErrorContext(ctx, fmt.Sprintf("failed to renew token: %s", err), "event", "rest:token_renew_fail")
+			if debugEnabled(ctx) {
+				slog.ErrorContext(ctx, Redact(fmt.Sprintf("failed to renew token: %s", err)), "event", "rest:token_renew_fail")
 			}
 			return nil, err
 		}
 
 		// re-run query
+		if err := rewindBody(r); err != nil {
+			return nil, err
+		}
 		r.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token.AccessToken))
-		resp, err := RestHttpClient.Do(r)
+		release, err = contextClient(ctx).limiter().hold(ctx)
 		if err != nil {
 			return nil, err
 		}
-		defer resp.Body.Close()
-
-		body, err := ioutil.ReadAll(resp.Body)
+		defer release()
+		info.BytesOut += r.ContentLength
+		resp, err = httpClient(ctx).Do(r)
 		if err != nil {
 			return nil, err
 		}
+		defer resp.Body.Close()
+		info.Status = resp.StatusCode
 
-		err = pjson.UnmarshalContext(ctx, body, result)
-		if err != nil {
-			if Debug {
-				slog.ErrorContext(ctx, fmt.Sprintf("failed to parse json: %s\n%s", err, body), "event", "rest:not_json")
-			}
+		result = &Response{}
+		if err := readResponse(ctx, resp, result, target, info); err != nil {
 			return nil, err
 		}
+		release()
 	}
 
-	if Debug {
-		if v, ok := ctx.Value(SkipDebugLog).(bool); !ok || !v {
+	if debugEnabled(ctx) {
+		if !skipDebugLog(ctx) {
 			d := time.Since(t)
 			slog.DebugContext(ctx, fmt.Sprintf("[rest] %s %s => %s", method, path, d), "event", "rest:debug_query", "rest:method", method, "rest:request", path, "rest:duration", d)
 		}
@@ -164,18 +288,108 @@ func Do(ctx context.Context, path, method string, param any) (*Response, error)
 
 	if result.Result == "redirect" {
 		if result.Exception == "Exception\\Login" {
-			return nil, ErrLoginRequired
+			return nil, newLoginRequiredError(result)
 		}
-		url, err := url.Parse(result.RedirectUrl)
+		u, err := url.Parse(result.RedirectUrl)
 		if err != nil {
 			return nil, err
 		}
-		return nil, webutil.RedirectErrorCode(url, result.RedirectCode)
+		return handleRedirect(ctx, method, u, result.RedirectCode, target, info)
 	}
 
 	if result.Result == "error" {
-		return nil, &Error{Response: result}
+		return nil, responseError(resp, result)
 	}
 
 	return result, nil
 }
+
+// readResponse reads the response envelope from resp into result. If target
+// is not nil, the data field is decoded directly into it instead of being
+// copied into result.
+func readResponse(ctx context.Context, resp *http.Response, result *Response, target any, info *RequestInfo) error {
+	buf, err := readBody(resp.Body, resp.ContentLength)
+	defer putBuffer(buf)
+	body := buf.Bytes()
+	info.BytesIn += int64(len(body))
+	if err != nil {
+		return err
+	}
+
+	//log.Printf(ctx, "[rest] Response to %s %s: %s", method, path, body)
+
+	var env any = result
+	var envelope *dataEnvelope
+	if target != nil {
+		envelope = &dataEnvelope{Response: result, Data: &dataTarget{ctx: ctx, target: target}}
+		env = envelope
+	}
+
+	err = pjson.UnmarshalContext(ctx, body, env)
+	if err != nil {
+		if debugEnabled(ctx) {
+			slog.ErrorContext(ctx, fmt.Sprintf("failed to parse json: %s\n%s", err, Redact(string(body))), "event", "rest:not_json")
+		}
+		if resp.StatusCode >= 400 {
+			// this is an error response
+			err = newHttpError(resp, bytes.Clone(body), err)
+		} else if StrictParsing {
+			err = &MalformedResponseError{Status: resp.StatusCode, Reason: "invalid JSON", Body: bytes.Clone(body), Err: err}
+		}
+		return err
+	}
+
+	if StrictParsing {
+		if reason := checkEnvelope(body, result, envelope); reason != "" {
+			return &MalformedResponseError{Status: resp.StatusCode, Reason: reason, Body: bytes.Clone(body)}
+		}
+	}
+
+	if envelope == nil || result.Result != "success" {
+		// data of errors and redirects is not meant for target
+		return nil
+	}
+	data := envelope.Data
+	if data == nil {
+		// data was null
+		return nil
+	}
+	if !data.found {
+		// no data, fail the same way as with an empty Data
+		return applyData(ctx, result, target)
+	}
+	if data.err != nil && debugEnabled(ctx) {
+		slog.ErrorContext(ctx, fmt.Sprintf("failed to parse json: %s\n%s", data.err, Redact(string(body))), "event", "rest:not_json")
+	}
+	return data.err
+}
+
+// applyData decodes the data of res into target
+func applyData(ctx context.Context, res *Response, target any) error {
+	err := pjson.UnmarshalContext(ctx, res.Data, target)
+	if debugEnabled(ctx) && err != nil {
+		slog.ErrorContext(ctx, fmt.Sprintf("failed to parse json: %s\n%s", err, Redact(string(res.Data))), "event", "rest:not_json")
+	}
+	return err
+}
+
+// dataEnvelope is a Response whose data field is decoded by dataTarget
+type dataEnvelope struct {
+	*Response
+	Data *dataTarget `json:"data"`
+}
+
+// dataTarget decodes a data field into target. Errors are kept until the
+// whole envelope is read, as they do not matter if the request failed.
+type dataTarget struct {
+	ctx    context.Context
+	target any
+	found  bool
+	err    error
+}
+
+func (d *dataTarget) UnmarshalJSON(b []byte) error {
+	d.found = true
+	d.err = pjson.UnmarshalContext(d.ctx, b, d.target)
+	return nil
+}
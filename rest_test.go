@@ -0,0 +1,632 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newBenchBackend(b *testing.B) context.Context {
+	data := `{"result":"success","data":{"Items":[` + strings.Repeat(`{"Id":"item-xxxx","Value":12345},`, 200) + `{}]}}`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(data))
+	}))
+	b.Cleanup(srv.Close)
+
+	u, _ := url.Parse(srv.URL)
+	return context.WithValue(context.Background(), BackendURL, u)
+}
+
+func BenchmarkDo(b *testing.B) {
+	ctx := newBenchBackend(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := Do(ctx, "Test:bench", "POST", Param{"foo": "bar"}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkApply(b *testing.B) {
+	ctx := newBenchBackend(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	var target struct {
+		Items []struct {
+			Id    string
+			Value int
+		}
+	}
+	for i := 0; i < b.N; i++ {
+		if err := Apply(ctx, "Test:bench", "GET", nil, &target); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestApply(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/_special/rest/Test:ok":
+			w.Write([]byte(`{"data":{"Id":"abc","Value":42},"result":"success","time":1}`))
+		case "/_special/rest/Test:error":
+			w.Write([]byte(`{"data":"not an object","result":"error","error":"something failed","code":400}`))
+		}
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+	ctx := context.WithValue(context.Background(), BackendURL, u)
+
+	var v struct {
+		Id    string
+		Value int
+	}
+	if err := Apply(ctx, "Test:ok", "GET", nil, &v); err != nil {
+		t.Fatalf("Apply failed: %s", err)
+	}
+	if v.Id != "abc" || v.Value != 42 {
+		t.Errorf("unexpected value %+v", v)
+	}
+
+	err := Apply(ctx, "Test:error", "GET", nil, &v)
+	var restErr *Error
+	if !errors.As(err, &restErr) || restErr.Response.Error != "something failed" {
+		t.Errorf("expected rest error, got %v", err)
+	}
+}
+
+func TestAsFull(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":[{"Id":"abc"},{"Id":"def"}],"result":"success","paging":{"count":10},"time":1}`))
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+	ctx := context.WithValue(context.Background(), BackendURL, u)
+
+	type item struct{ Id string }
+	res, err := AsFull[[]item](ctx, "Test", "GET", nil)
+	if err != nil {
+		t.Fatalf("AsFull failed: %s", err)
+	}
+	if len(res.Data) != 2 || res.Data[1].Id != "def" {
+		t.Errorf("unexpected data %+v", res.Data)
+	}
+	if res.Response.Paging == nil || res.Response.Time == nil {
+		t.Errorf("expected envelope to be set, got %+v", res.Response)
+	}
+
+	items, err := As[[]item](ctx, "Test", "GET", nil)
+	if err != nil || len(items) != 2 {
+		t.Errorf("As returned %+v, %v", items, err)
+	}
+
+	items, err = Get[[]item](ctx, "Test", nil)
+	if err != nil || len(items) != 2 {
+		t.Errorf("Get returned %+v, %v", items, err)
+	}
+}
+
+func TestHeadOptions(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/_special/rest/Test/missing":
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Allow", "GET, head,OPTIONS")
+		w.Header().Set("ETag", `"abc"`)
+		w.Header().Set("Last-Modified", "Mon, 02 Jan 2006 15:04:05 GMT")
+		w.Header().Set("Content-Length", "42")
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+	ctx := context.WithValue(context.Background(), BackendURL, u)
+
+	meta, err := Head(ctx, "Test/abc", nil)
+	if err != nil {
+		t.Fatalf("Head failed: %s", err)
+	}
+	if meta.ContentLength != 42 || meta.ETag != `"abc"` || meta.LastModified.Year() != 2006 {
+		t.Errorf("unexpected head result %+v", meta)
+	}
+
+	meta, err = Options(ctx, "Test/abc", nil)
+	if err != nil {
+		t.Fatalf("Options failed: %s", err)
+	}
+	if strings.Join(meta.Allow, ",") != "GET,HEAD,OPTIONS" {
+		t.Errorf("unexpected allowed methods %v", meta.Allow)
+	}
+
+	if _, err := Head(ctx, "Test/missing", nil); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected not found error, got %v", err)
+	}
+}
+
+func TestExtraFields(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"result":"success","data":{"Id":"abc"},"time":1,"request_id":"req-1","quota":{"left":5}}`))
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+	ctx := context.WithValue(context.Background(), BackendURL, u)
+
+	res, err := Do(ctx, "Test", "GET", nil)
+	if err != nil {
+		t.Fatalf("Do failed: %s", err)
+	}
+	if len(res.ExtraFields) != 2 || string(res.ExtraFields["request_id"]) != `"req-1"` {
+		t.Errorf("unexpected extra fields %v", res.ExtraFields)
+	}
+	if v, err := res.OffsetGet(ctx, "@quota"); err != nil || v.(map[string]any)["left"] != float64(5) {
+		t.Errorf("unexpected @quota value %v, %v", v, err)
+	}
+
+	full, err := AsFull[map[string]any](ctx, "Test", "GET", nil)
+	if err != nil || full.Response.ExtraFields["quota"] == nil {
+		t.Errorf("expected extra fields when decoding into a target, got %+v, %v", full, err)
+	}
+}
+
+func TestDecodeEnvelope(t *testing.T) {
+	var r Response
+	err := json.Unmarshal([]byte(`{"Result":"error","error":"failed","code":403,"paging":{"page_no":1},"request_id":"req-1"}`), &r)
+	if err != nil {
+		t.Fatalf("failed to decode envelope: %s", err)
+	}
+	if r.Result != "error" || r.Error != "failed" || r.Code != 403 || r.Paging == nil {
+		t.Errorf("unexpected envelope fields %+v", &r)
+	}
+	if len(r.ExtraFields) != 1 || string(r.ExtraFields["request_id"]) != `"req-1"` {
+		t.Errorf("unexpected extra fields %v", r.ExtraFields)
+	}
+
+	// a type mismatch is reported once the whole envelope is read
+	r = Response{}
+	err = json.Unmarshal([]byte(`{"code":"x","result":"success","data":{}}`), &r)
+	var typeErr *json.UnmarshalTypeError
+	if !errors.As(err, &typeErr) || r.Result != "success" || string(r.Data) != "{}" {
+		t.Errorf("unexpected result for type mismatch %+v, %v", &r, err)
+	}
+
+	if err := json.Unmarshal([]byte(`[]`), &Response{}); !errors.As(err, &typeErr) {
+		t.Errorf("expected type error for non object envelope, got %v", err)
+	}
+
+	var target map[string]any
+	env := &dataEnvelope{Response: &Response{}, Data: &dataTarget{ctx: context.Background(), target: &target}}
+	if err := json.Unmarshal([]byte(`{"result":"success","data":{"a":1}}`), env); err != nil || target["a"] != float64(1) || !env.Data.found {
+		t.Errorf("data was not decoded into target: %v, %v", target, err)
+	}
+	env = &dataEnvelope{Response: &Response{}, Data: &dataTarget{ctx: context.Background(), target: &target}}
+	if err := json.Unmarshal([]byte(`{"result":"success","data":null}`), env); err != nil || env.Data != nil {
+		t.Errorf("null data should clear the target, got %+v, %v", env.Data, err)
+	}
+}
+
+func TestPreconnect(t *testing.T) {
+	var conns atomic.Int32
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"result":"success","data":null}`))
+	}))
+	srv.Config.ConnState = func(c net.Conn, st http.ConnState) {
+		if st == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	srv.Start()
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+	ctx := context.WithValue(context.Background(), BackendURL, u)
+
+	if err := Preconnect(ctx); err != nil {
+		t.Fatalf("Preconnect failed: %s", err)
+	}
+	if _, err := Do(ctx, "Test:ping", "GET", nil); err != nil {
+		t.Fatalf("Do failed: %s", err)
+	}
+	if n := conns.Load(); n != 1 {
+		t.Errorf("expected request to reuse preconnected connection, got %d connections", n)
+	}
+}
+
+func TestCoalesceGET(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		time.Sleep(100 * time.Millisecond)
+		w.Write([]byte(`{"result":"success","data":{"Value":1}}`))
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+	ctx := context.WithValue(context.Background(), BackendURL, u)
+
+	CoalesceGET = true
+	defer func() { CoalesceGET = false }()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var v struct{ Value int }
+			if err := Apply(ctx, "Test:get", "GET", Param{"a": 1}, &v); err != nil || v.Value != 1 {
+				t.Errorf("Apply failed: %v %+v", err, v)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := hits.Load(); n != 1 {
+		t.Errorf("expected a single upstream request, got %d", n)
+	}
+}
+
+func TestCoalesceCancel(t *testing.T) {
+	var hits atomic.Int32
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		<-release
+		w.Write([]byte(`{"result":"success","data":{"Value":1}}`))
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+	ctx := context.WithValue(context.Background(), BackendURL, u)
+
+	CoalesceGET = true
+	defer func() { CoalesceGET = false }()
+
+	// the first caller gives up, the request goes on for the other one
+	lctx, cancel := context.WithCancel(ctx)
+	leader := make(chan error, 1)
+	go func() {
+		_, err := Do(lctx, "Test:get", "GET", nil)
+		leader <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	waiter := make(chan error, 1)
+	go func() {
+		var v struct{ Value int }
+		err := Apply(ctx, "Test:get", "GET", nil, &v)
+		if err == nil && v.Value != 1 {
+			err = fmt.Errorf("unexpected value %d", v.Value)
+		}
+		waiter <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	if err := <-leader; !errors.Is(err, context.Canceled) {
+		t.Errorf("expected leader to be cancelled, got %v", err)
+	}
+
+	// a waiter with a short deadline does not wait for the request
+	sctx, scancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer scancel()
+	if _, err := Do(sctx, "Test:get", "GET", nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline error, got %v", err)
+	}
+
+	close(release)
+	if err := <-waiter; err != nil {
+		t.Errorf("waiter failed: %s", err)
+	}
+	if n := hits.Load(); n != 1 {
+		t.Errorf("expected a single upstream request, got %d", n)
+	}
+}
+
+func TestResponseCache(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Write([]byte(`{"result":"success","data":{"Value":1}}`))
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+	ctx := context.WithValue(context.Background(), BackendURL, u)
+
+	Cache = NewResponseCache(time.Minute)
+	defer func() { Cache = nil }()
+
+	for i := 0; i < 3; i++ {
+		if _, err := Do(ctx, "Test/obj", "GET", nil); err != nil {
+			t.Fatalf("Do failed: %s", err)
+		}
+	}
+	if n := hits.Load(); n != 1 {
+		t.Errorf("expected responses to be cached, got %d requests", n)
+	}
+
+	if _, err := Do(ctx, "Test/obj:update", "POST", Param{"a": 1}); err != nil {
+		t.Fatalf("Do failed: %s", err)
+	}
+	if _, err := Do(ctx, "Test/obj", "GET", nil); err != nil {
+		t.Fatalf("Do failed: %s", err)
+	}
+	if n := hits.Load(); n != 3 {
+		t.Errorf("expected cache to be invalidated by update, got %d requests", n)
+	}
+
+	// responses depend on the locale and on request mutators
+	for _, lctx := range []context.Context{WithLocale(ctx, "ja-JP"), WithLocale(ctx, "en-US"), WithLocale(ctx, "ja-JP"), WithRequestMutator(ctx, func(r *http.Request) {})} {
+		if _, err := Do(lctx, "Test/obj", "GET", nil); err != nil {
+			t.Fatalf("Do failed: %s", err)
+		}
+	}
+	if n := hits.Load(); n != 6 {
+		t.Errorf("expected one request per locale and mutated request, got %d requests", n)
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	var cur, peak atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := cur.Add(1)
+		defer cur.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		w.Write([]byte(`{"result":"success","data":null}`))
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+	ctx := WithBackend(context.Background(), u)
+
+	Limiter = NewConcurrencyLimiter(2)
+	defer func() { Limiter = nil }()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := Do(ctx, "Test:limit", "GET", nil); err != nil {
+				t.Errorf("Do failed: %s", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if p := peak.Load(); p > 2 {
+		t.Errorf("expected at most 2 concurrent requests, got %d", p)
+	}
+}
+
+func TestRetryBudget(t *testing.T) {
+	b := NewRetryBudget(0.1, 1)
+	for i := 0; i < 20; i++ {
+		b.request()
+	}
+	allowed := 0
+	for i := 0; i < 10; i++ {
+		if b.allow() {
+			allowed++
+		}
+	}
+	if allowed != 2 {
+		t.Errorf("expected 2 retries allowed out of 20 requests, got %d", allowed)
+	}
+}
+
+func TestFakeClock(t *testing.T) {
+	fc := NewFakeClock(time.Unix(1600000000, 0))
+	DefaultClock = fc
+	defer func() { DefaultClock = realClock{} }()
+
+	done := make(chan error)
+	go func() {
+		done <- sleep(context.Background(), time.Hour)
+	}()
+	for fc.Timers() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	fc.Advance(30 * time.Minute)
+	select {
+	case <-done:
+		t.Fatalf("sleep returned too early")
+	default:
+	}
+
+	fc.Advance(30 * time.Minute)
+	if err := <-done; err != nil {
+		t.Errorf("sleep failed: %s", err)
+	}
+	if !now().Equal(time.Unix(1600003600, 0)) {
+		t.Errorf("unexpected time %s", now())
+	}
+}
+
+func TestDefaultTimeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(200 * time.Millisecond):
+		}
+		w.Write([]byte(`{"result":"success","data":null}`))
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+	ctx := context.WithValue(context.Background(), BackendURL, u)
+
+	DefaultTimeout = 50 * time.Millisecond
+	defer func() { DefaultTimeout = 0 }()
+
+	if _, err := Do(ctx, "Test/slow", "GET", nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline exceeded, got %v", err)
+	}
+
+	// contexts with a deadline are not changed
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if _, err := Do(ctx, "Test/slow", "GET", nil); err != nil {
+		t.Errorf("Do failed: %s", err)
+	}
+}
+
+func TestOnError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"result":"error","error":"not found","code":404}`))
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+	ctx := context.WithValue(context.Background(), BackendURL, u)
+
+	var got *RequestInfo
+	OnError = func(ctx context.Context, info *RequestInfo) { got = info }
+	defer func() { OnError = nil }()
+
+	if _, err := Do(ctx, "Test/missing", "GET", nil); err == nil {
+		t.Fatalf("expected error")
+	}
+	if got == nil || got.Path != "Test/missing" || got.Method != "GET" || got.Attempts != 1 || got.Err == nil {
+		t.Errorf("unexpected error info %+v", got)
+	}
+}
+
+func TestTokenRenewReplay(t *testing.T) {
+	var bodies []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/_special/rest/OAuth2:token" {
+			w.Write([]byte(`{"result":"success","data":{"access_token":"new","token_type":"Bearer"}}`))
+			return
+		}
+		buf, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(buf))
+		if r.Header.Get("Authorization") != "Bearer new" {
+			w.Write([]byte(`{"result":"error","token":"invalid_request_token","extra":"token_expired"}`))
+			return
+		}
+		w.Write([]byte(`{"result":"success","data":null}`))
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+	ctx := context.WithValue(context.Background(), BackendURL, u)
+	ctx = (&Token{AccessToken: "old", RefreshToken: "refresh", ClientID: "client"}).Use(ctx)
+
+	if _, err := Do(ctx, "Test/obj:update", "POST", Param{"a": 1}); err != nil {
+		t.Fatalf("Do failed: %s", err)
+	}
+	if len(bodies) != 2 || bodies[0] != `{"a":1}` || bodies[1] != bodies[0] {
+		t.Errorf("expected body to be sent twice, got %q", bodies)
+	}
+}
+
+func TestTokenRenewLimiter(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/_special/rest/OAuth2:token" {
+			w.Write([]byte(`{"result":"success","data":{"access_token":"new","token_type":"Bearer"}}`))
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer new" {
+			w.Write([]byte(`{"result":"error","token":"invalid_request_token","extra":"token_expired"}`))
+			return
+		}
+		w.Write([]byte(`{"result":"success","data":null}`))
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ctx = context.WithValue(ctx, BackendURL, u)
+	ctx = (&Token{AccessToken: "old", RefreshToken: "refresh", ClientID: "client"}).Use(ctx)
+
+	// the renewal must not wait for the slot held by the expired call
+	Limiter = NewConcurrencyLimiter(1)
+	defer func() { Limiter = nil }()
+	if _, err := Do(ctx, "Test/obj", "GET", nil); err != nil {
+		t.Fatalf("Do failed: %s", err)
+	}
+	if n := Limiter.InFlight(); n != 0 {
+		t.Errorf("expected no request in flight, got %d", n)
+	}
+}
+
+func TestTokenRenewFailure(t *testing.T) {
+	var renewals int
+	grant := "ok"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/_special/rest/OAuth2:token" {
+			renewals += 1
+			switch {
+			case grant == "invalid":
+				w.Write([]byte(`{"result":"error","error":"invalid refresh token","code":400}`))
+			case renewals == 1:
+				w.WriteHeader(http.StatusServiceUnavailable)
+			default:
+				w.Write([]byte(`{"result":"success","data":{"access_token":"new","token_type":"Bearer"}}`))
+			}
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer new" {
+			w.Write([]byte(`{"result":"error","token":"invalid_request_token","extra":"token_expired"}`))
+			return
+		}
+		w.Write([]byte(`{"result":"success","data":null}`))
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+	ctx := context.WithValue(context.Background(), BackendURL, u)
+
+	// a temporary failure is retried once
+	tctx := (&Token{AccessToken: "old", RefreshToken: "refresh", ClientID: "client"}).Use(ctx)
+	if _, err := Do(tctx, "Test/obj", "GET", nil); err != nil || renewals != 2 {
+		t.Fatalf("expected renewal to be retried, got %d renewals: %v", renewals, err)
+	}
+
+	grant = "invalid"
+	tctx = (&Token{AccessToken: "old", RefreshToken: "refresh", ClientID: "client"}).Use(ctx)
+	_, err := Do(tctx, "Test/obj", "GET", nil)
+	var renewErr *TokenRenewalError
+	if !errors.As(err, &renewErr) || !errors.Is(err, ErrTokenRenewalFailed) || renewErr.Temporary() {
+		t.Errorf("expected permanent renewal error, got %v", err)
+	}
+
+	tctx = (&Token{AccessToken: "old", ClientID: "client"}).Use(ctx)
+	if _, err := Do(tctx, "Test/obj", "GET", nil); !errors.Is(err, ErrNoRefreshToken) || !errors.Is(err, ErrTokenRenewalFailed) {
+		t.Errorf("expected missing refresh token error, got %v", err)
+	}
+}
+
+func TestValidatePath(t *testing.T) {
+	valid := []string{"User:get", "Blog/Article/blg-123", "Cloud/Aws/Bucket/Upload/x:signV4", string(NewEndpoint("Blog", "Article").Method("list"))}
+	for _, p := range valid {
+		if err := ValidatePath(p); err != nil {
+			t.Errorf("expected %q to be valid, got %s", p, err)
+		}
+	}
+	invalid := []string{"", "/User:get", "User//get", "User/", "User:", "User:get:more", "User:get/x", "User:get?x=1", "User get"}
+	for _, p := range invalid {
+		if err := ValidatePath(p); !errors.Is(err, ErrInvalidPath) {
+			t.Errorf("expected %q to be invalid, got %v", p, err)
+		}
+	}
+
+	if _, err := Do(context.Background(), "/User:get", "GET", nil); !errors.Is(err, ErrInvalidPath) {
+		t.Errorf("expected Do to reject an invalid path, got %v", err)
+	}
+}
@@ -0,0 +1,131 @@
+// Package restfs exposes files stored on the API as a fs.FS.
+//
+// The API endpoint given to New must implement two methods, both receiving
+// the slash-separated path of an entry as "path" parameter ("." being the
+// root):
+//
+//	<endpoint>:stat  returns the Entry at path
+//	<endpoint>:list  returns the Entry list of the directory at path
+//
+// File contents are downloaded from the Entry's Url, using ranged requests
+// when seeking or reading at an offset.
+package restfs
+
+import (
+	"context"
+	"io/fs"
+	"net/http"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/KarpelesLab/rest"
+)
+
+// Entry is a file or directory as described by the API
+type Entry struct {
+	Name      string    `json:"name"`
+	Size      int64     `json:"size"`
+	Mime      string    `json:"mime,omitempty"`
+	Modified  rest.Time `json:"modified"`
+	Directory bool      `json:"dir,omitempty"`
+	Url       string    `json:"url,omitempty"` // download url, for files
+}
+
+// FS is a fs.FS backed by API calls. It implements fs.StatFS and fs.ReadDirFS.
+type FS struct {
+	ctx      context.Context
+	endpoint string
+
+	// Client is used to download files, defaults to rest.RestHttpClient
+	Client *http.Client
+}
+
+// New returns a FS listing files through endpoint. API calls are performed
+// with ctx, which should carry authentication.
+func New(ctx context.Context, endpoint string) *FS {
+	return &FS{ctx: ctx, endpoint: endpoint}
+}
+
+func (f *FS) client() *http.Client {
+	if f.Client != nil {
+		return f.Client
+	}
+	return rest.RestHttpClient
+}
+
+func (f *FS) Open(name string) (fs.File, error) {
+	e, err := f.stat("open", name)
+	if err != nil {
+		return nil, err
+	}
+	if e.Directory {
+		return &dir{fs: f, name: name, entry: e}, nil
+	}
+	return &file{fs: f, name: name, entry: e}, nil
+}
+
+func (f *FS) Stat(name string) (fs.FileInfo, error) {
+	e, err := f.stat("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return &fileInfo{e}, nil
+}
+
+func (f *FS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+	return f.list(name)
+}
+
+func (f *FS) stat(op, name string) (*Entry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	var e *Entry
+	if err := rest.Apply(f.ctx, f.endpoint+":stat", "GET", rest.Param{"path": name}, &e); err != nil {
+		return nil, &fs.PathError{Op: op, Path: name, Err: err}
+	}
+	if e == nil {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	// use the name we know about rather than trusting the API
+	e.Name = path.Base(name)
+	if name == "." {
+		e.Directory = true
+	}
+	return e, nil
+}
+
+func (f *FS) list(name string) ([]fs.DirEntry, error) {
+	var entries []*Entry
+	if err := rest.Apply(f.ctx, f.endpoint+":list", "GET", rest.Param{"path": name}, &entries); err != nil {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+	}
+	res := make([]fs.DirEntry, 0, len(entries))
+	for _, e := range entries {
+		res = append(res, fs.FileInfoToDirEntry(&fileInfo{e}))
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Name() < res[j].Name() })
+	return res, nil
+}
+
+// fileInfo implements fs.FileInfo for an Entry
+type fileInfo struct {
+	e *Entry
+}
+
+func (i *fileInfo) Name() string       { return i.e.Name }
+func (i *fileInfo) Size() int64        { return i.e.Size }
+func (i *fileInfo) ModTime() time.Time { return i.e.Modified.Time }
+func (i *fileInfo) IsDir() bool        { return i.e.Directory }
+func (i *fileInfo) Sys() any           { return i.e }
+
+func (i *fileInfo) Mode() fs.FileMode {
+	if i.e.Directory {
+		return fs.ModeDir | 0555
+	}
+	return 0444
+}
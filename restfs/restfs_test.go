@@ -0,0 +1,97 @@
+package restfs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/KarpelesLab/rest"
+)
+
+func TestFS(t *testing.T) {
+	files := map[string]string{
+		"hello.txt":     "hello world",
+		"dir/a.txt":     "aaa",
+		"dir/sub/b.txt": strings.Repeat("b", 10000),
+	}
+	isDir := func(name string) bool {
+		if name == "." {
+			return true
+		}
+		for k := range files {
+			if strings.HasPrefix(k, name+"/") {
+				return true
+			}
+		}
+		return false
+	}
+
+	var srv *httptest.Server
+	entry := func(name string) map[string]any {
+		e := map[string]any{"name": path.Base(name), "modified": map[string]any{"unix": 1600000000, "us": 0}}
+		if isDir(name) {
+			e["dir"] = true
+		} else {
+			e["size"] = len(files[name])
+			e["url"] = srv.URL + "/dl/" + name
+		}
+		return e
+	}
+	reply := func(w http.ResponseWriter, data any) {
+		json.NewEncoder(w).Encode(map[string]any{"result": "success", "data": data})
+	}
+
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if name, ok := strings.CutPrefix(r.URL.Path, "/dl/"); ok {
+			http.ServeContent(w, r, name, time.Unix(1600000000, 0), bytes.NewReader([]byte(files[name])))
+			return
+		}
+		var p struct{ Path string }
+		json.Unmarshal([]byte(r.URL.Query().Get("_")), &p)
+
+		switch r.URL.Path {
+		case "/_special/rest/Files:stat":
+			if _, ok := files[p.Path]; !ok && !isDir(p.Path) {
+				json.NewEncoder(w).Encode(map[string]any{"result": "error", "error": "not found", "code": 404})
+				return
+			}
+			reply(w, entry(p.Path))
+		case "/_special/rest/Files:list":
+			seen := make(map[string]bool)
+			list := []any{}
+			for k := range files {
+				if p.Path != "." {
+					var ok bool
+					if k, ok = strings.CutPrefix(k, p.Path+"/"); !ok {
+						continue
+					}
+				}
+				child, _, _ := strings.Cut(k, "/")
+				if seen[child] {
+					continue
+				}
+				seen[child] = true
+				list = append(list, entry(path.Join(p.Path, child)))
+			}
+			reply(w, list)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	u, _ := url.Parse(srv.URL)
+	fsys := New(rest.WithBackend(context.Background(), u), "Files")
+
+	if err := fstest.TestFS(fsys, "hello.txt", "dir/a.txt", "dir/sub/b.txt"); err != nil {
+		t.Fatal(err)
+	}
+}
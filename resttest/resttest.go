@@ -0,0 +1,179 @@
+// Package resttest implements test assertions for API responses.
+//
+//	res, err := rest.Do(ctx, "User/@", "GET", nil)
+//	resttest.RequireSuccess(t, res, err)
+//	resttest.AssertPath(t, res, "Profile/Display_Name", "John")
+package resttest
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/KarpelesLab/pjson"
+	"github.com/KarpelesLab/rest"
+)
+
+// RequireSuccess stops the test if the request failed or its result is not
+// "success"
+func RequireSuccess(t testing.TB, res *rest.Response, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("request failed: %s", err)
+	}
+	if res == nil {
+		t.Fatalf("request returned no response")
+	}
+	if res.Result != "success" {
+		t.Fatalf("expected result success, got %s: %s", res.Result, res.Error)
+	}
+}
+
+// RequireErrorCode stops the test unless err is an API or HTTP error with
+// the given code
+func RequireErrorCode(t testing.TB, err error, code int) {
+	t.Helper()
+	if err == nil {
+		t.Fatalf("expected error with code %d, request succeeded", code)
+	}
+	var restErr *rest.Error
+	var httpErr *rest.HttpError
+	switch {
+	case errors.As(err, &restErr) && restErr.Response != nil:
+		if restErr.Response.Code != code {
+			t.Fatalf("expected error with code %d, got code %d: %s", code, restErr.Response.Code, err)
+		}
+	case errors.As(err, &httpErr):
+		if httpErr.Code != code {
+			t.Fatalf("expected error with code %d, got code %d: %s", code, httpErr.Code, err)
+		}
+	default:
+		t.Fatalf("expected error with code %d, got %s", code, err)
+	}
+}
+
+// AssertDataEquals checks that the data of res is the JSON equivalent of
+// expected, and reports the differences otherwise. expected can be a JSON
+// string, []byte, or any value that can be marshalled.
+func AssertDataEquals(t testing.TB, res *rest.Response, expected any) {
+	t.Helper()
+	if res == nil {
+		t.Errorf("expected data, got no response")
+		return
+	}
+	got, err := normalize(res.Data)
+	if err != nil {
+		t.Errorf("invalid response data: %s", err)
+		return
+	}
+	want, err := normalize(expected)
+	if err != nil {
+		t.Errorf("invalid expected data: %s", err)
+		return
+	}
+	if got != want {
+		t.Errorf("response data mismatch (-want +got):\n%s", diff(want, got))
+	}
+}
+
+// AssertPath checks that the value at path in the data of res (as with
+// Response.Get) is the JSON equivalent of expected. As with
+// AssertDataEquals, strings holding valid JSON are compared as JSON.
+func AssertPath(t testing.TB, res *rest.Response, path string, expected any) {
+	t.Helper()
+	if res == nil {
+		t.Errorf("expected %s, got no response", path)
+		return
+	}
+	v, err := res.Get(path)
+	if err != nil {
+		t.Errorf("failed to get %s: %s", path, err)
+		return
+	}
+	got, err := normalize(v)
+	if err != nil {
+		t.Errorf("invalid value at %s: %s", path, err)
+		return
+	}
+	want, err := normalize(expected)
+	if err != nil {
+		t.Errorf("invalid expected value: %s", err)
+		return
+	}
+	if got != want {
+		t.Errorf("value mismatch at %s (-want +got):\n%s", path, diff(want, got))
+	}
+}
+
+// normalize returns v as indented JSON with sorted keys
+func normalize(v any) (string, error) {
+	var buf []byte
+	switch x := v.(type) {
+	case string:
+		buf = []byte(x)
+	case []byte:
+		buf = x
+	case pjson.RawMessage:
+		buf = x
+	default:
+		var err error
+		if buf, err = pjson.Marshal(v); err != nil {
+			return "", err
+		}
+	}
+	if len(buf) == 0 {
+		buf = []byte("null")
+	}
+
+	var val any
+	if err := pjson.Unmarshal(buf, &val); err != nil {
+		if _, ok := v.(string); ok {
+			// plain string value
+			val = v
+		} else {
+			return "", err
+		}
+	}
+	res, err := pjson.MarshalIndent(val, "", "  ")
+	return string(res), err
+}
+
+// diff returns a line diff between a and b
+func diff(a, b string) string {
+	al := strings.Split(a, "\n")
+	bl := strings.Split(b, "\n")
+
+	// longest common subsequence table
+	lcs := make([][]int, len(al)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(bl)+1)
+	}
+	for i := len(al) - 1; i >= 0; i-- {
+		for j := len(bl) - 1; j >= 0; j-- {
+			if al[i] == bl[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var sb strings.Builder
+	i, j := 0, 0
+	for i < len(al) || j < len(bl) {
+		switch {
+		case i < len(al) && j < len(bl) && al[i] == bl[j]:
+			fmt.Fprintf(&sb, "  %s\n", al[i])
+			i++
+			j++
+		case j < len(bl) && (i == len(al) || lcs[i][j+1] >= lcs[i+1][j]):
+			fmt.Fprintf(&sb, "+ %s\n", bl[j])
+			j++
+		default:
+			fmt.Fprintf(&sb, "- %s\n", al[i])
+			i++
+		}
+	}
+	return sb.String()
+}
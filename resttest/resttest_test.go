@@ -0,0 +1,45 @@
+package resttest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/KarpelesLab/rest"
+)
+
+func TestAssertions(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/_special/rest/Test/obj":
+			w.Write([]byte(`{"result":"success","data":{"Id":"obj-1","Tags":["a","b"],"Size":3}}`))
+		default:
+			w.Write([]byte(`{"result":"error","error":"not found","code":404}`))
+		}
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+	ctx := rest.WithBackend(context.Background(), u)
+
+	res, err := rest.Do(ctx, "Test/obj", "GET", nil)
+	RequireSuccess(t, res, err)
+	AssertDataEquals(t, res, `{"Size":3,"Id":"obj-1","Tags":["a","b"]}`)
+	AssertDataEquals(t, res, map[string]any{"Id": "obj-1", "Tags": []string{"a", "b"}, "Size": 3})
+	AssertPath(t, res, "Id", "obj-1")
+	AssertPath(t, res, "Size", 3)
+
+	_, err = rest.Do(ctx, "Test/missing", "GET", nil)
+	RequireErrorCode(t, err, 404)
+}
+
+func TestDiff(t *testing.T) {
+	want, _ := normalize(`{"A":1,"B":2}`)
+	got, _ := normalize(`{"A":1,"B":3}`)
+	d := diff(want, got)
+	if !strings.Contains(d, `-   "B": 2`) || !strings.Contains(d, `+   "B": 3`) || !strings.Contains(d, `    "A": 1,`) {
+		t.Errorf("unexpected diff:\n%s", d)
+	}
+}
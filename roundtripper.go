@@ -0,0 +1,128 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// RoundTripper is a http.RoundTripper sending requests the way this package
+// does, so third party SDKs and generated clients can use its settings by
+// plugging it in their own http.Client:
+//
+//	client := &http.Client{Transport: rest.NewRoundTripper(tok.Use(ctx))}
+//
+// Requests without a host are sent to the backend. The token, locale, API
+// version and request mutators of the context given to NewRoundTripper are
+// applied, unless the request context carries its own token. Expired tokens
+// are renewed when the server answers 401, and requests are retried on
+// temporary failures according to the EndpointPolicy of their path.
+type RoundTripper struct {
+	Transport http.RoundTripper // transport requests are sent with, defaults to the one of the http client of the context
+
+	ctx context.Context
+}
+
+// NewRoundTripper returns a RoundTripper applying the settings of ctx
+func NewRoundTripper(ctx context.Context) *RoundTripper {
+	return &RoundTripper{ctx: ctx}
+}
+
+func (rt *RoundTripper) transport() http.RoundTripper {
+	if rt.Transport != nil {
+		return rt.Transport
+	}
+	if t := httpClient(rt.ctx).Transport; t != nil {
+		return t
+	}
+	return http.DefaultTransport
+}
+
+func (rt *RoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	req = req.Clone(ctx)
+	if req.URL.Host == "" {
+		backend := backendURL(rt.ctx)
+		req.URL.Scheme = backend.Scheme
+		req.URL.Host = backend.Host
+	}
+	if req.Header.Get("Sec-Rest-Version") == "" {
+		if v := apiVersion(rt.ctx); v != "" {
+			req.Header.Set("Sec-Rest-Version", v)
+		}
+	}
+	if req.Header.Get("Accept-Language") == "" {
+		setLocaleHeaders(rt.ctx, req)
+	}
+	mutateRequest(rt.ctx, req)
+
+	token := contextToken(ctx)
+	if token == nil {
+		token = contextToken(rt.ctx)
+	}
+
+	path := strings.TrimPrefix(req.URL.Path, PathPrefix)
+	var resp *http.Response
+	attempt := 0
+	_, err := policyFor(path).retry(ctx, req.Method, path, func() (*Response, error) {
+		if attempt > 0 {
+			if err := rewindBody(req); err != nil {
+				return nil, err
+			}
+		}
+		attempt += 1
+		r, err := rt.send(req, token)
+		if err != nil {
+			return nil, err
+		}
+		resp = r
+		if temporaryStatus(r.StatusCode) {
+			// buffer the body so the response can be returned if retries
+			// are exhausted
+			body, err := io.ReadAll(r.Body)
+			r.Body.Close()
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			if err != nil {
+				return nil, err
+			}
+			return nil, newHttpError(r, body, nil)
+		}
+		return nil, nil
+	})
+	var httpErr *HttpError
+	if err != nil && !errors.As(err, &httpErr) {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// send performs req with token, renewing the token if it has expired
+func (rt *RoundTripper) send(req *http.Request, token *Token) (*http.Response, error) {
+	if token == nil {
+		return rt.transport().RoundTrip(req)
+	}
+	req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+	resp, err := rt.transport().RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized || token.RefreshToken == "" {
+		return resp, err
+	}
+
+	// token may have expired, renew and send again
+	if err := rewindBody(req); err != nil {
+		return resp, nil
+	}
+	if err := token.renew(rt.ctx); err != nil {
+		return resp, nil
+	}
+	resp.Body.Close()
+	req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+	resp, err = rt.transport().RoundTrip(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request after token renewal: %w", err)
+	}
+	return resp, nil
+}
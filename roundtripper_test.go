@@ -0,0 +1,45 @@
+package rest
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestRoundTripper(t *testing.T) {
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls += 1
+		if r.Header.Get("Authorization") != "Bearer abc" || r.Header.Get("Accept-Language") != "fr-FR" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if calls == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+
+	SetPolicy("Sdk", &EndpointPolicy{Retries: 2, RetryDelay: 1})
+	defer SetPolicy("Sdk", nil)
+
+	ctx := WithLocale(context.WithValue(context.Background(), BackendURL, u), "fr-FR")
+	ctx = (&Token{AccessToken: "abc"}).Use(ctx)
+	client := &http.Client{Transport: NewRoundTripper(ctx)}
+
+	resp, err := client.Get("/_special/rest/Sdk/thing")
+	if err != nil {
+		t.Fatalf("request failed: %s", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || string(body) != "ok" || calls != 2 {
+		t.Errorf("unexpected response %d %q after %d calls", resp.StatusCode, body, calls)
+	}
+}
@@ -1,16 +1,165 @@
-//go:build !wasm
+//go:build !wasm && !tinygo
 
 package rest
 
-import "net/http"
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+)
 
 type RouterType struct {
+	// Token, if set, will be attached to requests proxied upstream that do
+	// not already carry an Authorization header
+	Token *Token
+	// UseContextToken causes a Token found in the inbound request's context
+	// (see Token.Use) to be attached, taking precedence over Token
+	UseContextToken bool
+
+	// Cache, if set, is used to store responses to GET requests for CacheTTL
+	Cache    RouterCache
+	CacheTTL time.Duration
+	// CacheKey returns the key under which the response to a given request is
+	// cached, or an empty string if it shouldn't be cached. By default the
+	// request URI is used, and requests carrying credentials are not cached.
+	// Keys are scoped to the backend the request is sent to.
+	CacheKey func(req *http.Request) string
+
+	// MaxBodySize, if positive, is the maximum size of request bodies the
+	// Router will forward. Larger requests are rejected with a 413 error.
+	MaxBodySize int64
+	// BufferBody causes request bodies to be read fully before being sent
+	// upstream, keeping up to MemoryBodySize bytes (1MB by default) in memory
+	// and spilling the rest to a temporary file. By default bodies are
+	// streamed to the backend as they are received.
+	BufferBody     bool
+	MemoryBodySize int64
+
+	// AccessLog enables logging of each proxied request via slog
+	AccessLog bool
+	// Metrics, if set, receives information on proxied requests instead of
+	// the package-level Metrics
+	Metrics MetricsCollector
 }
 
 var Router *RouterType = &RouterType{}
 
 func (h *RouterType) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if h.AccessLog || h.metrics() != nil {
+		rw := &routerResponseWriter{ResponseWriter: w}
+		defer h.observe(req, rw, req.URL.Path, time.Now())
+		w = rw
+	}
+
+	// attach the token first, the cache key depends on the credentials
+	if token := h.token(req); token != nil && req.Header.Get("Authorization") == "" {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token.AccessToken))
+	}
+
+	key := h.cacheKey(req)
+	if key != "" {
+		if res, ok := h.Cache.Get(key); ok {
+			res.serve(w)
+			return
+		}
+		cw := &routerCacheWriter{ResponseWriter: w}
+		defer func() {
+			if res := cw.response(); res != nil {
+				h.Cache.Set(key, res, h.CacheTTL)
+			}
+		}()
+		w = cw
+	}
+
+	cleanup, err := h.prepareBody(w, req)
+	if err != nil {
+		code := http.StatusBadRequest
+		var mbe *http.MaxBytesError
+		if errors.As(err, &mbe) {
+			code = http.StatusRequestEntityTooLarge
+		}
+		if Debug {
+			slog.ErrorContext(req.Context(), "[rest] failed to read request body: "+err.Error(), "event", "rest:router_error", "rest:request", req.URL.Path)
+		}
+		writeErrorResponse(w, code)
+		return
+	}
+	defer cleanup()
+
 	// fallback to PHP, add prefix for rest
 	req.URL.Path = "/_special/rest" + req.URL.Path
 	SystemProxy.ServeHTTP(w, req)
 }
+
+// token returns the token to attach to the proxied request, if any
+func (h *RouterType) token(req *http.Request) *Token {
+	if h.UseContextToken {
+		if t, ok := req.Context().Value(tokenValue(0)).(*Token); ok && t != nil {
+			return t
+		}
+	}
+	return h.Token
+}
+
+func (h *RouterType) metrics() MetricsCollector {
+	if h.Metrics != nil {
+		return h.Metrics
+	}
+	return Metrics
+}
+
+// observe logs and reports a proxied request once it has completed
+func (h *RouterType) observe(req *http.Request, rw *routerResponseWriter, path string, start time.Time) {
+	info := &RequestInfo{
+		Method:   req.Method,
+		Path:     path,
+		Status:   rw.code,
+		Duration: time.Since(start),
+		BytesIn:  rw.bytes,
+		BytesOut: req.ContentLength,
+		Proxied:  true,
+	}
+	if info.Status == 0 {
+		// nothing written, net/http will answer 200
+		info.Status = http.StatusOK
+	}
+
+	if h.AccessLog {
+		ctx := req.Context()
+		slog.InfoContext(ctx, fmt.Sprintf("[rest] proxy %s %s => %d (%s)", info.Method, info.Path, info.Status, info.Duration), "event", "rest:access", "rest:method", info.Method, "rest:request", info.Path, "rest:status", info.Status, "rest:duration", info.Duration, "rest:bytes", info.BytesIn)
+	}
+	if m := h.metrics(); m != nil {
+		m.ObserveRequest(info)
+	}
+}
+
+// routerResponseWriter keeps track of the status and size of a response
+type routerResponseWriter struct {
+	http.ResponseWriter
+	code  int
+	bytes int64
+}
+
+func (w *routerResponseWriter) WriteHeader(code int) {
+	if w.code == 0 {
+		w.code = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *routerResponseWriter) Write(b []byte) (int, error) {
+	if w.code == 0 {
+		w.code = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	return n, err
+}
+
+func (w *routerResponseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
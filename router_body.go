@@ -0,0 +1,90 @@
+//go:build !wasm && !tinygo
+
+package rest
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"os"
+)
+
+// defaultMemoryBodySize is the amount of a buffered request body kept in
+// memory when MemoryBodySize is not set
+const defaultMemoryBodySize = 1024 * 1024
+
+// prepareBody applies the body size limit and buffering settings of the
+// router to req. The returned function must be called once the request has
+// been processed.
+func (h *RouterType) prepareBody(w http.ResponseWriter, req *http.Request) (func(), error) {
+	noop := func() {}
+	if req.Body == nil || req.Body == http.NoBody {
+		return noop, nil
+	}
+
+	if h.MaxBodySize > 0 {
+		if req.ContentLength > h.MaxBodySize {
+			return nil, &http.MaxBytesError{Limit: h.MaxBodySize}
+		}
+		req.Body = http.MaxBytesReader(w, req.Body, h.MaxBodySize)
+	}
+
+	if !h.BufferBody {
+		// streaming passthrough
+		return noop, nil
+	}
+
+	memSize := h.MemoryBodySize
+	if memSize <= 0 {
+		memSize = defaultMemoryBodySize
+	}
+
+	buf := &bytes.Buffer{}
+	n, err := io.CopyN(buf, req.Body, memSize+1)
+	if err == io.EOF {
+		// body fits in memory
+		req.Body.Close()
+		data := buf.Bytes()
+		req.Body = io.NopCloser(bytes.NewReader(data))
+		req.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(data)), nil
+		}
+		req.ContentLength = n
+		req.TransferEncoding = nil
+		return noop, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// spill to disk
+	tmpf, err := os.CreateTemp("", "restbody*.bin")
+	if err != nil {
+		return nil, err
+	}
+	cleanup := func() {
+		tmpf.Close()
+		os.Remove(tmpf.Name())
+	}
+
+	if _, err := buf.WriteTo(tmpf); err != nil {
+		cleanup()
+		return nil, err
+	}
+	remain, err := io.Copy(tmpf, req.Body)
+	if err != nil {
+		cleanup()
+		return nil, err
+	}
+	req.Body.Close()
+
+	if _, err := tmpf.Seek(0, io.SeekStart); err != nil {
+		cleanup()
+		return nil, err
+	}
+	req.Body = io.NopCloser(tmpf)
+	req.ContentLength = n + remain
+	req.TransferEncoding = nil
+
+	return cleanup, nil
+}
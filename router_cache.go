@@ -0,0 +1,161 @@
+//go:build !wasm && !tinygo
+
+package rest
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxRouterCacheBody is the largest response body that will be stored in a
+// RouterCache, larger responses are passed through without caching
+const maxRouterCacheBody = 4 * 1024 * 1024
+
+// RouterCache is a store for responses proxied by the Router. Implementations
+// must be safe for concurrent use.
+type RouterCache interface {
+	Get(key string) (*CachedResponse, bool)
+	Set(key string, res *CachedResponse, ttl time.Duration)
+}
+
+// CachedResponse is a response as stored in a RouterCache
+type CachedResponse struct {
+	Code   int
+	Header http.Header
+	Body   []byte
+}
+
+type memoryRouterCacheEntry struct {
+	res     *CachedResponse
+	expires time.Time
+}
+
+type memoryRouterCache struct {
+	entries map[string]*memoryRouterCacheEntry
+	lk      sync.Mutex
+}
+
+// NewMemoryRouterCache returns a RouterCache keeping responses in memory
+func NewMemoryRouterCache() RouterCache {
+	return &memoryRouterCache{entries: make(map[string]*memoryRouterCacheEntry)}
+}
+
+func (c *memoryRouterCache) Get(key string) (*CachedResponse, bool) {
+	c.lk.Lock()
+	defer c.lk.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if now().After(e.expires) {
+		delete(c.entries, key)
+		return nil, false
+	}
+	return e.res, true
+}
+
+func (c *memoryRouterCache) Set(key string, res *CachedResponse, ttl time.Duration) {
+	c.lk.Lock()
+	defer c.lk.Unlock()
+
+	now := now()
+	// drop expired entries so the cache doesn't grow forever
+	for k, e := range c.entries {
+		if now.After(e.expires) {
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = &memoryRouterCacheEntry{res: res, expires: now.Add(ttl)}
+}
+
+// cacheKey returns the key to use to cache the response to req, or an empty
+// string if the request cannot be cached
+func (h *RouterType) cacheKey(req *http.Request) string {
+	if h.Cache == nil || h.CacheTTL <= 0 || req.Method != http.MethodGet {
+		return ""
+	}
+	var key string
+	if h.CacheKey != nil {
+		key = h.CacheKey(req)
+	} else if auth := req.Header.Get("Authorization"); (auth != "" && !h.sharedAuth(auth)) || req.Header.Get("Cookie") != "" {
+		// credentials may change the response, do not share it
+		return ""
+	} else {
+		key = req.URL.RequestURI()
+	}
+	if key == "" {
+		return ""
+	}
+	// the same path on different backends is a different response
+	return backendURL(req.Context()).String() + "\x00" + key
+}
+
+// sharedAuth returns true if auth is the one sent for the token configured
+// on the router, which is the same for all inbound requests
+func (h *RouterType) sharedAuth(auth string) bool {
+	return h.Token != nil && auth == "Bearer "+h.Token.AccessToken
+}
+
+func (c *CachedResponse) serve(w http.ResponseWriter) {
+	for k, v := range c.Header {
+		w.Header()[k] = v
+	}
+	w.WriteHeader(c.Code)
+	w.Write(c.Body)
+}
+
+// routerCacheWriter captures a response while it is sent to the client
+type routerCacheWriter struct {
+	http.ResponseWriter
+	code     int
+	buf      bytes.Buffer
+	overflow bool
+}
+
+func (w *routerCacheWriter) WriteHeader(code int) {
+	if w.code == 0 {
+		w.code = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *routerCacheWriter) Write(b []byte) (int, error) {
+	if w.code == 0 {
+		w.code = http.StatusOK
+	}
+	if !w.overflow {
+		if w.buf.Len()+len(b) > maxRouterCacheBody {
+			w.overflow = true
+			w.buf.Reset()
+		} else {
+			w.buf.Write(b)
+		}
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *routerCacheWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// response returns the captured response if it can be cached
+func (w *routerCacheWriter) response() *CachedResponse {
+	if w.code != http.StatusOK || w.overflow {
+		return nil
+	}
+	hdr := w.Header()
+	if hdr.Get("Set-Cookie") != "" {
+		return nil
+	}
+	cc := strings.ToLower(hdr.Get("Cache-Control"))
+	if strings.Contains(cc, "no-store") || strings.Contains(cc, "private") {
+		return nil
+	}
+	return &CachedResponse{Code: w.code, Header: hdr.Clone(), Body: bytes.Clone(w.buf.Bytes())}
+}
@@ -0,0 +1,259 @@
+//go:build !wasm && !tinygo
+
+package rest
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/KarpelesLab/pjson"
+)
+
+// newRouterTestBackend returns a test server and a function building inbound
+// requests targeting it through the router
+func newRouterTestBackend(t *testing.T, h http.HandlerFunc) (*httptest.Server, func(method, path string) *http.Request) {
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatalf("failed to parse test server url: %s", err)
+	}
+
+	return srv, func(method, path string) *http.Request {
+		req := httptest.NewRequest(method, path, nil)
+		return req.WithContext(context.WithValue(req.Context(), BackendURL, u))
+	}
+}
+
+func TestRouterToken(t *testing.T) {
+	var auth, path string
+	_, newReq := newRouterTestBackend(t, func(w http.ResponseWriter, req *http.Request) {
+		auth = req.Header.Get("Authorization")
+		path = req.URL.Path
+		w.Write([]byte(`{"result":"success","data":{}}`))
+	})
+
+	r := &RouterType{Token: &Token{AccessToken: "configured"}}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, newReq("GET", "/User:get"))
+	if auth != "Bearer configured" {
+		t.Errorf("unexpected authorization header %q", auth)
+	}
+	if path != "/_special/rest/User:get" {
+		t.Errorf("unexpected upstream path %q", path)
+	}
+
+	// context token takes precedence when enabled
+	r.UseContextToken = true
+	req := newReq("GET", "/User:get")
+	req = req.WithContext((&Token{AccessToken: "context"}).Use(req.Context()))
+	r.ServeHTTP(httptest.NewRecorder(), req)
+	if auth != "Bearer context" {
+		t.Errorf("unexpected authorization header %q", auth)
+	}
+
+	// existing authorization is kept
+	req = newReq("GET", "/User:get")
+	req.Header.Set("Authorization", "Bearer frontend")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+	if auth != "Bearer frontend" {
+		t.Errorf("unexpected authorization header %q", auth)
+	}
+}
+
+func TestRouterCache(t *testing.T) {
+	hits := 0
+	_, newReq := newRouterTestBackend(t, func(w http.ResponseWriter, req *http.Request) {
+		hits += 1
+		w.Write([]byte(`{"result":"success","data":{}}`))
+	})
+
+	r := &RouterType{Cache: NewMemoryRouterCache(), CacheTTL: time.Minute}
+
+	for i := 0; i < 3; i++ {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, newReq("GET", "/Misc/Debug:ping"))
+		if w.Body.String() != `{"result":"success","data":{}}` {
+			t.Errorf("unexpected body %q", w.Body.String())
+		}
+	}
+	if hits != 1 {
+		t.Errorf("expected 1 upstream request, got %d", hits)
+	}
+
+	// non-GET requests and requests with credentials bypass the cache
+	r.ServeHTTP(httptest.NewRecorder(), newReq("POST", "/Misc/Debug:ping"))
+	req := newReq("GET", "/Misc/Debug:ping")
+	req.Header.Set("Cookie", "session=x")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+	if hits != 3 {
+		t.Errorf("expected 3 upstream requests, got %d", hits)
+	}
+
+	// tokens taken from the context are per user
+	r.UseContextToken = true
+	for _, user := range []string{"alice", "bob"} {
+		req = newReq("GET", "/Misc/Debug:ping")
+		req = req.WithContext((&Token{AccessToken: user}).Use(req.Context()))
+		r.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	if hits != 5 {
+		t.Errorf("expected 5 upstream requests, got %d", hits)
+	}
+
+	// the same path on another backend is not served from the cache
+	_, newOtherReq := newRouterTestBackend(t, func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(`{"result":"success","data":{"backend":"other"}}`))
+	})
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, newOtherReq("GET", "/Misc/Debug:ping"))
+	if w.Body.String() != `{"result":"success","data":{"backend":"other"}}` {
+		t.Errorf("response cached for another backend was served: %q", w.Body.String())
+	}
+}
+
+type testMetrics struct {
+	infos []*RequestInfo
+}
+
+func (m *testMetrics) ObserveRequest(info *RequestInfo) {
+	m.infos = append(m.infos, info)
+}
+
+func TestRouterMetrics(t *testing.T) {
+	body := `{"result":"error","error":"not found","code":404}`
+	_, newReq := newRouterTestBackend(t, func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(body))
+	})
+
+	m := &testMetrics{}
+	r := &RouterType{Metrics: m}
+	r.ServeHTTP(httptest.NewRecorder(), newReq("GET", "/Missing:thing"))
+
+	if len(m.infos) != 1 {
+		t.Fatalf("expected 1 observed request, got %d", len(m.infos))
+	}
+	info := m.infos[0]
+	if info.Status != http.StatusNotFound || info.Path != "/Missing:thing" || !info.Proxied || info.BytesIn != int64(len(body)) {
+		t.Errorf("unexpected request info %+v", info)
+	}
+}
+
+func TestProxyHeaderPolicy(t *testing.T) {
+	var hdr http.Header
+	_, newReq := newRouterTestBackend(t, func(w http.ResponseWriter, req *http.Request) {
+		hdr = req.Header
+		w.Write([]byte(`{"result":"success","data":{}}`))
+	})
+
+	defer func(p *ProxyHeaderPolicy) { ProxyHeaders = p }(ProxyHeaders)
+
+	req := newReq("GET", "/User:get")
+	req.Header.Set("Cookie", "session=x")
+	req.Header.Set("X-Custom", "value")
+	Router.ServeHTTP(httptest.NewRecorder(), req)
+	if hdr.Get("Cookie") != "" || hdr.Get("X-Custom") != "value" {
+		t.Errorf("unexpected headers with default policy: %v", hdr)
+	}
+
+	ProxyHeaders = &ProxyHeaderPolicy{
+		Allow: []string{"Cookie"},
+		Set:   http.Header{"X-Forwarded-App": []string{"test"}},
+	}
+	req = newReq("GET", "/User:get")
+	req.Header.Set("Cookie", "session=x")
+	req.Header.Set("X-Custom", "value")
+	Router.ServeHTTP(httptest.NewRecorder(), req)
+	if hdr.Get("Cookie") != "session=x" || hdr.Get("X-Custom") != "" || hdr.Get("X-Forwarded-App") != "test" {
+		t.Errorf("unexpected headers with custom policy: %v", hdr)
+	}
+}
+
+func TestProxyFailover(t *testing.T) {
+	_, newReq := newRouterTestBackend(t, func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	failover, _ := newRouterTestBackend(t, func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(`{"result":"success","data":"failover"}`))
+	})
+
+	defer func(u *url.URL) { FailoverURL = u }(FailoverURL)
+	FailoverURL, _ = url.Parse(failover.URL)
+
+	w := httptest.NewRecorder()
+	Router.ServeHTTP(w, newReq("GET", "/Misc/Debug:ping"))
+	if w.Code != http.StatusOK || w.Body.String() != `{"result":"success","data":"failover"}` {
+		t.Errorf("unexpected failover response %d %q", w.Code, w.Body.String())
+	}
+
+	// non idempotent requests are not retried
+	w = httptest.NewRecorder()
+	Router.ServeHTTP(w, newReq("POST", "/Misc/Debug:ping"))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("unexpected POST response code %d", w.Code)
+	}
+}
+
+func TestProxyErrorHandler(t *testing.T) {
+	srv, newReq := newRouterTestBackend(t, func(w http.ResponseWriter, req *http.Request) {})
+	srv.Close()
+
+	w := httptest.NewRecorder()
+	Router.ServeHTTP(w, newReq("GET", "/Misc/Debug:ping"))
+	if w.Code != http.StatusBadGateway {
+		t.Errorf("unexpected response code %d", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("unexpected content type %q", ct)
+	}
+	var res Response
+	if err := pjson.Unmarshal(w.Body.Bytes(), &res); err != nil || res.Result != "error" || res.Code != http.StatusBadGateway {
+		t.Errorf("unexpected response %q (%v)", w.Body.String(), err)
+	}
+	if res.Error != "bad gateway" {
+		t.Errorf("proxy error details should not be sent to clients, got %q", res.Error)
+	}
+}
+
+func TestRouterBody(t *testing.T) {
+	var received []byte
+	var length int64
+	_, newReq := newRouterTestBackend(t, func(w http.ResponseWriter, req *http.Request) {
+		received, _ = io.ReadAll(req.Body)
+		length = req.ContentLength
+		w.Write([]byte(`{"result":"success","data":{}}`))
+	})
+	newBodyReq := func(body []byte) *http.Request {
+		req := newReq("POST", "/Misc/Debug:upload")
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		req.ContentLength = -1
+		return req
+	}
+
+	body := bytes.Repeat([]byte("0123456789abcdef"), 1024)
+
+	// spill to disk
+	r := &RouterType{BufferBody: true, MemoryBodySize: 1024}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, newBodyReq(body))
+	if w.Code != http.StatusOK || !bytes.Equal(received, body) || length != int64(len(body)) {
+		t.Errorf("unexpected buffered upload result: code %d, received %d/%d bytes", w.Code, len(received), length)
+	}
+
+	// size limit
+	r = &RouterType{BufferBody: true, MaxBodySize: 1024}
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, newBodyReq(body))
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("unexpected response code %d for oversized body", w.Code)
+	}
+}
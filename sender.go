@@ -2,6 +2,8 @@ package rest
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"io"
 )
 
@@ -9,17 +11,172 @@ type SenderInterface interface {
 	Send(from string, to []string, msg io.WriterTo) error
 }
 
-type restSender struct{}
+type restSender struct {
+	ctx context.Context
+}
 
 var Sender SenderInterface = restSender{}
 
+// errSendStopped is returned to message writers when the upload of the
+// message ended before it was fully written
+var errSendStopped = errors.New("mail sending stopped")
+
+// ResultSenderInterface is implemented by senders able to return details on
+// sent messages
+type ResultSenderInterface interface {
+	SendMessage(from string, to []string, msg io.WriterTo) (*SendResult, error)
+}
+
+// ContextSenderInterface is implemented by senders accepting a context for
+// each message, used to cancel sending and carry credentials
+type ContextSenderInterface interface {
+	SendContext(ctx context.Context, from string, to []string, msg io.WriterTo) error
+}
+
+// SendResult is the result of a MTA:send call
+type SendResult struct {
+	MessageID  string             `json:"message_id"`
+	Recipients []*RecipientStatus `json:"recipients"`
+
+	Response *Response `json:"-"` // full server response
+}
+
+// RecipientStatus is the delivery status of a message for a given recipient
+type RecipientStatus struct {
+	Address string `json:"address"`
+	Status  string `json:"status"` // "queued" when the message was accepted for this recipient
+	Error   string `json:"error,omitempty"`
+}
+
+// Queued returns the addresses the message was accepted for
+func (r *SendResult) Queued() []string {
+	var res []string
+	for _, rcpt := range r.Recipients {
+		if rcpt.Status == "queued" {
+			res = append(res, rcpt.Address)
+		}
+	}
+	return res
+}
+
+// MaxMailSize, if positive, is the maximum size of messages sent through
+// MTA:send. Larger messages fail with a *MailTooLargeError, before anything
+// is sent if the size of the message can be known in advance.
+var MaxMailSize int64
+
+// MailTooLargeError is returned when a message exceeds MaxMailSize
+type MailTooLargeError struct {
+	Size  int64 // size of the message, or amount read so far if not known
+	Limit int64
+}
+
+func (e *MailTooLargeError) Error() string {
+	return fmt.Sprintf("mail message too large: %d bytes exceeds limit of %d bytes", e.Size, e.Limit)
+}
+
+// NewSender returns a Sender performing MTA:send calls with the given
+// context, allowing messages to be sent with the credentials it carries
+// (see Token.Use) or against a different backend. Upload progress listeners
+// set in the context (see WithProgressListener) are called as the message is
+// sent.
+func NewSender(ctx context.Context) SenderInterface {
+	return restSender{ctx: ctx}
+}
+
+func (s restSender) context() context.Context {
+	if s.ctx != nil {
+		return s.ctx
+	}
+	return context.Background()
+}
+
 func (s restSender) Send(from string, to []string, msg io.WriterTo) error {
+	_, err := s.SendMessageContext(s.context(), from, to, msg)
+	return err
+}
+
+// SendContext sends msg using ctx instead of the context of the sender
+func (s restSender) SendContext(ctx context.Context, from string, to []string, msg io.WriterTo) error {
+	_, err := s.SendMessageContext(ctx, from, to, msg)
+	return err
+}
+
+// SendMessage sends msg and returns the details of the server's response
+func (s restSender) SendMessage(from string, to []string, msg io.WriterTo) (*SendResult, error) {
+	return s.SendMessageContext(s.context(), from, to, msg)
+}
+
+// SendMessageContext sends msg using ctx instead of the context of the
+// sender, and returns the details of the server's response. If writing msg
+// fails, the upload is aborted and the write error is returned.
+func (s restSender) SendMessageContext(ctx context.Context, from string, to []string, msg io.WriterTo) (*SendResult, error) {
+	size := mailSize(msg)
+	if MaxMailSize > 0 && size > MaxMailSize {
+		return nil, &MailTooLargeError{Size: size, Limit: MaxMailSize}
+	}
+
 	reader, writer := io.Pipe()
-	defer reader.Close()
+	writeErr := make(chan error, 1)
 	go func() {
-		defer writer.Close()
-		msg.WriteTo(writer)
+		var err error
+		defer func() {
+			// msg is user code
+			if r := recover(); r != nil {
+				err = newPanicError(r)
+			}
+			// make the upload fail promptly on write errors
+			writer.CloseWithError(err)
+			writeErr <- err
+		}()
+		var w io.Writer = writer
+		if MaxMailSize > 0 {
+			w = &mailLimitWriter{w: writer, limit: MaxMailSize}
+		}
+		_, err = msg.WriteTo(w)
 	}()
-	_, err := Upload(context.Background(), "MTA:send", "POST", map[string]any{"from": from, "to": to}, reader, "message/rfc822")
-	return err
+	res, err := upload(ctx, MTASend.String(), "POST", Param{"from": from, "to": to}, reader, "message/rfc822", size)
+	// unblock the writer if the upload stopped before reading everything
+	reader.CloseWithError(errSendStopped)
+	if werr := <-writeErr; werr != nil && !errors.Is(werr, errSendStopped) {
+		return nil, fmt.Errorf("failed to write message: %w", werr)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result := &SendResult{Response: res}
+	// the message was sent at this point, so parsing is best effort and
+	// unknown response formats only leave fields empty
+	res.ApplyContext(ctx, result)
+	return result, nil
+}
+
+// mailSize returns the size of msg if it can be known without writing it, or
+// -1
+func mailSize(msg io.WriterTo) int64 {
+	switch m := msg.(type) {
+	case interface{ Len() int }:
+		return int64(m.Len())
+	case interface{ Size() int64 }:
+		return m.Size()
+	default:
+		return -1
+	}
+}
+
+// mailLimitWriter fails with a *MailTooLargeError once more than limit bytes
+// have been written
+type mailLimitWriter struct {
+	w     io.Writer
+	n     int64
+	limit int64
+}
+
+func (l *mailLimitWriter) Write(b []byte) (int, error) {
+	if l.n+int64(len(b)) > l.limit {
+		return 0, &MailTooLargeError{Size: l.n + int64(len(b)), Limit: l.limit}
+	}
+	n, err := l.w.Write(b)
+	l.n += int64(n)
+	return n, err
 }
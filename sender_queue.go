@@ -0,0 +1,325 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/KarpelesLab/pjson"
+)
+
+// QueuedMail is a message stored in a MailQueue
+type QueuedMail struct {
+	ID        string    `json:"id"`
+	From      string    `json:"from"`
+	To        []string  `json:"to"`
+	Data      []byte    `json:"data"`
+	Created   time.Time `json:"created"`
+	Attempts  int       `json:"attempts"`
+	NextTry   time.Time `json:"next_try"`
+	LastError string    `json:"last_error,omitempty"`
+	Failed    bool      `json:"failed,omitempty"` // permanent failure, will not be retried
+}
+
+// MailQueueStore is a durable storage for queued messages. Implementations
+// must be safe for concurrent use.
+type MailQueueStore interface {
+	Put(msg *QueuedMail) error // store or update a message
+	Delete(id string) error
+	List() ([]*QueuedMail, error)
+}
+
+// MailQueue is a Sender that stores messages in a MailQueueStore and sends
+// them in the background, retrying with backoff on transient failures. The
+// queue must be started with Run.
+type MailQueue struct {
+	Store  MailQueueStore
+	Sender SenderInterface // Sender used to actually send messages, defaults to sending via MTA:send
+
+	MinBackoff  time.Duration // delay before first retry, defaults to 30 seconds
+	MaxBackoff  time.Duration // maximum delay between retries, defaults to 1 hour
+	MaxAttempts int           // number of attempts before a message is marked as failed, 0 for no limit
+
+	wake chan struct{}
+	once sync.Once
+}
+
+// NewMailQueue returns a new MailQueue using the given store
+func NewMailQueue(store MailQueueStore) *MailQueue {
+	return &MailQueue{Store: store}
+}
+
+func (q *MailQueue) init() {
+	q.once.Do(func() {
+		q.wake = make(chan struct{}, 1)
+	})
+}
+
+// Send stores the message in the queue. It returns once the message has
+// been durably stored, not when it has been sent.
+func (q *MailQueue) Send(from string, to []string, msg io.WriterTo) error {
+	q.init()
+
+	buf := &bytes.Buffer{}
+	if _, err := msg.WriteTo(buf); err != nil {
+		return err
+	}
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return err
+	}
+	now := now()
+
+	m := &QueuedMail{
+		ID:      fmt.Sprintf("%016x-%s", now.UnixNano(), hex.EncodeToString(id)),
+		From:    from,
+		To:      to,
+		Data:    buf.Bytes(),
+		Created: now,
+		NextTry: now,
+	}
+	if err := q.Store.Put(m); err != nil {
+		return err
+	}
+
+	// wake worker
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Pending returns the messages currently in the queue, including the ones
+// that have permanently failed
+func (q *MailQueue) Pending() ([]*QueuedMail, error) {
+	list, err := q.Store.List()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Created.Before(list[j].Created) })
+	return list, nil
+}
+
+// Run sends queued messages until ctx is cancelled
+func (q *MailQueue) Run(ctx context.Context) error {
+	q.init()
+
+	for {
+		next, err := q.process(ctx)
+		if err != nil {
+			slog.ErrorContext(ctx, fmt.Sprintf("[rest] mail queue error: %s", err), "event", "rest:mailqueue_error")
+			next = now().Add(q.minBackoff())
+		}
+
+		timer := DefaultClock.NewTimer(next.Sub(now()))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-q.wake:
+			timer.Stop()
+		case <-timer.C():
+		}
+	}
+}
+
+// process attempts to send all messages that are due, and returns the time
+// at which it should be called again
+func (q *MailQueue) process(ctx context.Context) (time.Time, error) {
+	list, err := q.Pending()
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	next := now().Add(q.maxBackoff())
+	for _, m := range list {
+		if ctx.Err() != nil {
+			return next, nil
+		}
+		if m.Failed {
+			continue
+		}
+		if now().Before(m.NextTry) {
+			if m.NextTry.Before(next) {
+				next = m.NextTry
+			}
+			continue
+		}
+
+		var err error
+		if snd, ok := q.sender().(ContextSenderInterface); ok {
+			err = snd.SendContext(ctx, m.From, m.To, bytes.NewReader(m.Data))
+		} else {
+			err = q.sender().Send(m.From, m.To, bytes.NewReader(m.Data))
+		}
+		if err == nil {
+			if err := q.Store.Delete(m.ID); err != nil {
+				return next, err
+			}
+			continue
+		}
+
+		m.Attempts += 1
+		m.LastError = err.Error()
+		if !isTransientMailError(err) || (q.MaxAttempts > 0 && m.Attempts >= q.MaxAttempts) {
+			m.Failed = true
+			slog.ErrorContext(ctx, fmt.Sprintf("[rest] failed to send mail %s: %s", m.ID, err), "event", "rest:mailqueue_failed")
+		} else {
+			m.NextTry = now().Add(q.backoff(m.Attempts))
+			if m.NextTry.Before(next) {
+				next = m.NextTry
+			}
+		}
+		if err := q.Store.Put(m); err != nil {
+			return next, err
+		}
+	}
+	return next, nil
+}
+
+func (q *MailQueue) sender() SenderInterface {
+	if q.Sender != nil {
+		return q.Sender
+	}
+	return restSender{}
+}
+
+func (q *MailQueue) minBackoff() time.Duration {
+	if q.MinBackoff > 0 {
+		return q.MinBackoff
+	}
+	return 30 * time.Second
+}
+
+func (q *MailQueue) maxBackoff() time.Duration {
+	if q.MaxBackoff > 0 {
+		return q.MaxBackoff
+	}
+	return time.Hour
+}
+
+// backoff returns the delay before the next attempt
+func (q *MailQueue) backoff(attempts int) time.Duration {
+	d := q.minBackoff()
+	for i := 1; i < attempts && d < q.maxBackoff(); i++ {
+		d *= 2
+	}
+	if d > q.maxBackoff() {
+		d = q.maxBackoff()
+	}
+	return d
+}
+
+// isTransientMailError returns true if sending may succeed when retried
+// later: network errors, timeouts, server errors and rate limiting. Other
+// errors, such as messages too large or failing to encode, are permanent.
+func isTransientMailError(err error) bool {
+	var tooLarge *MailTooLargeError
+	var panicErr *PanicError
+	if errors.As(err, &tooLarge) || errors.As(err, &panicErr) {
+		return false
+	}
+	var e *Error
+	if errors.As(err, &e) && e.Response != nil {
+		return transientMailStatus(e.Response.Code)
+	}
+	var httpErr *HttpError
+	if errors.As(err, &httpErr) {
+		return transientMailStatus(httpErr.Code)
+	}
+	if errors.Is(err, ErrTimeout) || errors.Is(err, ErrUploadStalled) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+func transientMailStatus(code int) bool {
+	return code >= 500 || code == 408 || code == 429
+}
+
+// FileMailQueueStore is a MailQueueStore keeping each message as a json file
+// in a directory
+type FileMailQueueStore struct {
+	Dir string
+	lk  sync.Mutex
+}
+
+// NewFileMailQueueStore returns a store using the given directory, creating
+// it if needed
+func NewFileMailQueueStore(dir string) (*FileMailQueueStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &FileMailQueueStore{Dir: dir}, nil
+}
+
+func (s *FileMailQueueStore) Put(msg *QueuedMail) error {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	buf, err := pjson.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	// write to temp file & rename so a crash doesn't leave partial messages
+	fn := filepath.Join(s.Dir, msg.ID+".json")
+	tmp := fn + ".tmp"
+	if err := os.WriteFile(tmp, buf, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, fn)
+}
+
+func (s *FileMailQueueStore) Delete(id string) error {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	err := os.Remove(filepath.Join(s.Dir, id+".json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (s *FileMailQueueStore) List() ([]*QueuedMail, error) {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	ents, err := os.ReadDir(s.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var res []*QueuedMail
+	for _, ent := range ents {
+		if ent.IsDir() || !strings.HasSuffix(ent.Name(), ".json") {
+			continue
+		}
+		buf, err := os.ReadFile(filepath.Join(s.Dir, ent.Name()))
+		if err != nil {
+			return nil, err
+		}
+		m := &QueuedMail{}
+		if err := pjson.Unmarshal(buf, m); err != nil {
+			return nil, fmt.Errorf("failed to parse queued mail %s: %w", ent.Name(), err)
+		}
+		res = append(res, m)
+	}
+	return res, nil
+}
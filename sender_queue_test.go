@@ -0,0 +1,103 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+)
+
+type testSender struct {
+	fail int // number of calls that will fail
+	sent [][]byte
+}
+
+func (s *testSender) Send(from string, to []string, msg io.WriterTo) error {
+	if s.fail > 0 {
+		s.fail -= 1
+		return &HttpError{Code: http.StatusServiceUnavailable}
+	}
+	buf := &bytes.Buffer{}
+	msg.WriteTo(buf)
+	s.sent = append(s.sent, buf.Bytes())
+	return nil
+}
+
+func TestMailQueue(t *testing.T) {
+	store, err := NewFileMailQueueStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create store: %s", err)
+	}
+	snd := &testSender{fail: 1}
+	q := &MailQueue{Store: store, Sender: snd, MinBackoff: time.Millisecond}
+
+	if err := q.Send("from@example.com", []string{"to@example.com"}, bytes.NewReader([]byte("hello"))); err != nil {
+		t.Fatalf("failed to queue message: %s", err)
+	}
+
+	pending, err := q.Pending()
+	if err != nil || len(pending) != 1 {
+		t.Fatalf("expected 1 pending message, got %d (%v)", len(pending), err)
+	}
+
+	// first attempt fails, second succeeds
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		time.Sleep(2 * time.Millisecond)
+		if _, err := q.process(ctx); err != nil {
+			t.Fatalf("failed to process queue: %s", err)
+		}
+	}
+
+	if len(snd.sent) != 1 || string(snd.sent[0]) != "hello" {
+		t.Errorf("unexpected sent messages: %q", snd.sent)
+	}
+	pending, _ = q.Pending()
+	if len(pending) != 0 {
+		t.Errorf("expected empty queue, got %d messages", len(pending))
+	}
+}
+
+func TestMailQueuePermanentErrors(t *testing.T) {
+	store, err := NewFileMailQueueStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create store: %s", err)
+	}
+	// the default sender rejects the message before sending anything
+	q := &MailQueue{Store: store, MinBackoff: time.Millisecond}
+
+	MaxMailSize = 4
+	defer func() { MaxMailSize = 0 }()
+	if err := q.Send("from@example.com", []string{"to@example.com"}, bytes.NewReader([]byte("too large"))); err != nil {
+		t.Fatalf("failed to queue message: %s", err)
+	}
+	if _, err := q.process(context.Background()); err != nil {
+		t.Fatalf("failed to process queue: %s", err)
+	}
+	pending, _ := q.Pending()
+	if len(pending) != 1 || !pending[0].Failed || pending[0].Attempts != 1 {
+		t.Errorf("expected oversized message to be marked as failed, got %+v", pending)
+	}
+
+	cases := []struct {
+		err       error
+		transient bool
+	}{
+		{&MailTooLargeError{Size: 10, Limit: 4}, false},
+		{newPanicError("boom"), false},
+		{errors.New("json: unsupported value"), false},
+		{&Error{Response: &Response{Code: 400}}, false},
+		{&Error{Response: &Response{Code: 500}}, true},
+		{&Error{Response: &Response{Code: 429}}, true},
+		{&HttpError{Code: 408}, true},
+		{ErrTimeout, true},
+	}
+	for _, c := range cases {
+		if isTransientMailError(c.err) != c.transient {
+			t.Errorf("%v: expected transient=%v", c.err, c.transient)
+		}
+	}
+}
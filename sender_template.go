@@ -0,0 +1,107 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/mail"
+)
+
+// MailTemplateEndpoint is the API endpoint used to send template based mail
+var MailTemplateEndpoint = "MTA:sendTemplate"
+
+var ErrInvalidMail = errors.New("invalid mail")
+
+// MailVars holds the variables merged into a mail template
+type MailVars map[string]string
+
+// TemplateMail is a message built by the platform from a stored template
+type TemplateMail struct {
+	From       string
+	Template   string   // template id
+	Vars       MailVars // variables common to all recipients
+	Recipients []*TemplateRecipient
+}
+
+// TemplateRecipient is a recipient of a TemplateMail along with its own
+// variables, which take precedence over the message's
+type TemplateRecipient struct {
+	To   string
+	Vars MailVars
+}
+
+// TemplateSenderInterface is implemented by senders able to send template
+// based mail
+type TemplateSenderInterface interface {
+	SendTemplate(m *TemplateMail) error
+}
+
+// Validate checks that the message can be sent
+func (m *TemplateMail) Validate() error {
+	if m.Template == "" {
+		return fmt.Errorf("%w: template is required", ErrInvalidMail)
+	}
+	if _, err := mail.ParseAddress(m.From); err != nil {
+		return fmt.Errorf("%w: bad sender address %q: %s", ErrInvalidMail, m.From, err)
+	}
+	if len(m.Recipients) == 0 {
+		return fmt.Errorf("%w: no recipients", ErrInvalidMail)
+	}
+	if err := m.Vars.validate(); err != nil {
+		return err
+	}
+	for _, r := range m.Recipients {
+		if _, err := mail.ParseAddress(r.To); err != nil {
+			return fmt.Errorf("%w: bad recipient address %q: %s", ErrInvalidMail, r.To, err)
+		}
+		if err := r.Vars.validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v MailVars) validate() error {
+	for k := range v {
+		if k == "" {
+			return fmt.Errorf("%w: empty variable name", ErrInvalidMail)
+		}
+		for _, c := range k {
+			if !(c >= 'a' && c <= 'z') && !(c >= 'A' && c <= 'Z') && !(c >= '0' && c <= '9') && c != '_' && c != '.' {
+				return fmt.Errorf("%w: invalid variable name %q", ErrInvalidMail, k)
+			}
+		}
+	}
+	return nil
+}
+
+// SendTemplate validates and sends a template based message
+func SendTemplate(ctx context.Context, m *TemplateMail) (*Response, error) {
+	if err := m.Validate(); err != nil {
+		return nil, err
+	}
+
+	rcpts := make([]map[string]any, 0, len(m.Recipients))
+	for _, r := range m.Recipients {
+		rcpt := map[string]any{"to": r.To}
+		if len(r.Vars) > 0 {
+			rcpt["vars"] = r.Vars
+		}
+		rcpts = append(rcpts, rcpt)
+	}
+	req := map[string]any{
+		"from":       m.From,
+		"template":   m.Template,
+		"recipients": rcpts,
+	}
+	if len(m.Vars) > 0 {
+		req["vars"] = m.Vars
+	}
+
+	return Do(ctx, MailTemplateEndpoint, "POST", req)
+}
+
+func (s restSender) SendTemplate(m *TemplateMail) error {
+	_, err := SendTemplate(s.context(), m)
+	return err
+}
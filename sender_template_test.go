@@ -0,0 +1,101 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestTemplateMailValidate(t *testing.T) {
+	rcpt := []*TemplateRecipient{{To: "to@example.com"}}
+	cases := []struct {
+		name string
+		mail *TemplateMail
+		ok   bool
+	}{
+		{"valid", &TemplateMail{From: "from@example.com", Template: "tpl-1", Recipients: rcpt, Vars: MailVars{"user.name": "A", "code_1": "x"}}, true},
+		{"missing template", &TemplateMail{From: "from@example.com", Recipients: rcpt}, false},
+		{"missing sender", &TemplateMail{Template: "tpl-1", Recipients: rcpt}, false},
+		{"bad sender", &TemplateMail{From: "not an address", Template: "tpl-1", Recipients: rcpt}, false},
+		{"missing recipients", &TemplateMail{From: "from@example.com", Template: "tpl-1"}, false},
+		{"bad recipient", &TemplateMail{From: "from@example.com", Template: "tpl-1", Recipients: []*TemplateRecipient{{To: "nobody"}}}, false},
+		{"empty variable name", &TemplateMail{From: "from@example.com", Template: "tpl-1", Recipients: rcpt, Vars: MailVars{"": "x"}}, false},
+		{"invalid variable name", &TemplateMail{From: "from@example.com", Template: "tpl-1", Recipients: rcpt, Vars: MailVars{"{{name}}": "x"}}, false},
+		{"invalid recipient variable", &TemplateMail{From: "from@example.com", Template: "tpl-1", Recipients: []*TemplateRecipient{{To: "to@example.com", Vars: MailVars{"a b": "x"}}}}, false},
+	}
+	for _, c := range cases {
+		err := c.mail.Validate()
+		if c.ok && err != nil {
+			t.Errorf("%s: unexpected error %s", c.name, err)
+		}
+		if !c.ok && !errors.Is(err, ErrInvalidMail) {
+			t.Errorf("%s: expected ErrInvalidMail, got %v", c.name, err)
+		}
+	}
+}
+
+func TestSendTemplate(t *testing.T) {
+	var body map[string]any
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls += 1
+		if r.URL.Path != "/_special/rest/MTA:sendTemplate" || r.Method != "POST" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		buf, _ := io.ReadAll(r.Body)
+		json.Unmarshal(buf, &body)
+		w.Write([]byte(`{"result":"success","data":{}}`))
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+	ctx := context.WithValue(context.Background(), BackendURL, u)
+
+	cases := []struct {
+		name string
+		mail *TemplateMail
+		want map[string]any
+	}{
+		{
+			"without variables",
+			&TemplateMail{From: "from@example.com", Template: "tpl-1", Recipients: []*TemplateRecipient{{To: "to@example.com"}}},
+			map[string]any{"from": "from@example.com", "template": "tpl-1", "recipients": []any{map[string]any{"to": "to@example.com"}}},
+		},
+		{
+			"with variables",
+			&TemplateMail{From: "from@example.com", Template: "tpl-2", Vars: MailVars{"site": "example"}, Recipients: []*TemplateRecipient{
+				{To: "a@example.com", Vars: MailVars{"name": "A"}},
+				{To: "b@example.com"},
+			}},
+			map[string]any{"from": "from@example.com", "template": "tpl-2", "vars": map[string]any{"site": "example"}, "recipients": []any{
+				map[string]any{"to": "a@example.com", "vars": map[string]any{"name": "A"}},
+				map[string]any{"to": "b@example.com"},
+			}},
+		},
+	}
+	for _, c := range cases {
+		body = nil
+		if _, err := SendTemplate(ctx, c.mail); err != nil {
+			t.Fatalf("%s: SendTemplate failed: %s", c.name, err)
+		}
+		if !reflect.DeepEqual(body, c.want) {
+			t.Errorf("%s: unexpected request %v", c.name, body)
+		}
+	}
+
+	// invalid messages are not sent
+	if _, err := SendTemplate(ctx, &TemplateMail{From: "from@example.com"}); !errors.Is(err, ErrInvalidMail) || calls != len(cases) {
+		t.Errorf("expected invalid message to be rejected, got %v", err)
+	}
+
+	// senders created with NewSender use their context
+	snd := NewSender(ctx).(TemplateSenderInterface)
+	if err := snd.SendTemplate(cases[0].mail); err != nil || calls != len(cases)+1 {
+		t.Errorf("template sender failed: %v", err)
+	}
+}
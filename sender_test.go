@@ -0,0 +1,229 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+type failingMessage struct {
+	err error
+}
+
+func (m *failingMessage) WriteTo(w io.Writer) (int64, error) {
+	n, err := w.Write([]byte("Subject: test\r\n\r\nhello"))
+	if err != nil {
+		return int64(n), err
+	}
+	return int64(n), m.err
+}
+
+func TestSenderWriteError(t *testing.T) {
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/_special/rest/MTA:send":
+			w.Write([]byte(`{"result":"success","data":{"PUT":"` + srv.URL + `/put","Complete":"Upload:complete","Blocksize":4}}`))
+		case "/put":
+			io.Copy(io.Discard, req.Body)
+		default:
+			w.Write([]byte(`{"result":"success","data":{}}`))
+		}
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+	ctx := context.WithValue(context.Background(), BackendURL, u)
+
+	writeErr := errors.New("message generation failed")
+	snd := NewSender(context.Background()).(ContextSenderInterface)
+	err := snd.SendContext(ctx, "from@example.com", []string{"to@example.com"}, &failingMessage{err: writeErr})
+	if !errors.Is(err, writeErr) {
+		t.Errorf("expected write error to be returned, got %v", err)
+	}
+
+	if err := snd.SendContext(ctx, "from@example.com", []string{"to@example.com"}, &failingMessage{}); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+}
+
+func TestNewSender(t *testing.T) {
+	var params, auth, body string
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/_special/rest/MTA:send":
+			buf, _ := io.ReadAll(req.Body)
+			params = string(buf)
+			auth = req.Header.Get("Authorization")
+			w.Write([]byte(`{"result":"success","data":{"PUT":"` + srv.URL + `/put","Complete":"Upload:complete"}}`))
+		case "/put":
+			buf, _ := io.ReadAll(req.Body)
+			body = string(buf)
+		default:
+			w.Write([]byte(`{"result":"success","data":{"Message_Id":"msg-1"}}`))
+		}
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+	ctx := (&Token{AccessToken: "sender"}).Use(context.WithValue(context.Background(), BackendURL, u))
+
+	var sent int64
+	ctx = WithProgress(ctx, func(s, total int64) { sent = s })
+
+	snd := NewSender(ctx)
+	msg := bytes.NewBufferString("Subject: test\r\n\r\nhello")
+	if err := snd.Send("from@example.com", []string{"to@example.com"}, msg); err != nil {
+		t.Fatalf("Send failed: %s", err)
+	}
+	if auth != "Bearer sender" {
+		t.Errorf("message was not sent with the context token, got %q", auth)
+	}
+	if !strings.Contains(params, `"from":"from@example.com"`) || !strings.Contains(params, `"to":["to@example.com"]`) {
+		t.Errorf("unexpected send parameters %s", params)
+	}
+	if body != "Subject: test\r\n\r\nhello" {
+		t.Errorf("unexpected uploaded message %q", body)
+	}
+	if sent != int64(len(body)) {
+		t.Errorf("progress listener of the context was not called, got %d bytes", sent)
+	}
+
+	// SendContext replaces the context of the sender
+	other := (&Token{AccessToken: "other"}).Use(context.WithValue(context.Background(), BackendURL, u))
+	msg = bytes.NewBufferString("Subject: test\r\n\r\nhello")
+	if err := snd.(ContextSenderInterface).SendContext(other, "from@example.com", []string{"to@example.com"}, msg); err != nil {
+		t.Fatalf("SendContext failed: %s", err)
+	}
+	if auth != "Bearer other" {
+		t.Errorf("message was not sent with the given context, got %q", auth)
+	}
+}
+
+func TestMaxMailSize(t *testing.T) {
+	var calls int
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/_special/rest/MTA:send":
+			calls += 1
+			w.Write([]byte(`{"result":"success","data":{"PUT":"` + srv.URL + `/put","Complete":"Upload:complete","Blocksize":4}}`))
+		case "/put":
+			io.Copy(io.Discard, req.Body)
+		default:
+			w.Write([]byte(`{"result":"success","data":{}}`))
+		}
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+	ctx := context.WithValue(context.Background(), BackendURL, u)
+
+	const msg = "Subject: test\r\n\r\nhello"
+	defer func(v int64) { MaxMailSize = v }(MaxMailSize)
+	MaxMailSize = int64(len(msg))
+	snd := NewSender(ctx)
+
+	// exactly at the limit
+	if err := snd.Send("from@example.com", []string{"to@example.com"}, bytes.NewBufferString(msg)); err != nil {
+		t.Errorf("message at the limit failed: %s", err)
+	}
+	if err := snd.Send("from@example.com", []string{"to@example.com"}, &failingMessage{}); err != nil {
+		t.Errorf("message of unknown size at the limit failed: %s", err)
+	}
+
+	// over the limit, with a known size nothing is sent
+	MaxMailSize = int64(len(msg)) - 1
+	calls = 0
+	err := snd.Send("from@example.com", []string{"to@example.com"}, bytes.NewBufferString(msg))
+	var tooLarge *MailTooLargeError
+	if !errors.As(err, &tooLarge) {
+		t.Fatalf("expected *MailTooLargeError, got %v", err)
+	}
+	if tooLarge.Size != int64(len(msg)) || tooLarge.Limit != MaxMailSize {
+		t.Errorf("unexpected error details %+v", tooLarge)
+	}
+	if calls != 0 {
+		t.Errorf("oversized message of known size was sent to the server")
+	}
+
+	// over the limit, with an unknown size the upload is aborted
+	err = snd.Send("from@example.com", []string{"to@example.com"}, &failingMessage{})
+	if !errors.As(err, &tooLarge) {
+		t.Errorf("expected *MailTooLargeError for message of unknown size, got %v", err)
+	}
+}
+
+func TestMailLimitWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := &mailLimitWriter{w: &buf, limit: 8}
+
+	if n, err := w.Write([]byte("12345")); n != 5 || err != nil {
+		t.Fatalf("unexpected write result %d, %v", n, err)
+	}
+	if n, err := w.Write([]byte("678")); n != 3 || err != nil {
+		t.Fatalf("write reaching the limit failed: %d, %v", n, err)
+	}
+	n, err := w.Write([]byte("9"))
+	var tooLarge *MailTooLargeError
+	if n != 0 || !errors.As(err, &tooLarge) {
+		t.Fatalf("expected *MailTooLargeError past the limit, got %d, %v", n, err)
+	}
+	if tooLarge.Size != 9 || tooLarge.Limit != 8 {
+		t.Errorf("unexpected error details %+v", tooLarge)
+	}
+	if buf.String() != "12345678" {
+		t.Errorf("unexpected data written %q", buf.String())
+	}
+}
+
+func TestSendResult(t *testing.T) {
+	var complete string
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/_special/rest/MTA:send":
+			w.Write([]byte(`{"result":"success","data":{"PUT":"` + srv.URL + `/put","Complete":"Upload:complete","Blocksize":4}}`))
+		case "/put":
+			io.Copy(io.Discard, req.Body)
+		default:
+			w.Write([]byte(complete))
+		}
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+	ctx := context.WithValue(context.Background(), BackendURL, u)
+	snd := NewSender(ctx).(ResultSenderInterface)
+
+	complete = `{"result":"success","data":{"message_id":"<abc@example.com>","recipients":[{"address":"a@example.com","status":"queued"},{"address":"b@example.com","status":"rejected","error":"no such user"}]}}`
+	res, err := snd.SendMessage("from@example.com", []string{"a@example.com", "b@example.com"}, bytes.NewBufferString("Subject: test\r\n\r\nhello"))
+	if err != nil {
+		t.Fatalf("SendMessage failed: %s", err)
+	}
+	if res.MessageID != "<abc@example.com>" {
+		t.Errorf("unexpected message id %q", res.MessageID)
+	}
+	if len(res.Recipients) != 2 || res.Recipients[1].Error != "no such user" {
+		t.Errorf("unexpected recipients %+v", res.Recipients)
+	}
+	if q := res.Queued(); len(q) != 1 || q[0] != "a@example.com" {
+		t.Errorf("unexpected queued recipients %v", q)
+	}
+	if res.Response == nil {
+		t.Errorf("full response missing from result")
+	}
+
+	// the message was still sent when the response has no details
+	complete = `{"result":"success","data":{}}`
+	res, err = snd.SendMessage("from@example.com", []string{"a@example.com"}, bytes.NewBufferString("Subject: test\r\n\r\nhello"))
+	if err != nil {
+		t.Fatalf("SendMessage with empty response failed: %s", err)
+	}
+	if res.MessageID != "" || len(res.Recipients) != 0 || len(res.Queued()) != 0 {
+		t.Errorf("unexpected result for empty response %+v", res)
+	}
+}
@@ -0,0 +1,76 @@
+package rest
+
+import (
+	"sync"
+	"time"
+
+	"github.com/KarpelesLab/pjson"
+)
+
+// serverClock keeps a rolling estimate of the offset between the server
+// clock and the local clock, from the time reported in responses
+var serverClock struct {
+	lk      sync.Mutex
+	offset  time.Duration
+	samples int
+}
+
+// ServerTime returns the time reported by the server in the response, if
+// it contains a timestamp. Durations, also found in this field, are ignored.
+func (r *Response) ServerTime() (Time, bool) {
+	var t Time
+	switch v := r.Time.(type) {
+	case map[string]any:
+		if _, ok := v["unix"]; !ok {
+			return t, false
+		}
+	case float64:
+		if v < 1e9 {
+			// processing time rather than a timestamp
+			return t, false
+		}
+	default:
+		return t, false
+	}
+	data, err := pjson.Marshal(r.Time)
+	if err != nil || t.UnmarshalJSON(data) != nil {
+		return t, false
+	}
+	return t, true
+}
+
+// observeServerTime updates the clock offset estimate from a response to a
+// request sent at start and received at end
+func observeServerTime(res *Response, start, end time.Time) {
+	st, ok := res.ServerTime()
+	if !ok {
+		return
+	}
+	// assume the server time was taken halfway through the request
+	sample := st.Sub(start.Add(end.Sub(start) / 2))
+
+	serverClock.lk.Lock()
+	defer serverClock.lk.Unlock()
+	if serverClock.samples == 0 {
+		serverClock.offset = sample
+	} else {
+		serverClock.offset += (sample - serverClock.offset) / 5
+	}
+	serverClock.samples += 1
+}
+
+// ClockOffset returns the estimated offset of the server clock from the local
+// clock, and false if no response carried a timestamp yet
+func ClockOffset() (time.Duration, bool) {
+	serverClock.lk.Lock()
+	defer serverClock.lk.Unlock()
+	return serverClock.offset, serverClock.samples > 0
+}
+
+// ServerNow returns the current time according to the server, estimated from
+// the local clock and ClockOffset. It is the local time if the offset is not
+// known yet.
+func ServerNow() time.Time {
+	offset, _ := ClockOffset()
+	return now().Add(offset)
+}
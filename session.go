@@ -0,0 +1,184 @@
+package rest
+
+import (
+	"context"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"sync"
+
+	"github.com/KarpelesLab/pjson"
+)
+
+// Session bundles what identifies a client to the API: a token, cookies set
+// by the server and parameters added to every call. Applications using
+// several identities can switch between them with a single Use.
+type Session struct {
+	Token  *Token
+	Params Param // added to the parameters of each call, unless already set
+
+	lk      sync.Mutex
+	jar     *cookiejar.Jar
+	origins map[string]*url.URL // backends cookies were received from
+}
+
+type sessionValue int
+
+type withSession struct {
+	context.Context
+	s *Session
+}
+
+func (w *withSession) Value(v any) any {
+	switch v.(type) {
+	case sessionValue:
+		return w.s
+	case tokenValue:
+		if w.s.Token != nil {
+			return w.s.Token
+		}
+	}
+	return w.Context.Value(v)
+}
+
+// Use returns a context in which calls are made with the session. It
+// replaces the token and session of ctx, if any. If the session has no
+// token, the token of ctx is used.
+func (s *Session) Use(ctx context.Context) context.Context {
+	return &withSession{ctx, s}
+}
+
+// contextSession returns the session of ctx, if any
+func contextSession(ctx context.Context) *Session {
+	s, _ := ctx.Value(sessionValue(0)).(*Session)
+	return s
+}
+
+// sessionParams returns param with the default parameters of the session of
+// ctx added. Only Param and map parameters can be extended.
+func sessionParams(ctx context.Context, param any) any {
+	s := contextSession(ctx)
+	if s == nil || len(s.Params) == 0 {
+		return param
+	}
+	var p map[string]any
+	switch v := param.(type) {
+	case nil:
+	case Param:
+		p = v
+	case map[string]any:
+		p = v
+	default:
+		return param
+	}
+	res := make(Param, len(p)+len(s.Params))
+	for k, v := range s.Params {
+		res[k] = v
+	}
+	for k, v := range p {
+		res[k] = v
+	}
+	return res
+}
+
+// cookieJar returns the jar of the session, creating it if needed
+func (s *Session) cookieJar() *cookiejar.Jar {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	if s.jar == nil {
+		s.jar, _ = cookiejar.New(nil)
+	}
+	return s.jar
+}
+
+// addCookies adds the cookies of the session to req
+func (s *Session) addCookies(req *http.Request) {
+	for _, c := range s.cookieJar().Cookies(req.URL) {
+		req.AddCookie(c)
+	}
+}
+
+// setCookies stores the cookies set by resp
+func (s *Session) setCookies(resp *http.Response) {
+	s.SetCookies(resp.Request.URL, resp.Cookies())
+}
+
+// Cookies returns the cookies the session sends to backend
+func (s *Session) Cookies(backend *url.URL) []*http.Cookie {
+	return s.cookieJar().Cookies(backend)
+}
+
+// SetCookies stores cookies to be sent to backend
+func (s *Session) SetCookies(backend *url.URL, cookies []*http.Cookie) {
+	if len(cookies) == 0 {
+		return
+	}
+	s.cookieJar().SetCookies(backend, cookies)
+
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	if s.origins == nil {
+		s.origins = make(map[string]*url.URL)
+	}
+	origin := &url.URL{Scheme: backend.Scheme, Host: backend.Host, Path: "/"}
+	s.origins[origin.String()] = origin
+}
+
+// sessionFile is the format sessions are saved in
+type sessionFile struct {
+	Token   *Token                    `json:"token,omitempty"`
+	Params  Param                     `json:"params,omitempty"`
+	Cookies map[string][]*http.Cookie `json:"cookies,omitempty"` // by backend
+}
+
+// Save writes the session to file fn, readable only by the current user.
+// Only the name and value of the cookies sent to the root of each backend
+// are saved.
+func (s *Session) Save(fn string) error {
+	f := &sessionFile{Token: s.Token, Params: s.Params}
+
+	jar := s.cookieJar()
+	s.lk.Lock()
+	for k, u := range s.origins {
+		if cookies := jar.Cookies(u); len(cookies) > 0 {
+			if f.Cookies == nil {
+				f.Cookies = make(map[string][]*http.Cookie)
+			}
+			f.Cookies[k] = cookies
+		}
+	}
+	s.lk.Unlock()
+
+	buf, err := pjson.Marshal(f)
+	if err != nil {
+		return err
+	}
+	// write to temp file & rename so a crash doesn't leave a partial session
+	tmp := fn + ".tmp"
+	if err := os.WriteFile(tmp, buf, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, fn)
+}
+
+// LoadSession reads a session written by Save
+func LoadSession(fn string) (*Session, error) {
+	buf, err := os.ReadFile(fn)
+	if err != nil {
+		return nil, err
+	}
+	f := &sessionFile{}
+	if err := pjson.Unmarshal(buf, f); err != nil {
+		return nil, err
+	}
+	s := &Session{Token: f.Token, Params: f.Params}
+	for k, cookies := range f.Cookies {
+		u, err := url.Parse(k)
+		if err != nil {
+			return nil, err
+		}
+		s.SetCookies(u, cookies)
+	}
+	return s, nil
+}
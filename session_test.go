@@ -0,0 +1,84 @@
+package rest
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSession(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if c, err := r.Cookie("sid"); err != nil || c.Value != "s1" {
+			http.SetCookie(w, &http.Cookie{Name: "sid", Value: "s1", Path: "/"})
+		}
+		body, _ := io.ReadAll(r.Body)
+		w.Write([]byte(`{"result":"success","data":{"auth":"` + r.Header.Get("Authorization") + `","body":` + string(body) + `}}`))
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+	ctx := (&Token{AccessToken: "other"}).Use(context.WithValue(context.Background(), BackendURL, u))
+
+	s := &Session{Token: &Token{AccessToken: "abc"}, Params: Param{"site": "s", "a": 0}}
+	var res struct {
+		Auth string
+		Body map[string]any
+	}
+	if err := Apply(s.Use(ctx), "Test", "POST", Param{"a": 1}, &res); err != nil {
+		t.Fatalf("request failed: %s", err)
+	}
+	if res.Auth != "Bearer abc" || res.Body["site"] != "s" || res.Body["a"] != float64(1) {
+		t.Errorf("unexpected request %+v", res)
+	}
+	if c := s.Cookies(u); len(c) != 1 || c[0].Value != "s1" {
+		t.Errorf("expected session cookie, got %v", c)
+	}
+
+	fn := filepath.Join(t.TempDir(), "session.json")
+	if err := s.Save(fn); err != nil {
+		t.Fatalf("failed to save session: %s", err)
+	}
+	s2, err := LoadSession(fn)
+	if err != nil {
+		t.Fatalf("failed to load session: %s", err)
+	}
+	if s2.Token.AccessToken != "abc" || s2.Params["site"] != "s" {
+		t.Errorf("unexpected loaded session %+v", s2)
+	}
+	if c := s2.Cookies(u); len(c) != 1 || c[0].Value != "s1" {
+		t.Errorf("expected loaded session cookie, got %v", c)
+	}
+}
+
+func TestSessionCache(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sid := "anonymous"
+		if c, err := r.Cookie("sid"); err == nil {
+			sid = c.Value
+		}
+		w.Write([]byte(`{"result":"success","data":"` + sid + `"}`))
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+	ctx := context.WithValue(context.Background(), BackendURL, u)
+
+	Cache = NewResponseCache(time.Minute)
+	defer func() { Cache = nil }()
+
+	// cookie-only sessions must not share cached responses
+	for _, sid := range []string{"s1", "s2"} {
+		s := &Session{}
+		s.SetCookies(u, []*http.Cookie{{Name: "sid", Value: sid, Path: "/"}})
+		res, err := As[string](s.Use(ctx), "Test", "GET", nil)
+		if err != nil {
+			t.Fatalf("request failed: %s", err)
+		}
+		if res != sid {
+			t.Errorf("expected response for session %s, got %s", sid, res)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrShutdown is returned by calls started after their client was shut down
+var ErrShutdown = errors.New("[rest] client is shut down")
+
+// shutdownState tracks in-flight calls of a client for Shutdown
+type shutdownState struct {
+	lk     sync.Mutex
+	closed bool
+	wg     sync.WaitGroup
+	abort  context.Context
+	cancel context.CancelFunc
+}
+
+// inflight returns the state tracking the in-flight calls of c
+func (c *Client) inflight() *shutdownState {
+	c.workOnce.Do(func() { c.work = newShutdownState() })
+	return c.work
+}
+
+func newShutdownState() *shutdownState {
+	s := &shutdownState{}
+	s.abort, s.cancel = context.WithCancel(context.Background())
+	return s
+}
+
+// trackedCall marks contexts of calls already tracked, so nested calls made
+// while completing them (such as the completion of an upload) are allowed
+// during shutdown
+type trackedCall struct{}
+
+// trackCall registers a call starting with ctx with the client of ctx, see
+// shutdownState.track
+func trackCall(ctx context.Context) (context.Context, func(), error) {
+	return contextClient(ctx).inflight().track(ctx)
+}
+
+// track registers a call starting with ctx. It returns the context to use,
+// which is cancelled if Shutdown reaches its deadline, and a function to call
+// once the call is done.
+func (s *shutdownState) track(ctx context.Context) (context.Context, func(), error) {
+	if ctx.Value(trackedCall{}) == s {
+		return ctx, func() {}, nil
+	}
+	s.lk.Lock()
+	if s.closed {
+		s.lk.Unlock()
+		return nil, nil, ErrShutdown
+	}
+	s.wg.Add(1)
+	s.lk.Unlock()
+
+	ctx, cancel := context.WithCancel(context.WithValue(ctx, trackedCall{}, s))
+	stop := context.AfterFunc(s.abort, cancel)
+	return ctx, func() {
+		stop()
+		cancel()
+		s.wg.Done()
+	}, nil
+}
+
+// Shutdown shuts down DefaultClient, which performs calls made with contexts
+// not set up with Client.Use. Calls made with other clients are not
+// affected, see Client.Shutdown.
+func Shutdown(ctx context.Context) error {
+	return DefaultClient.Shutdown(ctx)
+}
+
+// Shutdown stops accepting new calls with c (Do, Apply, uploads and mail
+// sending then fail with ErrShutdown) and waits for in-flight ones to
+// finish. If ctx is done first, in-flight calls are cancelled, which aborts
+// multipart uploads, and Shutdown returns once they have stopped, with the
+// error of ctx. A client cannot be restarted once shut down.
+func (c *Client) Shutdown(ctx context.Context) error {
+	s := c.inflight()
+	s.lk.Lock()
+	s.closed = true
+	s.lk.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.cancel()
+		<-done
+		return ctx.Err()
+	}
+}
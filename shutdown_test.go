@@ -0,0 +1,80 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestShutdown(t *testing.T) {
+	defer func() { DefaultClient = &Client{} }()
+
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/_special/rest/Test:slow" {
+			select {
+			case <-release:
+			case <-r.Context().Done():
+				return
+			}
+		}
+		w.Write([]byte(`{"result":"success","data":{}}`))
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+	ctx := context.WithValue(context.Background(), BackendURL, u)
+
+	// in-flight calls complete before Shutdown returns
+	res := make(chan error, 1)
+	go func() {
+		_, err := Do(ctx, "Test:slow", "GET", nil)
+		res <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		close(release)
+	}()
+	if err := Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown failed: %s", err)
+	}
+	select {
+	case err := <-res:
+		if err != nil {
+			t.Errorf("in-flight call failed: %s", err)
+		}
+	default:
+		t.Errorf("Shutdown returned before in-flight call completed")
+	}
+	if _, err := Do(ctx, "Test", "GET", nil); !errors.Is(err, ErrShutdown) {
+		t.Errorf("expected ErrShutdown, got %v", err)
+	}
+
+	// other clients are not affected
+	c := &Client{BaseURL: u}
+	if _, err := c.Do(context.Background(), "Test", "GET", nil); err != nil {
+		t.Errorf("call with another client failed: %s", err)
+	}
+
+	// in-flight calls are cancelled at the deadline
+	DefaultClient = &Client{}
+	release = make(chan struct{})
+	defer close(release)
+	go func() {
+		_, err := Do(ctx, "Test:slow", "GET", nil)
+		res <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	sctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := Shutdown(sctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline error, got %v", err)
+	}
+	if err := <-res; !errors.Is(err, context.Canceled) {
+		t.Errorf("expected in-flight call to be cancelled, got %v", err)
+	}
+}
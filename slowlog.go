@@ -0,0 +1,96 @@
+package rest
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// SlowRequestThreshold, if positive, causes requests taking longer than this
+// to be logged as a warning with a timing breakdown, even if Debug is off
+var SlowRequestThreshold time.Duration
+
+// requestTiming records the timing of the phases of a request
+type requestTiming struct {
+	lk    sync.Mutex
+	start time.Time
+	// duration of each phase
+	dns, connect, tls, ttfb time.Duration
+	reused                  bool
+
+	dnsStart, connStart, tlsStart time.Time
+}
+
+// traceRequest attaches a requestTiming to r if slow request logging is
+// enabled, returning nil otherwise
+func traceRequest(r *http.Request) (*http.Request, *requestTiming) {
+	if SlowRequestThreshold <= 0 {
+		return r, nil
+	}
+	t := &requestTiming{start: time.Now()}
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.lk.Lock()
+			defer t.lk.Unlock()
+			t.reused = info.Reused
+		},
+		DNSStart: func(httptrace.DNSStartInfo) {
+			t.lk.Lock()
+			defer t.lk.Unlock()
+			t.dnsStart = time.Now()
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			t.lk.Lock()
+			defer t.lk.Unlock()
+			t.dns = time.Since(t.dnsStart)
+		},
+		ConnectStart: func(network, addr string) {
+			t.lk.Lock()
+			defer t.lk.Unlock()
+			t.connStart = time.Now()
+		},
+		ConnectDone: func(network, addr string, err error) {
+			t.lk.Lock()
+			defer t.lk.Unlock()
+			t.connect = time.Since(t.connStart)
+		},
+		TLSHandshakeStart: func() {
+			t.lk.Lock()
+			defer t.lk.Unlock()
+			t.tlsStart = time.Now()
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			t.lk.Lock()
+			defer t.lk.Unlock()
+			t.tls = time.Since(t.tlsStart)
+		},
+		GotFirstResponseByte: func() {
+			t.lk.Lock()
+			defer t.lk.Unlock()
+			t.ttfb = time.Since(t.start)
+		},
+	}
+	return r.WithContext(httptrace.WithClientTrace(r.Context(), trace)), t
+}
+
+// log logs the request if it took longer than SlowRequestThreshold
+func (t *requestTiming) log(ctx context.Context, method, path string) {
+	if t == nil {
+		return
+	}
+	d := time.Since(t.start)
+	if d < SlowRequestThreshold {
+		return
+	}
+	t.lk.Lock()
+	defer t.lk.Unlock()
+
+	slog.WarnContext(ctx, fmt.Sprintf("[rest] slow request %s %s => %s (dns=%s connect=%s tls=%s ttfb=%s reused=%t)", method, path, d, t.dns, t.connect, t.tls, t.ttfb, t.reused),
+		"event", "rest:slow_query", "rest:method", method, "rest:request", path, "rest:duration", d,
+		"rest:dns", t.dns, "rest:connect", t.connect, "rest:tls", t.tls, "rest:ttfb", t.ttfb, "rest:reused", t.reused)
+}
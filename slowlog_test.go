@@ -0,0 +1,88 @@
+package rest
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+)
+
+// eventRecorder is a slog handler keeping the events of logged records
+type eventRecorder struct {
+	lk     sync.Mutex
+	events []string
+}
+
+func (h *eventRecorder) Enabled(context.Context, slog.Level) bool { return true }
+func (h *eventRecorder) WithAttrs([]slog.Attr) slog.Handler       { return h }
+func (h *eventRecorder) WithGroup(string) slog.Handler            { return h }
+
+func (h *eventRecorder) Handle(_ context.Context, r slog.Record) error {
+	r.Attrs(func(a slog.Attr) bool {
+		if a.Key == "event" {
+			h.lk.Lock()
+			defer h.lk.Unlock()
+			h.events = append(h.events, a.Value.String())
+		}
+		return true
+	})
+	return nil
+}
+
+func (h *eventRecorder) count(event string) int {
+	h.lk.Lock()
+	defer h.lk.Unlock()
+	n := 0
+	for _, e := range h.events {
+		if e == event {
+			n += 1
+		}
+	}
+	return n
+}
+
+func TestSlowRequestLog(t *testing.T) {
+	delay := 100 * time.Millisecond
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/_special/rest/Slow" {
+			time.Sleep(delay)
+		}
+		w.Write([]byte(`{"result":"success","data":{}}`))
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+	ctx := context.WithValue(context.Background(), BackendURL, u)
+
+	rec := &eventRecorder{}
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(slog.New(rec))
+	defer func(v time.Duration) { SlowRequestThreshold = v }(SlowRequestThreshold)
+	SlowRequestThreshold = delay / 2
+
+	if _, err := Do(ctx, "Fast", "GET", nil); err != nil {
+		t.Fatalf("request failed: %s", err)
+	}
+	if n := rec.count("rest:slow_query"); n != 0 {
+		t.Errorf("request below the threshold was logged %d times", n)
+	}
+
+	if _, err := Do(ctx, "Slow", "GET", nil); err != nil {
+		t.Fatalf("request failed: %s", err)
+	}
+	if n := rec.count("rest:slow_query"); n != 1 {
+		t.Errorf("expected slow request to be logged once, got %d", n)
+	}
+
+	// disabled when the threshold is not set
+	SlowRequestThreshold = 0
+	if _, err := Do(ctx, "Slow", "GET", nil); err != nil {
+		t.Fatalf("request failed: %s", err)
+	}
+	if n := rec.count("rest:slow_query"); n != 1 {
+		t.Errorf("slow request logged with logging disabled, got %d", n)
+	}
+}
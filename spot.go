@@ -20,8 +20,8 @@ func SpotApply(ctx context.Context, client SpotClient, path, method string, para
 		return err
 	}
 	err = pjson.UnmarshalContext(ctx, res.Data, target)
-	if Debug && err != nil {
-		slog.ErrorContext(ctx, fmt.Sprintf("failed to parse json: %s\n%s", err, res.Data), "event", "rest:not_json")
+	if debugEnabled(ctx) && err != nil {
+		slog.ErrorContext(ctx, fmt.Sprintf("failed to parse json: %s\n%s", err, Redact(string(res.Data))), "event", "rest:not_json")
 	}
 	return err
 }
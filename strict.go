@@ -0,0 +1,64 @@
+package rest
+
+import (
+	"fmt"
+
+	"github.com/KarpelesLab/pjson"
+)
+
+// StrictParsing causes responses that do not follow the API envelope format
+// to be rejected with a *MalformedResponseError: unknown result values,
+// missing fields, or data after the JSON document.
+var StrictParsing = false
+
+// MalformedResponseError is returned in strict mode for responses not
+// following the API envelope format
+type MalformedResponseError struct {
+	Status int    // HTTP status code
+	Reason string // what is wrong with the response
+	Body   []byte
+	Err    error // parse error, if any
+}
+
+func (e *MalformedResponseError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("[rest] malformed response (HTTP %d): %s: %s", e.Status, e.Reason, e.Err)
+	}
+	return fmt.Sprintf("[rest] malformed response (HTTP %d): %s", e.Status, e.Reason)
+}
+
+func (e *MalformedResponseError) Unwrap() error {
+	return e.Err
+}
+
+// checkEnvelope returns a description of what is wrong with the envelope of
+// a parsed response, or an empty string
+func checkEnvelope(body []byte, result *Response, envelope *dataEnvelope) string {
+	if !pjson.Valid(body) {
+		// the document itself was parsed, so something follows it
+		return "trailing data after JSON document"
+	}
+	switch result.Result {
+	case "success":
+		if envelope != nil {
+			if envelope.Data != nil && !envelope.Data.found {
+				return "missing data"
+			}
+		} else if result.Data == nil {
+			return "missing data"
+		}
+	case "error":
+		if result.Error == "" {
+			return "missing error message"
+		}
+	case "redirect":
+		if result.RedirectUrl == "" {
+			return "missing redirect_url"
+		}
+	case "":
+		return "missing result"
+	default:
+		return fmt.Sprintf("unknown result %q", result.Result)
+	}
+	return ""
+}
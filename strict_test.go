@@ -0,0 +1,54 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestStrictParsing(t *testing.T) {
+	var body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(body))
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+	ctx := context.WithValue(context.Background(), BackendURL, u)
+
+	StrictParsing = true
+	defer func() { StrictParsing = false }()
+
+	cases := []struct {
+		body string
+		ok   bool
+	}{
+		{`{"result":"success","data":{"A":1}}`, true},
+		{`{"result":"success","data":null}`, true},
+		{`{"result":"success"}`, false},
+		{`{"result":"maybe","data":1}`, false},
+		{`{"data":1}`, false},
+		{`{"result":"error"}`, false},
+		{`not json`, false},
+	}
+	for _, c := range cases {
+		body = c.body
+		var target map[string]any
+		for _, apply := range []bool{false, true} {
+			var err error
+			if apply {
+				err = Apply(ctx, "Test/obj", "GET", nil, &target)
+			} else {
+				_, err = Do(ctx, "Test/obj", "GET", nil)
+			}
+			var malformed *MalformedResponseError
+			if c.ok && err != nil {
+				t.Errorf("%s: unexpected error %s", c.body, err)
+			} else if !c.ok && !errors.As(err, &malformed) {
+				t.Errorf("%s: expected MalformedResponseError, got %v", c.body, err)
+			}
+		}
+	}
+}
@@ -1,7 +1,11 @@
 package rest
 
 import (
+	"bytes"
 	"context"
+	"math"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/KarpelesLab/pjson"
@@ -25,6 +29,9 @@ func (u *Time) UnmarshalJSON(data []byte) error {
 	if string(data) == "null" {
 		return nil
 	}
+	if u.parseNumber(data) {
+		return nil
+	}
 	var sd timestampInternal
 	err := pjson.Unmarshal(data, &sd)
 	if err != nil {
@@ -34,6 +41,38 @@ func (u *Time) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+// parseNumber sets u from a unix timestamp number, possibly with a decimal
+// part, and returns false if data is not a number
+func (u *Time) parseNumber(data []byte) bool {
+	if len(data) == 0 || (data[0] != '-' && (data[0] < '0' || data[0] > '9')) {
+		return false
+	}
+	if bytes.ContainsAny(data, "eE") {
+		// exponent notation, as produced when encoding float64 values
+		f, err := strconv.ParseFloat(string(data), 64)
+		if err != nil {
+			return false
+		}
+		sec := math.Floor(f)
+		u.Time = time.Unix(int64(sec), int64((f-sec)*1e9))
+		return true
+	}
+	sec, frac, _ := strings.Cut(string(data), ".")
+	unix, err := strconv.ParseInt(sec, 10, 64)
+	if err != nil {
+		return false
+	}
+	var nsec int64
+	if frac != "" {
+		frac = (frac + "000000000")[:9]
+		if nsec, err = strconv.ParseInt(frac, 10, 64); err != nil {
+			return false
+		}
+	}
+	u.Time = time.Unix(unix, nsec)
+	return true
+}
+
 func (u Time) MarshalJSON() ([]byte, error) {
 	var sd timestampInternal
 	sd.Unix = u.Unix()
@@ -51,12 +90,21 @@ func (u *Time) UnmarshalContextJSON(ctx context.Context, data []byte) error {
 	if string(data) == "null" {
 		return nil
 	}
+	if u.parseNumber(data) {
+		if loc := contextTimezone(ctx); loc != nil {
+			u.Time = u.Time.In(loc)
+		}
+		return nil
+	}
 	var sd timestampInternal
 	err := pjson.UnmarshalContext(ctx, data, &sd)
 	if err != nil {
 		return err
 	}
 	u.Time = time.Unix(sd.Unix, sd.Usec*1000) // *1000 means µs → ns
+	if loc := contextTimezone(ctx); loc != nil {
+		u.Time = u.Time.In(loc)
+	}
 	return nil
 }
 
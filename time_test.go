@@ -0,0 +1,106 @@
+package rest
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestLocaleTimezone(t *testing.T) {
+	var lang, errLang, tz string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lang = r.Header.Get("Accept-Language")
+		errLang = r.Header.Get("Sec-Error-Language")
+		tz = r.Header.Get("Sec-Time-Zone")
+		w.Write([]byte(`{"result":"success","data":null}`))
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+	ctx := context.WithValue(context.Background(), BackendURL, u)
+
+	loc := time.FixedZone("JST", 9*3600)
+	ctx = WithTimezone(WithLocale(ctx, "ja-JP"), loc)
+	if _, err := Do(ctx, "Test/obj", "GET", nil); err != nil {
+		t.Fatalf("Do failed: %s", err)
+	}
+	if lang != "ja-JP" || tz != "JST" || errLang != "" {
+		t.Errorf("unexpected headers Accept-Language=%q Sec-Time-Zone=%q Sec-Error-Language=%q", lang, tz, errLang)
+	}
+	if _, err := Do(WithErrorLocale(ctx, "en-US"), "Test/obj", "GET", nil); err != nil {
+		t.Fatalf("Do failed: %s", err)
+	}
+	if lang != "ja-JP" || errLang != "en-US" {
+		t.Errorf("unexpected headers Accept-Language=%q Sec-Error-Language=%q", lang, errLang)
+	}
+
+	var v Time
+	if err := v.UnmarshalContextJSON(ctx, []byte(`{"unix":1597242491,"us":0}`)); err != nil {
+		t.Fatalf("failed to decode time: %s", err)
+	}
+	if v.Location() != loc || v.Hour() != 23 {
+		t.Errorf("expected time in JST, got %s", v)
+	}
+}
+
+func TestGetTime(t *testing.T) {
+	res := &Response{Data: []byte(`{"Created":{"unix":1597242491,"us":747497},"Updated":1597242491.5,"Other":"abc"}`)}
+
+	v, err := res.GetTime("Created")
+	if err != nil || v.Unix() != 1597242491 || v.Nanosecond() != 747497000 {
+		t.Errorf("unexpected time %s, %v", v, err)
+	}
+	v, err = res.GetTime("Updated")
+	if err != nil || v.Unix() != 1597242491 || v.Nanosecond() != 500000000 {
+		t.Errorf("unexpected time %s, %v", v, err)
+	}
+	if _, err := res.GetTime("Other"); err == nil {
+		t.Errorf("expected error for non time value")
+	}
+	if _, err := res.GetTime("Missing"); err == nil {
+		t.Errorf("expected error for missing value")
+	}
+}
+
+func TestServerTime(t *testing.T) {
+	serverTs := time.Now().Add(time.Hour).Unix()
+	defer func() {
+		serverClock.offset, serverClock.samples = 0, 0
+	}()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/_special/rest/Test:duration" {
+			w.Write([]byte(`{"result":"success","data":null,"time":0.0123}`))
+			return
+		}
+		fmt.Fprintf(w, `{"result":"success","data":null,"time":{"unix":%d,"us":0}}`, serverTs)
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+	ctx := context.WithValue(context.Background(), BackendURL, u)
+
+	res, err := Do(ctx, "Test:duration", "GET", nil)
+	if err != nil {
+		t.Fatalf("Do failed: %s", err)
+	}
+	if _, ok := res.ServerTime(); ok {
+		t.Errorf("processing time should not be taken as server time")
+	}
+
+	res, err = Do(ctx, "Test", "GET", nil)
+	if err != nil {
+		t.Fatalf("Do failed: %s", err)
+	}
+	if st, ok := res.ServerTime(); !ok || st.Unix() != serverTs {
+		t.Errorf("unexpected server time %s", st)
+	}
+	offset, ok := ClockOffset()
+	if !ok || offset < 59*time.Minute || offset > 61*time.Minute {
+		t.Errorf("unexpected clock offset %s", offset)
+	}
+	if d := ServerNow().Sub(time.Now()); d < 59*time.Minute {
+		t.Errorf("unexpected server time offset %s", d)
+	}
+}
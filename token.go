@@ -3,6 +3,7 @@ package rest
 import (
 	"context"
 	"errors"
+	"time"
 )
 
 type Token struct {
@@ -21,10 +22,38 @@ type withToken struct {
 }
 
 var (
-	ErrNoClientID     = errors.New("no client_id has been provided for token renewal")
-	ErrNoRefreshToken = errors.New("no refresh token is available and access token has expired")
+	ErrNoClientID         = errors.New("no client_id has been provided for token renewal")
+	ErrNoRefreshToken     = errors.New("no refresh token is available and access token has expired")
+	ErrTokenRenewalFailed = errors.New("token renewal failed")
 )
 
+// TokenRenewTimeout bounds the time spent renewing an expired token,
+// including the retry after a temporary failure. Zero means no limit other
+// than the deadline of the call that triggered the renewal.
+var TokenRenewTimeout = 30 * time.Second
+
+// TokenRenewalError is returned when an expired token could not be renewed.
+// It matches ErrTokenRenewalFailed and the underlying error with errors.Is.
+type TokenRenewalError struct {
+	Err error
+}
+
+func (e *TokenRenewalError) Error() string {
+	return "token renewal failed: " + e.Err.Error()
+}
+
+func (e *TokenRenewalError) Unwrap() []error {
+	return []error{ErrTokenRenewalFailed, e.Err}
+}
+
+// Temporary returns true if renewal failed because of a network error, a
+// timeout or a server error, in which case the token may still be renewed
+// later. Otherwise the token is not usable anymore and a new login is
+// needed.
+func (e *TokenRenewalError) Temporary() bool {
+	return isTemporary(e.Err) || errors.Is(e.Err, context.DeadlineExceeded)
+}
+
 func (w *withToken) Value(v any) any {
 	if _, ok := v.(tokenValue); ok {
 		return w.token
@@ -37,17 +66,48 @@ func (t *Token) Use(ctx context.Context) context.Context {
 	return &withToken{ctx, t}
 }
 
-func (t *Token) renew(ctx context.Context) error {
-	// perform renew of token via OAuth2:token endpoint
-	ctx = &withToken{ctx, nil} // set token to nil
+// contextToken returns the token to use for requests made with ctx
+func contextToken(ctx context.Context) *Token {
+	if t, ok := ctx.Value(tokenValue(0)).(*Token); ok {
+		// a nil token means no token, as used for renewal
+		return t
+	}
+	return contextClient(ctx).token()
+}
 
+// renew renews the token, retrying once after a temporary failure. Errors
+// are returned as *TokenRenewalError.
+func (t *Token) renew(ctx context.Context) error {
 	if t.ClientID == "" {
-		return ErrNoClientID
+		return &TokenRenewalError{Err: ErrNoClientID}
 	}
 	if t.RefreshToken == "" {
-		return ErrNoRefreshToken
+		return &TokenRenewalError{Err: ErrNoRefreshToken}
+	}
+
+	if TokenRenewTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, TokenRenewTimeout)
+		defer cancel()
 	}
 
+	err := t.renewOnce(ctx)
+	if err != nil && isTemporary(err) && ctx.Err() == nil {
+		if sleep(ctx, retryDelay(err, 500*time.Millisecond)) == nil {
+			err = t.renewOnce(ctx)
+		}
+	}
+	if err != nil {
+		return &TokenRenewalError{Err: err}
+	}
+	return nil
+}
+
+// renewOnce performs a renewal request
+func (t *Token) renewOnce(ctx context.Context) error {
+	// perform renew of token via OAuth2:token endpoint
+	ctx = &withToken{ctx, nil} // set token to nil
+
 	req := map[string]any{
 		"grant_type":    "refresh_token",
 		"client_id":     t.ClientID,
@@ -55,7 +115,7 @@ func (t *Token) renew(ctx context.Context) error {
 		"noraw":         true,
 	}
 
-	err := Apply(ctx, "OAuth2:token", "POST", req, t)
+	err := Apply(ctx, OAuth2Token.String(), "POST", req, t)
 	if err != nil {
 		return err
 	}
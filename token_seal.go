@@ -0,0 +1,122 @@
+package rest
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+
+	"github.com/KarpelesLab/pjson"
+)
+
+// SealIterations is the number of PBKDF2 rounds used to derive the key of
+// sealed tokens from a passphrase
+var SealIterations = 600000
+
+var ErrWrongPassphrase = errors.New("wrong passphrase or corrupted sealed token")
+
+// sealedToken is the format of tokens encrypted with a passphrase: AES-256-GCM
+// with a key derived using PBKDF2-HMAC-SHA256
+type sealedToken struct {
+	Version int    `json:"sealed"`
+	Iter    int    `json:"iter"`
+	Salt    []byte `json:"salt"`
+	Nonce   []byte `json:"nonce"`
+	Data    []byte `json:"data"`
+}
+
+// SealToken returns tok encrypted with passphrase, so it can be stored
+// without exposing credentials. See OpenToken.
+func SealToken(tok *Token, passphrase string) ([]byte, error) {
+	buf, err := pjson.Marshal(tok)
+	if err != nil {
+		return nil, err
+	}
+	s := &sealedToken{Version: 1, Iter: SealIterations, Salt: make([]byte, 16)}
+	if _, err := rand.Read(s.Salt); err != nil {
+		return nil, err
+	}
+	aead, err := sealCipher(passphrase, s)
+	if err != nil {
+		return nil, err
+	}
+	s.Nonce = make([]byte, aead.NonceSize())
+	if _, err := rand.Read(s.Nonce); err != nil {
+		return nil, err
+	}
+	s.Data = aead.Seal(nil, s.Nonce, buf, nil)
+	return pjson.Marshal(s)
+}
+
+// OpenToken decrypts a token sealed with SealToken
+func OpenToken(sealed []byte, passphrase string) (*Token, error) {
+	var s *sealedToken
+	if err := pjson.Unmarshal(sealed, &s); err != nil || s == nil || s.Version == 0 {
+		return nil, errors.New("data is not a sealed token")
+	}
+	if s.Version != 1 {
+		return nil, fmt.Errorf("unsupported sealed token version %d", s.Version)
+	}
+	aead, err := sealCipher(passphrase, s)
+	if err != nil {
+		return nil, err
+	}
+	if len(s.Nonce) != aead.NonceSize() {
+		return nil, ErrWrongPassphrase
+	}
+	buf, err := aead.Open(nil, s.Nonce, s.Data, nil)
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+	var tok *Token
+	if err := pjson.Unmarshal(buf, &tok); err != nil {
+		return nil, err
+	}
+	return tok, nil
+}
+
+// IsSealedToken returns true if data looks like a token sealed with
+// SealToken rather than a plain token
+func IsSealedToken(data []byte) bool {
+	if !bytes.Contains(data, []byte(`"sealed"`)) {
+		return false
+	}
+	var s sealedToken
+	return pjson.Unmarshal(data, &s) == nil && s.Version != 0
+}
+
+func sealCipher(passphrase string, s *sealedToken) (cipher.AEAD, error) {
+	if s.Iter <= 0 || len(s.Salt) == 0 {
+		return nil, errors.New("invalid sealed token parameters")
+	}
+	key := pbkdf2Key([]byte(passphrase), s.Salt, s.Iter)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// pbkdf2Key derives a 32 bytes key from password (RFC 8018, with a single
+// block of HMAC-SHA256)
+func pbkdf2Key(password, salt []byte, iter int) []byte {
+	prf := hmac.New(func() hash.Hash { return Crypto.NewSHA256() }, password)
+	prf.Write(salt)
+	prf.Write(binary.BigEndian.AppendUint32(nil, 1))
+	u := prf.Sum(nil)
+	key := bytes.Clone(u)
+	for i := 1; i < iter; i++ {
+		prf.Reset()
+		prf.Write(u)
+		u = prf.Sum(u[:0])
+		for j := range key {
+			key[j] ^= u[j]
+		}
+	}
+	return key
+}
@@ -0,0 +1,37 @@
+package rest
+
+import (
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+func TestSealToken(t *testing.T) {
+	// RFC 7914 section 11 test vector for PBKDF2-HMAC-SHA256
+	if k := hex.EncodeToString(pbkdf2Key([]byte("passwd"), []byte("salt"), 1)); k != "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc" {
+		t.Errorf("unexpected pbkdf2 key %s", k)
+	}
+
+	SealIterations = 1000
+	defer func() { SealIterations = 600000 }()
+
+	tok := &Token{AccessToken: "access", RefreshToken: "refresh", ClientID: "client"}
+	sealed, err := SealToken(tok, "secret")
+	if err != nil {
+		t.Fatalf("failed to seal token: %s", err)
+	}
+	if !IsSealedToken(sealed) || IsSealedToken([]byte(`{"access_token":"abc"}`)) {
+		t.Errorf("IsSealedToken does not recognize sealed tokens")
+	}
+
+	res, err := OpenToken(sealed, "secret")
+	if err != nil {
+		t.Fatalf("failed to open token: %s", err)
+	}
+	if *res != *tok {
+		t.Errorf("unexpected token %+v", res)
+	}
+	if _, err := OpenToken(sealed, "wrong"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("expected wrong passphrase error, got %v", err)
+	}
+}
@@ -0,0 +1,93 @@
+package rest
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Traffic accumulates the traffic of all requests performed through Do, see
+// also Client.Traffic
+var Traffic = &TrafficCounter{}
+
+// TrafficStats holds traffic totals
+type TrafficStats struct {
+	Requests      int64
+	BytesSent     int64
+	BytesReceived int64
+}
+
+// TrafficCounter accumulates the traffic of requests. It implements
+// MetricsCollector so it can also be used to count traffic of the Router.
+type TrafficCounter struct {
+	// PerEndpoint enables keeping separate totals for each endpoint
+	PerEndpoint bool
+	// EndpointKey returns the key under which traffic for path is counted,
+	// defaults to the path itself. Paths containing object IDs should be
+	// mapped to a common key to keep the number of endpoints bounded.
+	EndpointKey func(path string) string
+
+	requests, sent, received atomic.Int64
+
+	lk        sync.Mutex
+	endpoints map[string]*TrafficStats
+}
+
+func (t *TrafficCounter) ObserveRequest(info *RequestInfo) {
+	sent := max(info.BytesOut, 0)
+	t.requests.Add(1)
+	t.sent.Add(sent)
+	t.received.Add(info.BytesIn)
+
+	if !t.PerEndpoint {
+		return
+	}
+	key := info.Path
+	if t.EndpointKey != nil {
+		key = t.EndpointKey(key)
+	}
+
+	t.lk.Lock()
+	defer t.lk.Unlock()
+	if t.endpoints == nil {
+		t.endpoints = make(map[string]*TrafficStats)
+	}
+	st, ok := t.endpoints[key]
+	if !ok {
+		st = &TrafficStats{}
+		t.endpoints[key] = st
+	}
+	st.Requests += 1
+	st.BytesSent += sent
+	st.BytesReceived += info.BytesIn
+}
+
+// Total returns the traffic totals for all requests
+func (t *TrafficCounter) Total() TrafficStats {
+	return TrafficStats{
+		Requests:      t.requests.Load(),
+		BytesSent:     t.sent.Load(),
+		BytesReceived: t.received.Load(),
+	}
+}
+
+// Endpoints returns the traffic totals for each endpoint, if PerEndpoint is
+// enabled
+func (t *TrafficCounter) Endpoints() map[string]TrafficStats {
+	t.lk.Lock()
+	defer t.lk.Unlock()
+	res := make(map[string]TrafficStats, len(t.endpoints))
+	for k, st := range t.endpoints {
+		res[k] = *st
+	}
+	return res
+}
+
+// Reset sets all counters back to zero
+func (t *TrafficCounter) Reset() {
+	t.lk.Lock()
+	defer t.lk.Unlock()
+	t.requests.Store(0)
+	t.sent.Store(0)
+	t.received.Store(0)
+	t.endpoints = nil
+}
@@ -0,0 +1,52 @@
+package rest
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTrafficCounter(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"result":"error","error":"busy","code":503}`))
+			return
+		}
+		w.Write([]byte(`{"result":"success","data":null}`))
+	}))
+	defer srv.Close()
+
+	SetPolicy("Test:traffic", &EndpointPolicy{Retries: 1, RetryDelay: time.Millisecond})
+	defer SetPolicy("Test:traffic", nil)
+
+	c, _ := NewClient(srv.URL)
+	c.Traffic = &TrafficCounter{PerEndpoint: true}
+	if _, err := c.Do(context.Background(), "Test:traffic", "PUT", Param{"a": 1}); err != nil {
+		t.Fatalf("Do failed: %s", err)
+	}
+
+	// both attempts are counted as a single request
+	want := TrafficStats{
+		Requests:      1,
+		BytesSent:     2 * int64(len(`{"a":1}`)),
+		BytesReceived: int64(len(`{"result":"error","error":"busy","code":503}`) + len(`{"result":"success","data":null}`)),
+	}
+	if got := c.Traffic.Total(); got != want {
+		t.Errorf("unexpected traffic %+v, expected %+v", got, want)
+	}
+	if got := c.Traffic.Endpoints()["Test:traffic"]; got != want {
+		t.Errorf("unexpected endpoint traffic %+v", got)
+	}
+
+	c.Traffic.Reset()
+	if got := c.Traffic.Total(); got != (TrafficStats{}) {
+		t.Errorf("unexpected traffic after reset %+v", got)
+	}
+}
@@ -3,15 +3,12 @@ package rest
 import (
 	"bytes"
 	"context"
-	"crypto/sha256"
 	"encoding/hex"
-	"encoding/xml"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
-	"os"
 	"sort"
 	"strings"
 	"sync"
@@ -27,21 +24,59 @@ type UploadInfo struct {
 	MaxPartSize     int64 // maximum size of a single part in MB, defaults to 1024 (1GB)
 	ParallelUploads int   // number of parallel uploads to perform (defaults to 3)
 
+	Retries      int           // number of times a failed part is retried, defaults to 0
+	RetryDelay   time.Duration // delay before the first retry, doubled on each retry (defaults to 1s)
+	StallTimeout time.Duration // abort a request when no data could be sent for this long, disabled if zero
+	SkipVerify   bool          // do not check the size and hash returned by the server on completion
+	ID           string        // identifies the upload in progress events, logs and errors, a random UUID if empty
+
+	name string // file name from the upload parameters
+
 	// put upload
 	blocksize int64
+	partSize  int64 // size of parts of the current upload, if split
 
 	// aws upload
-	awsid     string
-	awskey    string
-	awsregion string
-	awsname   string
-	awshost   string
+	awsid      string
+	awskey     string
+	endpoint   uploadEndpoint   // endpoint in use
+	endpoints  []uploadEndpoint // all offered endpoints, fastest first once probed
+	failed     map[string]bool  // hosts of endpoints that failed
+	endpointLk sync.Mutex
 
 	awsuploadid string // used during upload
 	awstags     []string
 	awstagsLk   sync.Mutex
+
+	// resume
+	info    map[string]any
+	done    map[int]string // completed parts, with their etag for aws uploads
+	doneLk  sync.Mutex
+	OnState func(state *UploadState) // called each time the upload progresses, see State
+	OnAbort func(abort *UploadAbort) // called after a failed upload with the cleanup performed
+
+	// progress
+	size       int64
+	sent       int64
+	progress   []UploadProgressListener
+	progressLk sync.Mutex
+
+	// statistics
+	result   UploadResult
+	resultLk sync.Mutex
+
+	// integrity
+	checksum string // checksum algorithm selected by the server
+	hash     *hashReader
+
+	// cleanup
+	abort UploadAbort
 }
 
+// UploadProgressFunc is called during uploads with the number of bytes sent
+// so far and the total size, or -1 if unknown
+type UploadProgressFunc func(sent, total int64)
+
 type uploadAuth struct {
 	Authorization string `json:"authorization"`
 }
@@ -53,21 +88,10 @@ type uploadAwsResp struct {
 }
 
 func Upload(ctx context.Context, req, method string, param Param, f io.Reader, mimeType string) (*Response, error) {
-	var upinfo map[string]any
-
-	err := Apply(ctx, req, method, param, &upinfo)
-	if err != nil {
-		return nil, fmt.Errorf("initial upload query failed: %w", err)
-	}
-
-	up, err := PrepareUpload(upinfo)
-	if err != nil {
-		return nil, fmt.Errorf("upload prepare failed: %w", err)
-	}
-
 	ln := int64(-1)
 
 	if fs, ok := f.(io.Seeker); ok {
+		var err error
 		ln, err = fs.Seek(0, io.SeekEnd)
 		if err != nil {
 			// seek failed, let's continue in the unknown
@@ -78,6 +102,42 @@ func Upload(ctx context.Context, req, method string, param Param, f io.Reader, m
 		}
 	}
 
+	return upload(ctx, req, method, param, f, mimeType, ln)
+}
+
+// upload performs an upload of ln bytes (or -1 if unknown) read from f
+func upload(ctx context.Context, req, method string, param Param, f io.Reader, mimeType string, ln int64) (*Response, error) {
+	ctx, done, err := trackCall(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	policy := policyFor(req)
+	ctx, cancel := withDefaultTimeout(ctx, policy.timeout(DefaultUploadTimeout))
+	defer cancel()
+
+	var upinfo map[string]any
+
+	// let the server know which checksums we can compute
+	qctx := WithRequestMutator(ctx, func(r *http.Request) {
+		r.Header.Set("Sec-Rest-Checksum", supportedChecksums())
+	})
+	err = Apply(qctx, req, method, param, &upinfo)
+	if err != nil {
+		return nil, fmt.Errorf("initial upload query failed: %w", err)
+	}
+
+	up, err := PrepareUpload(upinfo)
+	if err != nil {
+		return nil, fmt.Errorf("upload prepare failed: %w", err)
+	}
+	up.name, _ = param["filename"].(string)
+	if policy != nil && up.Retries == 0 {
+		up.Retries = policy.Retries
+		up.RetryDelay = policy.RetryDelay
+	}
+
 	return up.Do(ctx, f, mimeType, ln)
 }
 
@@ -106,8 +166,14 @@ func (u *UploadInfo) String() string {
 func (u *UploadInfo) parse(req map[string]any) error {
 	var ok bool
 
+	u.info = req
+
 	//log.Printf("parsing upload response: %+v", req)
 
+	if algo, ok := req["Checksum"].(string); ok {
+		u.checksum = strings.ToLower(algo)
+	}
+
 	// strict minimum: PUT & Complete
 	u.put, ok = req["PUT"].(string)
 	if !ok {
@@ -124,6 +190,8 @@ func (u *UploadInfo) parse(req map[string]any) error {
 	// * Bucket_Endpoint.Region
 	// * Bucket_Endpoint.Name
 	// * Bucket_Endpoint.Host
+	// * Bucket_Endpoint.Hosts (optional, other hosts for the same bucket)
+	// * Bucket_Endpoints (optional, other endpoints)
 
 	// if we can't grab any of these, drop the whole thing and not set u.awsid so it won't be used
 
@@ -137,24 +205,11 @@ func (u *UploadInfo) parse(req map[string]any) error {
 		}
 		return nil
 	}
-	bucket, ok := req["Bucket_Endpoint"].(map[string]any)
-	if !ok {
-		return nil
-	}
 	u.awskey, ok = req["Key"].(string)
 	if !ok {
 		return nil
 	}
-	u.awsregion, ok = bucket["Region"].(string)
-	if !ok {
-		return nil
-	}
-	u.awsname = bucket["Name"].(string)
-	if !ok {
-		return nil
-	}
-	u.awshost = bucket["Host"].(string)
-	if !ok {
+	if !u.parseEndpoints(req) {
 		return nil
 	}
 	// all ok, set awsid
@@ -163,8 +218,57 @@ func (u *UploadInfo) parse(req map[string]any) error {
 	return nil
 }
 
-func (u *UploadInfo) Do(ctx context.Context, f io.Reader, mimeType string, ln int64) (*Response, error) {
+func (u *UploadInfo) Do(ctx context.Context, f io.Reader, mimeType string, ln int64) (res *Response, err error) {
+	defer func() {
+		// progress and state callbacks may panic
+		if r := recover(); r != nil {
+			res, err = nil, newPanicError(r)
+		}
+	}()
+	ctx, done, err := trackCall(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	ctx, cancel := withDefaultTimeout(ctx, DefaultUploadTimeout)
+	defer cancel()
+
 	u.ctx = ctx
+	u.size = ln
+	u.sent = 0
+	u.partSize = 0
+	u.progress = progressListeners(ctx)
+	if u.ID == "" {
+		u.ID = newUploadID()
+	}
+	if mimeType == "" {
+		// some buckets reject an empty content type
+		mimeType, f, err = detectContentType(f, ln, u.uploadName(ctx))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read upload %s: %w", u.shortID(), err)
+		}
+	}
+	f = u.hashReader(f)
+	u.abort = UploadAbort{}
+	defer func() {
+		if err != nil {
+			u.notifyAbort(err)
+		}
+	}()
+
+	start := now()
+	u.updateResult(func(r *UploadResult) { *r = UploadResult{Method: UploadMethodPut, ContentType: mimeType} })
+	defer func() {
+		d := now().Sub(start)
+		u.progressLk.Lock()
+		sent := u.sent
+		u.progressLk.Unlock()
+		u.updateResult(func(r *UploadResult) {
+			r.Bytes = sent
+			r.Duration = d
+		})
+	}()
 
 	if u.blocksize > 0 {
 		return u.partUpload(f, mimeType)
@@ -178,227 +282,233 @@ func (u *UploadInfo) Do(ctx context.Context, f io.Reader, mimeType string, ln in
 	if ln == -1 || ln > 5*1024*1024*1024 {
 		return nil, errors.New("cannot upload using PUT method without a known length of less than 5GB")
 	}
+	// we can use simple PUT, and retry if we can seek back to the start
+	body := u.progressReader(f)
+	seeker, canRetry := body.(io.Seeker)
+	err = u.retry(0, func(attempt int) error {
+		if attempt > 0 {
+			if !canRetry {
+				return errors.New("upload failed and cannot be retried on a non seekable source")
+			}
+			if _, err := seeker.Seek(0, io.SeekStart); err != nil {
+				return err
+			}
+		}
+		return u.doPut(body, ln, mimeType, "")
+	})
+	if err != nil {
+		return nil, fmt.Errorf("upload %s failed: %w", u.shortID(), err)
+	}
+	u.updateResult(func(r *UploadResult) { r.Parts = 1 })
+
+	return u.complete()
+}
+
+// doPut sends ln bytes from body to the upload PUT url
+func (u *UploadInfo) doPut(body io.Reader, ln int64, mimeType, contentRange string) error {
+	ctx := u.ctx
+	seeker, canReplay := body.(io.Seeker)
 	if ln == 0 {
 		// workaround bug with go http client when ContentLength it set to zero
-		f = bytes.NewReader([]byte{})
+		body = http.NoBody
+		canReplay = false
+	} else {
+		var cancel func()
+		ctx, body, cancel = withStallTimeout(ctx, body, u.StallTimeout)
+		defer cancel()
 	}
 
-	// we can use simple PUT
-	req, err := http.NewRequestWithContext(ctx, http.MethodPut, u.put, f)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, u.put, body)
 	if err != nil {
-		return nil, err
+		return err
+	}
+	if canReplay {
+		req.GetBody = seekGetBody(seeker, body)
 	}
 
 	req.ContentLength = ln
 	req.Header.Set("Content-Type", mimeType)
+	if contentRange != "" {
+		req.Header.Set("Content-Range", contentRange)
+	}
+
+	limiter := contextClient(ctx).limiter()
+	if err := limiter.Acquire(ctx); err != nil {
+		return err
+	}
+	defer limiter.Release()
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := UploadHttpClient.Do(req)
 	if err != nil {
-		return nil, err
+		return stallError(ctx, err)
 	}
 	defer resp.Body.Close() // avoid leaking stuff
-	// read full response, discard (ensures upload completed)
-	io.Copy(ioutil.Discard, resp.Body)
-
-	return u.complete()
+	// read full response (ensures upload completed)
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return stallError(ctx, err)
+	}
+	if resp.StatusCode >= 400 {
+		return newHttpError(resp, respBody, nil)
+	}
+	return nil
 }
 
-func (u *UploadInfo) complete() (*Response, error) {
-	return Do(u.ctx, u.cmpl, "POST", map[string]any{})
+// getUploadProgress returns the progress callback set in ctx, if any
+func getUploadProgress(ctx context.Context) UploadProgressFunc {
+	switch f := ctx.Value(UploadProgress).(type) {
+	case UploadProgressFunc:
+		return f
+	case func(sent, total int64):
+		return f
+	default:
+		return nil
+	}
 }
 
-func (u *UploadInfo) partUpload(f io.Reader, mimeType string) (*Response, error) {
-	// partUpload works similar to awsUpload but when uploading to the new kind of PUT server
-
-	// let's upload
-	partNo := 0
-	errCh := make(chan error, 2) // enough just in case
-	nwg := newNWG()
-
-	eof := false
-	for !eof {
-		nwg.Wait(u.ParallelUploads - 1)
-		partNo += 1
-
-		readCh := make(chan error)
-
-		nwg.Add(1)
-		go u.partUploadPart(f, mimeType, partNo, readCh, errCh, nwg)
-
-		select {
-		case err := <-readCh:
-			if err == io.EOF {
-				eof = true
-			} else if err != nil {
-				// fatal error
-				return nil, err
-			}
-		case err := <-errCh:
-			// fatal error
-			return nil, err
+// reportProgress records that n more bytes have been uploaded and notifies
+// the progress listeners, if any
+func (u *UploadInfo) reportProgress(part int, n int64) {
+	u.progressLk.Lock()
+	defer u.progressLk.Unlock()
+
+	u.sent += n
+	if len(u.progress) > 0 {
+		ev := &UploadProgressEvent{ID: u.ID, Part: part, Sent: u.sent, Total: u.size}
+		for _, l := range u.progress {
+			l(ev)
 		}
 	}
+}
 
-	// wait for nwg completion
-	go func() {
-		nwg.Wait(0)
-		// send "no error"
-		select {
-		case errCh <- nil:
-		default:
-			// do not wait if send fails
-		}
-	}()
-
-	// read & check error (cause waiting for completion)
-	err := <-errCh
-	if err != nil {
-		// fatal error
-		return nil, err
+// progressReader returns a reader reporting progress as data is read from
+// f. If f is an io.Seeker, the returned reader is too, and seeking moves the
+// progress accordingly.
+func (u *UploadInfo) progressReader(f io.Reader) io.Reader {
+	r := &progressReader{r: f, u: u}
+	if s, ok := f.(io.Seeker); ok {
+		return &progressReadSeeker{progressReader: r, s: s}
 	}
-
-	// finalize
-	return u.complete()
+	return r
 }
 
-func (u *UploadInfo) partUploadPart(f io.Reader, mimeType string, partNo int, readCh, errCh chan<- error, nwg *numeralWaitGroup) {
-	// prepare to upload a part
-	defer nwg.Done()
-
-	// we use temp files as to avoid using too much memory
-	tmpf, err := ioutil.TempFile("", "upload*.bin")
-	if err != nil {
-		// failed to create temp file
-		readCh <- err
-		return
-	}
-	// cleanup
-	defer func() {
-		tmpf.Close()
-		os.Remove(tmpf.Name())
-	}()
+type progressReader struct {
+	r   io.Reader
+	u   *UploadInfo
+	pos int64
+}
 
-	n, err := io.CopyN(tmpf, f, u.blocksize)
-	if err != nil {
-		if err != io.EOF {
-			// fatal error
-			errCh <- err
-			return
-		}
-		readCh <- err
-		if n == 0 {
-			return
-		}
-	} else if n == 0 {
-		// no data to upload, just return EOF
-		readCh <- io.EOF
-		return
-	} else {
-		// end of read
-		readCh <- nil
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	if n > 0 {
+		p.pos += int64(n)
+		p.u.reportProgress(0, int64(n))
 	}
+	return n, err
+}
 
-	// rewind tmpf
-	tmpf.Seek(0, io.SeekStart)
+type progressReadSeeker struct {
+	*progressReader
+	s io.Seeker
+}
 
-	// we can use simple PUT
-	req, err := http.NewRequestWithContext(u.ctx, http.MethodPut, u.put, tmpf)
+func (p *progressReadSeeker) Seek(offset int64, whence int) (int64, error) {
+	pos, err := p.s.Seek(offset, whence)
 	if err != nil {
-		select {
-		case errCh <- err:
-		default:
-		}
-		return
+		return pos, err
 	}
+	p.u.reportProgress(0, pos-p.pos)
+	p.pos = pos
+	return pos, nil
+}
 
-	start := int64(partNo-1) * u.blocksize
-	end := start + n - 1 // inclusive
-
-	req.ContentLength = n // from io.CopyN
-	req.Header.Set("Content-Type", mimeType)
-	req.Header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/*", start, end))
-
-	// perform upload
-	resp, err := http.DefaultClient.Do(req)
+func (u *UploadInfo) complete() (*Response, error) {
+	res, err := Do(u.ctx, u.cmpl, "POST", map[string]any{})
 	if err != nil {
-		select {
-		case errCh <- err:
-		default:
-		}
-		return
+		return nil, err
 	}
-	defer resp.Body.Close() // avoid leaking stuff
-	// read full response, discard (ensures upload completed)
-	_, err = io.Copy(ioutil.Discard, resp.Body)
-	if err != nil {
-		select {
-		case errCh <- err:
-		default:
-		}
-		return
+	err = u.verify(res)
+	if jerr := u.record(res, err); jerr != nil && err == nil {
+		err = jerr
 	}
+	return res, err
 }
 
-func (u *UploadInfo) awsUpload(f io.Reader, mimeType string) (*Response, error) {
-	// awsUpload is a magic method that does not need to know upload length as it will split file into manageable sized pieces.
-	err := u.awsInit(mimeType)
+func (u *UploadInfo) partUpload(f io.Reader, mimeType string) (*Response, error) {
+	// partUpload works similar to awsUpload but when uploading to the new kind of PUT server
+	u.updateResult(func(r *UploadResult) { r.Method = UploadMethodPart })
+	err := u.uploadParts(f, u.blocksize, false, func(p *uploadPart) error {
+		start := int64(p.no-1) * u.blocksize
+		end := start + p.size - 1 // inclusive
+
+		// perform upload using simple PUT
+		err := u.retry(p.no, func(attempt int) error {
+			return u.doPut(p.reader(), p.size, mimeType, fmt.Sprintf("bytes %d-%d/*", start, end))
+		})
+		if err != nil {
+			return err
+		}
+		u.partDone(p.no, "")
+		u.reportProgress(p.no, p.size)
+		u.updateResult(func(r *UploadResult) { r.Parts += 1 })
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	// let's upload
-	partNo := 0
-	errCh := make(chan error, 2) // enough just in case
-	nwg := newNWG()
-
-	eof := false
-	for !eof {
-		nwg.Wait(u.ParallelUploads - 1)
-		partNo += 1
-
-		readCh := make(chan error)
-
-		nwg.Add(1)
-		go u.awsUploadPart(f, partNo, readCh, errCh, nwg)
+	// finalize
+	return u.complete()
+}
 
-		select {
-		case err := <-readCh:
-			if err == io.EOF {
-				eof = true
-			} else if err != nil {
-				// fatal error, give up
-				u.awsAbort()
-				return nil, err
-			}
-		case err := <-errCh:
-			// fatal error, give up
-			u.awsAbort()
+func (u *UploadInfo) awsUpload(f io.Reader, mimeType string) (*Response, error) {
+	// awsUpload is a magic method that does not need to know upload length as it will split file into manageable sized pieces.
+	u.updateResult(func(r *UploadResult) { r.Method = UploadMethodAWS })
+	if u.awsuploadid == "" {
+		u.selectEndpoint()
+		err := u.awsInit(mimeType)
+		if err != nil {
 			return nil, err
 		}
-	}
-
-	// wait for nwg completion
-	go func() {
-		nwg.Wait(0)
-		// send "no error"
-		select {
-		case errCh <- nil:
-		default:
-			// do not wait if send fails
+		u.saveState()
+	}
+	host := u.currentEndpoint().Host
+	u.updateResult(func(r *UploadResult) { r.Endpoint = host })
+
+	// let's upload, part 1 is uploaded even if empty
+	err := u.uploadParts(f, u.MaxPartSize*1024*1024, true, func(p *uploadPart) error {
+		var tag string
+		err := u.retry(p.no, func(attempt int) error {
+			resp, err := u.awsReq("PUT", fmt.Sprintf("partNumber=%d&uploadId=%s", p.no, u.awsuploadid), p.reader(), nil)
+			if err != nil {
+				return err
+			}
+			defer resp.Body.Close()
+			_, err = io.Copy(ioutil.Discard, resp.Body)
+			tag = resp.Header.Get("Etag")
+			return err
+		})
+		if err != nil {
+			return err
 		}
-	}()
 
-	// read & check error (cause waiting for completion)
-	err = <-errCh
+		// store etag value
+		u.setTag(p.no, tag)
+		u.partDone(p.no, tag)
+		u.reportProgress(p.no, p.size)
+		u.updateResult(func(r *UploadResult) { r.Parts += 1 })
+		return nil
+	})
 	if err != nil {
-		// fatal error
-		u.awsAbort()
+		// fatal error, give up
+		u.abortAws()
 		return nil, err
 	}
 
 	// finalize
 	err = u.awsFinalize()
 	if err != nil {
+		u.abortAws()
 		return nil, err
 	}
 
@@ -425,68 +535,6 @@ func (u *UploadInfo) awsFinalize() error {
 	return err
 }
 
-func (u *UploadInfo) awsUploadPart(f io.Reader, partNo int, readCh, errCh chan<- error, nwg *numeralWaitGroup) {
-	// prepare to upload a part
-	defer nwg.Done()
-
-	// maxLen in MB
-	maxLen := u.MaxPartSize
-
-	tmpf, err := ioutil.TempFile("", "upload*.bin")
-	if err != nil {
-		// failed to create temp file
-		readCh <- err
-		return
-	}
-	// cleanup
-	defer func() {
-		tmpf.Close()
-		os.Remove(tmpf.Name())
-	}()
-
-	n, err := io.CopyN(tmpf, f, maxLen*1024*1024)
-	if err != nil {
-		if err != io.EOF {
-			// fatal error
-			errCh <- err
-			return
-		}
-		readCh <- err
-		if n == 0 && partNo != 1 {
-			return
-		}
-	} else if n == 0 && partNo != 1 {
-		// no data to upload, just return EOF unless we are part #1
-		readCh <- io.EOF
-		return
-	} else {
-		// end of read
-		readCh <- nil
-	}
-
-	// need to upload to aws
-	resp, err := u.awsReq("PUT", fmt.Sprintf("partNumber=%d&uploadId=%s", partNo, u.awsuploadid), tmpf, nil)
-	if err != nil {
-		select {
-		case errCh <- err:
-		default:
-		}
-		return
-	}
-	defer resp.Body.Close()
-	_, err = io.Copy(ioutil.Discard, resp.Body)
-	if err != nil {
-		select {
-		case errCh <- err:
-		default:
-		}
-		return
-	}
-
-	// store etag value
-	u.setTag(partNo, resp.Header.Get("Etag"))
-}
-
 func (u *UploadInfo) setTag(partNo int, tag string) {
 	u.awstagsLk.Lock()
 	defer u.awstagsLk.Unlock()
@@ -524,10 +572,7 @@ func (u *UploadInfo) awsInit(mimeType string) error {
 	}
 	defer resp.Body.Close()
 
-	dec := xml.NewDecoder(resp.Body)
-
-	res := &uploadAwsResp{}
-	err = dec.Decode(res)
+	res, err := decodeAwsResp(resp.Body)
 	if err != nil {
 		return err
 	}
@@ -566,7 +611,7 @@ func (u *UploadInfo) awsReq(method, query string, body io.ReadSeeker, headers ht
 	if ln == 0 {
 		bodyHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855" // sha256("")
 	} else {
-		h := sha256.New()
+		h := Crypto.NewSHA256()
 		_, err := io.Copy(h, body)
 		if err != nil {
 			return nil, err
@@ -576,8 +621,9 @@ func (u *UploadInfo) awsReq(method, query string, body io.ReadSeeker, headers ht
 		bodyHash = hex.EncodeToString(h.Sum(nil))
 	}
 
-	ts := time.Now().UTC().Format("20060102T150405Z") // amz format
-	tsD := ts[:8]                                     // YYYYMMDD
+	ep := u.currentEndpoint()
+	ts := ServerNow().UTC().Format("20060102T150405Z") // amz format, corrected for local clock skew
+	tsD := ts[:8]                                      // YYYYMMDD
 
 	headers.Set("X-Amz-Content-Sha256", bodyHash)
 	headers.Set("X-Amz-Date", ts)
@@ -585,11 +631,11 @@ func (u *UploadInfo) awsReq(method, query string, body io.ReadSeeker, headers ht
 	awsAuthStr := []string{
 		"AWS4-HMAC-SHA256",
 		ts,
-		tsD + "/" + u.awsregion + "/s3/aws4_request",
+		tsD + "/" + ep.Region + "/s3/aws4_request",
 		method,
-		"/" + u.awsname + "/" + u.awskey,
+		"/" + ep.Name + "/" + u.awskey,
 		query,
-		"host:" + u.awshost,
+		"host:" + ep.Host,
 	}
 
 	// list headers to sign (host and anything starting with x-)
@@ -625,28 +671,59 @@ func (u *UploadInfo) awsReq(method, query string, body io.ReadSeeker, headers ht
 	headers.Set("Authorization", auth.Authorization)
 
 	// perform the query
-	target := "https://" + u.awshost + "/" + u.awsname + "/" + u.awskey
+	target := "https://" + ep.Host + "/" + ep.Name + "/" + u.awskey
 	if query != "" {
 		target += "?" + query
 	}
-	req, err := http.NewRequestWithContext(u.ctx, method, target, body)
+	ctx := u.ctx
+	var reqBody io.Reader = body
+	cancel := func() {}
+	if body != nil && ln > 0 {
+		ctx, reqBody, cancel = withStallTimeout(ctx, body, u.StallTimeout)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, target, reqBody)
 	if err != nil {
+		cancel()
 		return nil, err
 	}
+	if body != nil && ln > 0 {
+		req.GetBody = seekGetBody(body, reqBody)
+	}
 	for k, v := range headers {
 		req.Header[k] = v
 	}
 
 	req.ContentLength = ln
 
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
+	limiter := contextClient(ctx).limiter()
+	if err := limiter.Acquire(ctx); err != nil {
+		cancel()
 		return nil, err
 	}
+
+	resp, err := UploadHttpClient.Do(req)
+	if err != nil {
+		limiter.Release()
+		cancel()
+		if u.ctx.Err() == nil && u.failover(ep) {
+			// try again right away on the next endpoint
+			if body != nil {
+				if _, err := body.Seek(0, io.SeekStart); err != nil {
+					return nil, err
+				}
+			}
+			return u.awsReq(method, query, body, headers)
+		}
+		return nil, stallError(ctx, err)
+	}
+	resp.Body = &releaseBody{ReadCloser: resp.Body, l: limiter}
 	if resp.StatusCode >= 400 {
+		defer cancel()
 		defer resp.Body.Close()
 		body, _ := ioutil.ReadAll(resp.Body)
-		return nil, fmt.Errorf("request failed: %s\ndetails: %s", resp.Status, body)
+		return nil, newHttpError(resp, body, nil)
 	}
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
 	return resp, err
 }
@@ -0,0 +1,52 @@
+package rest
+
+import (
+	"context"
+	"time"
+)
+
+// abortTimeout is how long cleanup requests may take once an upload has
+// failed, as the upload context may already be cancelled
+const abortTimeout = 30 * time.Second
+
+// UploadAbort describes the cleanup performed after an upload failed or was
+// cancelled
+type UploadAbort struct {
+	Err         error  // error that stopped the upload
+	AwsUploadID string // aws multipart upload that was aborted, if any
+	AbortErr    error  // error returned when aborting the multipart upload
+	Resumable   bool   // the multipart upload was kept so it can be resumed, see OnState
+	TempFiles   int    // number of temporary files removed
+}
+
+// abortAws aborts the current aws multipart upload so its parts are not left
+// stored. It runs even if the upload context was cancelled. Uploads whose
+// state is tracked with OnState are kept, as the saved state refers to them
+// and the upload can be resumed.
+func (u *UploadInfo) abortAws() {
+	if u.awsuploadid == "" {
+		return
+	}
+	if u.OnState != nil {
+		u.abort.Resumable = true
+		return
+	}
+	ctx := u.ctx
+	actx, cancel := context.WithTimeout(context.WithoutCancel(ctx), abortTimeout)
+	defer cancel()
+	u.ctx = actx
+	defer func() { u.ctx = ctx }()
+
+	u.abort.AwsUploadID = u.awsuploadid
+	u.abort.AbortErr = u.awsAbort()
+}
+
+// notifyAbort calls OnAbort after a failed upload
+func (u *UploadInfo) notifyAbort(err error) {
+	if u.OnAbort == nil {
+		return
+	}
+	a := u.abort
+	a.Err = err
+	u.OnAbort(&a)
+}
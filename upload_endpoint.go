@@ -0,0 +1,190 @@
+package rest
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	// ProbeUploadEndpoints enables measuring the latency of the storage
+	// endpoints offered for an upload, when there are several, so the
+	// fastest one is used
+	ProbeUploadEndpoints = true
+
+	// UploadEndpointProbeTimeout is how long to wait for an endpoint to
+	// answer a probe before considering it unreachable
+	UploadEndpointProbeTimeout = 3 * time.Second
+)
+
+// uploadEndpoint is a storage endpoint aws uploads can be sent to
+type uploadEndpoint struct {
+	Region string
+	Name   string
+	Host   string
+}
+
+// parseEndpoint reads an endpoint description, as found in Bucket_Endpoint
+func parseEndpoint(v any) (uploadEndpoint, bool) {
+	m, ok := v.(map[string]any)
+	if !ok {
+		return uploadEndpoint{}, false
+	}
+	var ep uploadEndpoint
+	ep.Region, _ = m["Region"].(string)
+	ep.Name, _ = m["Name"].(string)
+	ep.Host, _ = m["Host"].(string)
+	return ep, ep.Region != "" && ep.Name != "" && ep.Host != ""
+}
+
+// parseEndpoints reads the endpoints offered for the upload: Bucket_Endpoint
+// first, then the other hosts of the same bucket listed in
+// Bucket_Endpoint.Hosts, then the endpoints listed in Bucket_Endpoints
+func (u *UploadInfo) parseEndpoints(req map[string]any) bool {
+	main, ok := parseEndpoint(req["Bucket_Endpoint"])
+	if !ok {
+		return false
+	}
+	u.endpoints = []uploadEndpoint{main}
+	seen := map[string]bool{main.Host: true}
+	add := func(ep uploadEndpoint) {
+		if !seen[ep.Host] {
+			seen[ep.Host] = true
+			u.endpoints = append(u.endpoints, ep)
+		}
+	}
+
+	if hosts, ok := req["Bucket_Endpoint"].(map[string]any)["Hosts"].([]any); ok {
+		for _, h := range hosts {
+			if h, ok := h.(string); ok && h != "" {
+				add(uploadEndpoint{Region: main.Region, Name: main.Name, Host: h})
+			}
+		}
+	}
+	if list, ok := req["Bucket_Endpoints"].([]any); ok {
+		for _, v := range list {
+			if ep, ok := parseEndpoint(v); ok {
+				add(ep)
+			}
+		}
+	}
+	u.endpoint = main
+	u.failed = nil
+	return true
+}
+
+// currentEndpoint returns the endpoint aws requests are sent to
+func (u *UploadInfo) currentEndpoint() uploadEndpoint {
+	u.endpointLk.Lock()
+	defer u.endpointLk.Unlock()
+	return u.endpoint
+}
+
+// useEndpoint selects the endpoint with the given host, if offered. It is
+// used when resuming an upload, which must continue on the same bucket.
+func (u *UploadInfo) useEndpoint(host string) {
+	u.endpointLk.Lock()
+	defer u.endpointLk.Unlock()
+	for _, ep := range u.endpoints {
+		if ep.Host == host {
+			u.endpoint = ep
+			return
+		}
+	}
+}
+
+// failover switches to the next endpoint after a failure of failed, and
+// returns false if there is no other endpoint to try. Once a multipart
+// upload is started, only hosts of the same bucket can be used.
+func (u *UploadInfo) failover(failed uploadEndpoint) bool {
+	u.endpointLk.Lock()
+	defer u.endpointLk.Unlock()
+
+	if u.failed == nil {
+		u.failed = make(map[string]bool)
+	}
+	u.failed[failed.Host] = true
+	if u.endpoint.Host != failed.Host {
+		// another request already switched endpoint
+		return true
+	}
+	for _, ep := range u.endpoints {
+		if u.failed[ep.Host] {
+			continue
+		}
+		if u.awsuploadid != "" && (ep.Region != failed.Region || ep.Name != failed.Name) {
+			continue
+		}
+		slog.WarnContext(u.ctx, fmt.Sprintf("[rest] upload %s: storage endpoint %s failed, switching to %s", u.shortID(), failed.Host, ep.Host), "event", "rest:upload_failover", "rest:upload_id", u.ID)
+		u.endpoint = ep
+		u.updateResult(func(r *UploadResult) { r.Endpoint = ep.Host })
+		return true
+	}
+	return false
+}
+
+// selectEndpoint probes the offered endpoints and orders them by latency, so
+// the fastest is used first and the others are used for failover
+func (u *UploadInfo) selectEndpoint() {
+	if !ProbeUploadEndpoints || len(u.endpoints) < 2 {
+		return
+	}
+	latency := make([]time.Duration, len(u.endpoints))
+	var wg sync.WaitGroup
+	for i, ep := range u.endpoints {
+		wg.Add(1)
+		go func(i int, host string) {
+			defer wg.Done()
+			latency[i] = probeEndpoint(u.ctx, host)
+		}(i, ep.Host)
+	}
+	wg.Wait()
+
+	idx := make([]int, len(u.endpoints))
+	for i := range idx {
+		idx[i] = i
+	}
+	// unreachable endpoints have a negative latency and go last
+	sort.SliceStable(idx, func(a, b int) bool {
+		la, lb := latency[idx[a]], latency[idx[b]]
+		if la < 0 || lb < 0 {
+			return lb < 0 && la >= 0
+		}
+		return la < lb
+	})
+	sorted := make([]uploadEndpoint, len(idx))
+	for i, n := range idx {
+		sorted[i] = u.endpoints[n]
+	}
+
+	u.endpointLk.Lock()
+	u.endpoints = sorted
+	u.endpoint = sorted[0]
+	u.endpointLk.Unlock()
+	if debugEnabled(u.ctx) {
+		slog.DebugContext(u.ctx, fmt.Sprintf("[rest] upload %s: using storage endpoint %s", u.shortID(), sorted[0].Host), "event", "rest:upload_endpoint", "rest:upload_id", u.ID)
+	}
+}
+
+// probeEndpoint returns the time it takes for host to answer a request, or
+// -1 if it could not be reached. Any HTTP response counts as an answer.
+var probeEndpoint = func(ctx context.Context, host string) time.Duration {
+	ctx, cancel := context.WithTimeout(ctx, UploadEndpointProbeTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, "https://"+host+"/", nil)
+	if err != nil {
+		return -1
+	}
+	start := now()
+	resp, err := UploadHttpClient.Do(req)
+	if err != nil {
+		return -1
+	}
+	resp.Body.Close()
+	return now().Sub(start)
+}
@@ -0,0 +1,114 @@
+package rest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/KarpelesLab/pjson"
+)
+
+// UploadJournalEntry describes a completed upload, for auditing
+type UploadJournalEntry struct {
+	Time     time.Time         `json:"time"`
+	ID       string            `json:"id"`
+	Path     string            `json:"path,omitempty"` // see WithUploadPath
+	Size     int64             `json:"size"`
+	Hashes   map[string]string `json:"hashes,omitempty"` // hex encoded checksums by algorithm, empty if not all data was read
+	Blob     string            `json:"blob,omitempty"`
+	Error    string            `json:"error,omitempty"` // integrity check failure, if any
+	Response pjson.RawMessage  `json:"response,omitempty"`
+}
+
+// UploadJournal records completed uploads
+type UploadJournal interface {
+	Record(ctx context.Context, entry *UploadJournalEntry) error
+}
+
+// WithUploadJournal returns a context in which completed uploads are
+// recorded in j. Failing to record an upload makes it return an error.
+func WithUploadJournal(ctx context.Context, j UploadJournal) context.Context {
+	return context.WithValue(ctx, Journal, j)
+}
+
+// WithUploadPath returns a context in which uploads are recorded in journals
+// with the given file path
+func WithUploadPath(ctx context.Context, path string) context.Context {
+	return context.WithValue(ctx, UploadPath, path)
+}
+
+// JSONJournal is an UploadJournal writing entries as json, one per line
+type JSONJournal struct {
+	w  io.Writer
+	lk sync.Mutex
+}
+
+// NewJSONJournal returns a journal writing to w, which can be an
+// *os.File opened with os.O_APPEND
+func NewJSONJournal(w io.Writer) *JSONJournal {
+	return &JSONJournal{w: w}
+}
+
+func (j *JSONJournal) Record(ctx context.Context, entry *UploadJournalEntry) error {
+	buf, err := pjson.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	j.lk.Lock()
+	defer j.lk.Unlock()
+	_, err = j.w.Write(append(buf, '\n'))
+	return err
+}
+
+// ReadJournal reads the entries written by a JSONJournal
+func ReadJournal(r io.Reader) ([]*UploadJournalEntry, error) {
+	var res []*UploadJournalEntry
+	dec := pjson.NewDecoder(r)
+	for {
+		entry := &UploadJournalEntry{}
+		if err := dec.Decode(entry); err != nil {
+			if err == io.EOF {
+				return res, nil
+			}
+			return res, err
+		}
+		res = append(res, entry)
+	}
+}
+
+// record adds the completed upload to the journal of the context, if any.
+// verifyErr is the result of the integrity checks.
+func (u *UploadInfo) record(res *Response, verifyErr error) error {
+	j, ok := u.ctx.Value(Journal).(UploadJournal)
+	if !ok || j == nil {
+		return nil
+	}
+	entry := &UploadJournalEntry{
+		Time:     now(),
+		ID:       u.ID,
+		Size:     u.size,
+		Response: res.Data,
+	}
+	entry.Path, _ = u.ctx.Value(UploadPath).(string)
+	if u.hash != nil {
+		if entry.Size < 0 && !u.hash.gap {
+			entry.Size = u.hash.hashed
+		}
+		if u.hash.complete(u.size) {
+			entry.Hashes = make(map[string]string)
+			for algo := range u.hash.hashes {
+				entry.Hashes[algo] = u.hash.checksum(algo)
+			}
+		}
+	}
+	entry.Blob, _ = res.GetString("Blob__")
+	if verifyErr != nil {
+		entry.Error = verifyErr.Error()
+	}
+	if err := j.Record(u.ctx, entry); err != nil {
+		return fmt.Errorf("failed to record upload %s in journal: %w", u.shortID(), err)
+	}
+	return nil
+}
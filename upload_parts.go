@@ -0,0 +1,173 @@
+package rest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// uploadPart is a part of an upload, read from the source in a temporary
+// file
+type uploadPart struct {
+	no   int // part number, starting at 1
+	file *os.File
+	size int64
+}
+
+// reader returns a reader over the part data. The http client closes request
+// bodies implementing io.Closer, which would close the reused file.
+func (p *uploadPart) reader() io.ReadSeeker {
+	return io.NewSectionReader(p.file, 0, p.size)
+}
+
+// uploadParts splits f in parts of partSize bytes and calls upload for each
+// of them, with up to u.ParallelUploads calls at a time. While parts are
+// uploading, as many following parts are read ahead so network transfers
+// are not waiting on reads. Temporary files holding parts are reused. Parts
+// marked as done (when resuming) are skipped. If emptyFirst is true, part 1
+// is uploaded even if f is empty.
+func (u *UploadInfo) uploadParts(f io.Reader, partSize int64, emptyFirst bool, upload func(p *uploadPart) error) error {
+	workers := max(u.ParallelUploads, 1)
+	u.partSize = partSize
+	maxFiles := 2*workers + 1 // uploading, read ahead, and being read
+
+	ctx, cancel := context.WithCancel(u.ctx)
+	defer cancel()
+
+	var errOnce sync.Once
+	var uploadErr error
+	fail := func(err error) {
+		errOnce.Do(func() {
+			uploadErr = err
+			cancel()
+		})
+	}
+
+	var files []*os.File
+	free := make(chan *os.File, maxFiles)
+	defer func() {
+		// workers are done at this point, remove files even on failure
+		for _, tmpf := range files {
+			tmpf.Close()
+			os.Remove(tmpf.Name())
+		}
+		u.abort.TempFiles += len(files)
+	}()
+
+	parts := make(chan *uploadPart, workers)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() {
+				if r := recover(); r != nil {
+					fail(newPanicError(r))
+					// keep draining so the reader doesn't block
+					for p := range parts {
+						free <- p.file
+					}
+				}
+			}()
+			for p := range parts {
+				if ctx.Err() == nil {
+					if err := upload(p); err != nil {
+						fail(fmt.Errorf("%s failed: %w", u.partLabel(p.no), err))
+					}
+				}
+				free <- p.file
+			}
+		}()
+	}
+
+	readErr := u.readParts(ctx, f, partSize, emptyFirst, parts, func() (*os.File, error) {
+		// reuse a temp file if one is available
+		select {
+		case tmpf := <-free:
+			return tmpf, nil
+		default:
+		}
+		if len(files) < maxFiles {
+			// we use temp files as to avoid using too much memory
+			tmpf, err := os.CreateTemp("", "upload*.bin")
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, tmpf)
+			return tmpf, nil
+		}
+		select {
+		case tmpf := <-free:
+			return tmpf, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	})
+	close(parts)
+	wg.Wait()
+
+	if uploadErr != nil {
+		return uploadErr
+	}
+	return readErr
+}
+
+// readParts reads parts from f into files returned by getFile, and sends
+// them to parts until f is fully read
+func (u *UploadInfo) readParts(ctx context.Context, f io.Reader, partSize int64, emptyFirst bool, parts chan<- *uploadPart, getFile func() (*os.File, error)) error {
+	for partNo := 1; ; partNo++ {
+		if u.isDone(partNo) {
+			// already uploaded
+			n, err := skipBytes(f, partSize)
+			if err != nil && err != io.EOF {
+				return err
+			}
+			u.reportProgress(partNo, n)
+			if err == io.EOF || n == 0 {
+				return nil
+			}
+			continue
+		}
+
+		tmpf, err := getFile()
+		if err != nil {
+			return err
+		}
+		if _, err := tmpf.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		if err := tmpf.Truncate(0); err != nil {
+			return err
+		}
+
+		n, err := io.CopyN(tmpf, f, partSize)
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if n == 0 && (partNo != 1 || !emptyFirst) {
+			// no data to upload
+			return nil
+		}
+
+		select {
+		case parts <- &uploadPart{no: partNo, file: tmpf, size: n}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if err == io.EOF {
+			return nil
+		}
+	}
+}
+
+// partLabel describes a part in logs and errors, such as "part 37/210 of
+// upload 9f2c1a3b"
+func (u *UploadInfo) partLabel(no int) string {
+	if u.size >= 0 && u.partSize > 0 {
+		total := max((u.size+u.partSize-1)/u.partSize, 1)
+		return fmt.Sprintf("part %d/%d of upload %s", no, total, u.shortID())
+	}
+	return fmt.Sprintf("part %d of upload %s", no, u.shortID())
+}
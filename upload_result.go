@@ -0,0 +1,50 @@
+package rest
+
+import "time"
+
+// UploadMethod is the way a file was sent to storage
+type UploadMethod string
+
+const (
+	UploadMethodPut  UploadMethod = "put"  // single PUT request
+	UploadMethodPart UploadMethod = "part" // ranged PUT requests
+	UploadMethodAWS  UploadMethod = "aws"  // S3 multipart upload
+)
+
+// UploadResult holds statistics about a completed upload
+type UploadResult struct {
+	Method      UploadMethod
+	Bytes       int64 // bytes sent, including parts sent by a previous run when resuming
+	Parts       int   // parts uploaded by this run
+	Retries     int   // failed requests that were retried
+	Duration    time.Duration
+	Endpoint    string // storage host of aws uploads, the last one used if failing over
+	ContentType string // content type sent to storage, detected from the data if not given
+
+	ChecksumAlgorithm string // algorithm of Checksum, selected by the server or sha256
+	Checksum          string // hex encoded checksum of the uploaded data, empty if not all data was read
+}
+
+// Throughput returns the average upload speed in bytes per second
+func (r *UploadResult) Throughput() float64 {
+	if r.Duration <= 0 {
+		return 0
+	}
+	return float64(r.Bytes) / r.Duration.Seconds()
+}
+
+// Result returns statistics about the last upload performed with Do
+func (u *UploadInfo) Result() *UploadResult {
+	u.resultLk.Lock()
+	defer u.resultLk.Unlock()
+	res := u.result
+	return &res
+}
+
+// updateResult calls f with the result of the current upload, with the lock
+// held
+func (u *UploadInfo) updateResult(f func(r *UploadResult)) {
+	u.resultLk.Lock()
+	defer u.resultLk.Unlock()
+	f(&u.result)
+}
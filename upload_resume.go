@@ -0,0 +1,108 @@
+package rest
+
+import (
+	"io"
+)
+
+// UploadState holds the information needed to resume an interrupted upload.
+// It can be serialized as json.
+type UploadState struct {
+	Info        map[string]any `json:"info"`      // response to the initial upload query
+	PartSize    int64          `json:"part_size"` // MaxPartSize at the time of upload
+	AwsUploadID string         `json:"aws_upload_id,omitempty"`
+	AwsEndpoint string         `json:"aws_endpoint,omitempty"` // storage host the aws upload was started on
+	Parts       map[int]string `json:"parts,omitempty"`        // completed parts, with their etag for aws uploads
+}
+
+// ResumeUpload returns an UploadInfo that will continue the upload described
+// by state. The same file must be passed to Do, from its beginning. Only
+// multipart uploads can be resumed, others will restart from the start.
+func ResumeUpload(state *UploadState) (*UploadInfo, error) {
+	up, err := PrepareUpload(state.Info)
+	if err != nil {
+		return nil, err
+	}
+	if state.PartSize > 0 {
+		up.MaxPartSize = state.PartSize
+	}
+	up.awsuploadid = state.AwsUploadID
+	if state.AwsEndpoint != "" {
+		up.useEndpoint(state.AwsEndpoint)
+	}
+	up.done = make(map[int]string)
+	for partNo, tag := range state.Parts {
+		up.done[partNo] = tag
+		if tag != "" {
+			up.setTag(partNo, tag)
+		}
+	}
+	return up, nil
+}
+
+// State returns the current state of the upload, which can be passed to
+// ResumeUpload to continue it later
+func (u *UploadInfo) State() *UploadState {
+	u.doneLk.Lock()
+	defer u.doneLk.Unlock()
+
+	st := &UploadState{
+		Info:        u.info,
+		PartSize:    u.MaxPartSize,
+		AwsUploadID: u.awsuploadid,
+		Parts:       make(map[int]string),
+	}
+	for partNo, tag := range u.done {
+		st.Parts[partNo] = tag
+	}
+	if u.awsuploadid != "" {
+		st.AwsEndpoint = u.currentEndpoint().Host
+	}
+	return st
+}
+
+func (u *UploadInfo) saveState() {
+	if u.OnState != nil {
+		u.OnState(u.State())
+	}
+}
+
+func (u *UploadInfo) isDone(partNo int) bool {
+	u.doneLk.Lock()
+	defer u.doneLk.Unlock()
+
+	_, ok := u.done[partNo]
+	return ok
+}
+
+// partDone records partNo as completed
+func (u *UploadInfo) partDone(partNo int, tag string) {
+	u.doneLk.Lock()
+	if u.done == nil {
+		u.done = make(map[int]string)
+	}
+	u.done[partNo] = tag
+	u.doneLk.Unlock()
+
+	u.saveState()
+}
+
+// skipBytes skips up to n bytes from f, seeking if possible
+func skipBytes(f io.Reader, n int64) (int64, error) {
+	if s, ok := f.(io.Seeker); ok {
+		cur, err := s.Seek(0, io.SeekCurrent)
+		if err == nil {
+			end, err := s.Seek(0, io.SeekEnd)
+			if err == nil {
+				target := min(cur+n, end)
+				if _, err := s.Seek(target, io.SeekStart); err != nil {
+					return 0, err
+				}
+				if target-cur < n {
+					return target - cur, io.EOF
+				}
+				return n, nil
+			}
+		}
+	}
+	return io.CopyN(io.Discard, f, n)
+}
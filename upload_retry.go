@@ -0,0 +1,112 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+)
+
+var ErrUploadStalled = errors.New("upload stalled: no data sent within stall timeout")
+
+// DefaultUploadRetryDelay is the delay before the first retry of a failed
+// upload request when UploadInfo.RetryDelay is not set
+const DefaultUploadRetryDelay = time.Second
+
+// retry runs f until it succeeds or u.Retries retries have been performed.
+// attempt is zero for the first call. part is the part being uploaded, or
+// zero if the whole file is sent at once.
+func (u *UploadInfo) retry(part int, f func(attempt int) error) error {
+	delay := u.RetryDelay
+	if delay <= 0 {
+		delay = DefaultUploadRetryDelay
+	}
+
+	for attempt := 0; ; attempt++ {
+		if attempt == 0 {
+			Retries.request()
+		}
+		err := f(attempt)
+		if err == nil || attempt >= u.Retries || u.ctx.Err() != nil {
+			return err
+		}
+		var httpErr *HttpError
+		if errors.As(err, &httpErr) && !httpErr.IsTemporary() {
+			// the server rejected the request, retrying will not help
+			return err
+		}
+		if !Retries.allow() {
+			return fmt.Errorf("%w: %w", ErrRetryBudgetExhausted, err)
+		}
+		u.updateResult(func(r *UploadResult) { r.Retries += 1 })
+		if debugEnabled(u.ctx) {
+			what := "upload " + u.shortID()
+			if part > 0 {
+				what = u.partLabel(part)
+			}
+			slog.DebugContext(u.ctx, fmt.Sprintf("[rest] %s failed, retrying in %s: %s", what, delay, Redact(err.Error())), "event", "rest:upload_retry", "rest:upload_id", u.ID, "rest:part_id", u.partID(part))
+		}
+
+		if sleep(u.ctx, retryDelay(err, delay)) != nil {
+			return err
+		}
+		delay *= 2
+	}
+}
+
+// withStallTimeout returns a context that is cancelled if no data is read
+// from the returned reader for d. The returned function must be called to
+// release resources once the request is done.
+func withStallTimeout(ctx context.Context, r io.Reader, d time.Duration) (context.Context, io.Reader, func()) {
+	if d <= 0 {
+		return ctx, r, func() {}
+	}
+	ctx, cancel := context.WithCancelCause(ctx)
+	t := DefaultClock.AfterFunc(d, func() { cancel(ErrUploadStalled) })
+
+	return ctx, &stallReader{r: r, t: t, d: d}, func() {
+		t.Stop()
+		cancel(nil)
+	}
+}
+
+// stallError returns ErrUploadStalled if the failure of a request is caused
+// by the stall timeout
+func stallError(ctx context.Context, err error) error {
+	if errors.Is(context.Cause(ctx), ErrUploadStalled) {
+		return ErrUploadStalled
+	}
+	return err
+}
+
+// stallReader resets its timer each time data is read
+type stallReader struct {
+	r io.Reader
+	t Timer
+	d time.Duration
+}
+
+func (s *stallReader) Read(b []byte) (int, error) {
+	n, err := s.r.Read(b)
+	if err != nil {
+		// body fully sent, waiting for the response isn't a stall
+		s.t.Stop()
+	} else if n > 0 {
+		s.t.Reset(s.d)
+	}
+	return n, err
+}
+
+// cancelBody calls cancel when closed
+type cancelBody struct {
+	io.ReadCloser
+	cancel func()
+}
+
+func (c *cancelBody) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
@@ -0,0 +1,68 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+)
+
+// sniffLen is the number of bytes used to detect the content type of
+// uploads, see http.DetectContentType
+const sniffLen = 512
+
+// detectContentType returns the content type of the data in f, which is
+// read from its current position, and a reader returning the same data as f.
+// If the data does not allow a specific type to be detected, the extension
+// of the file name is used.
+func detectContentType(f io.Reader, ln int64, name string) (string, io.Reader, error) {
+	head := make([]byte, sniffLen)
+	if ln >= 0 && ln < sniffLen {
+		head = head[:ln]
+	}
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", f, err
+	}
+	head = head[:n]
+
+	if s, ok := f.(io.Seeker); ok {
+		if _, err := s.Seek(-int64(n), io.SeekCurrent); err != nil {
+			return "", f, err
+		}
+	} else {
+		f = io.MultiReader(bytes.NewReader(head), f)
+	}
+
+	typ := "application/octet-stream"
+	if n > 0 {
+		typ = http.DetectContentType(head)
+	}
+	if typ == "application/octet-stream" || typ == "text/plain; charset=utf-8" {
+		// generic type, the extension may tell more
+		if ext := path.Ext(name); ext != "" {
+			if t := mime.TypeByExtension(ext); t != "" {
+				typ = t
+			}
+		}
+	}
+	return typ, f, nil
+}
+
+// uploadName returns the name of the file being uploaded, if known
+func (u *UploadInfo) uploadName(ctx context.Context) string {
+	if p, ok := ctx.Value(UploadPath).(string); ok && p != "" {
+		return p
+	}
+	if u.name != "" {
+		return u.name
+	}
+	for _, k := range []string{"filename", "Filename", "Name"} {
+		if v, ok := u.info[k].(string); ok && v != "" {
+			return v
+		}
+	}
+	return ""
+}
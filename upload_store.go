@@ -0,0 +1,191 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/KarpelesLab/pjson"
+)
+
+// UploadStore persists the bookkeeping of uploads tracked with StoredUpload:
+// the state of uploads in progress so they can be resumed, and the result of
+// completed ones so identical content is not uploaded again.
+type UploadStore interface {
+	Get(key string) (*UploadRecord, error) // returns nil if key is unknown
+	Put(rec *UploadRecord) error
+	Delete(key string) error
+}
+
+// UploadRecord is what an UploadStore keeps for a given key
+type UploadRecord struct {
+	Key     string           `json:"key"`
+	State   *UploadState     `json:"state,omitempty"`  // state of the upload, while in progress
+	Result  pjson.RawMessage `json:"result,omitempty"` // data returned on completion
+	Done    bool             `json:"done,omitempty"`
+	Updated time.Time        `json:"updated"`
+}
+
+// StoredUpload uploads f, keeping track of it in store under key, which
+// should identify the content (for example from its path, size and
+// modification time, or its hash). If an upload with the same key completed
+// before, its result is returned without uploading anything. Else prepare is
+// called to get the UploadInfo to use, with the state of the interrupted
+// upload to resume if there is one (see ResumeUpload), or nil. When resuming,
+// f must be positioned at its start.
+func StoredUpload(ctx context.Context, store UploadStore, key string, prepare func(ctx context.Context, state *UploadState) (*UploadInfo, error), f io.Reader, mimeType string, ln int64) (*Response, error) {
+	rec, err := store.Get(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load upload record: %w", err)
+	}
+	if rec != nil && rec.Done {
+		return &Response{Result: "success", Data: rec.Result}, nil
+	}
+
+	var state *UploadState
+	if rec != nil {
+		state = rec.State
+	}
+	up, err := prepare(ctx, state)
+	if err != nil {
+		return nil, err
+	}
+
+	var lk sync.Mutex
+	var saveErr error
+	up.OnState = func(st *UploadState) {
+		lk.Lock()
+		defer lk.Unlock()
+		if err := store.Put(&UploadRecord{Key: key, State: st, Updated: now()}); err != nil && saveErr == nil {
+			saveErr = err
+		}
+	}
+	up.OnState(up.State())
+	if saveErr != nil {
+		return nil, fmt.Errorf("failed to save upload record: %w", saveErr)
+	}
+
+	res, err := up.Do(ctx, f, mimeType, ln)
+	if err != nil {
+		return nil, err
+	}
+	if err := store.Put(&UploadRecord{Key: key, Result: res.Data, Done: true, Updated: now()}); err != nil {
+		return res, fmt.Errorf("failed to save upload record: %w", err)
+	}
+	return res, nil
+}
+
+// MemoryUploadStore is an UploadStore keeping records in memory
+type MemoryUploadStore struct {
+	lk      sync.Mutex
+	records map[string]*UploadRecord
+}
+
+func (s *MemoryUploadStore) Get(key string) (*UploadRecord, error) {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	return s.records[key], nil
+}
+
+func (s *MemoryUploadStore) Put(rec *UploadRecord) error {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	if s.records == nil {
+		s.records = make(map[string]*UploadRecord)
+	}
+	s.records[rec.Key] = rec
+	return nil
+}
+
+func (s *MemoryUploadStore) Delete(key string) error {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	delete(s.records, key)
+	return nil
+}
+
+// FileUploadStore is an UploadStore keeping each record as a json file in a
+// directory. Keys are used as file names, and should be made of characters
+// valid in file names, such as hex encoded hashes.
+type FileUploadStore struct {
+	Dir string
+	lk  sync.Mutex
+}
+
+// NewFileUploadStore returns a store using the given directory, creating it
+// if needed
+func NewFileUploadStore(dir string) (*FileUploadStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &FileUploadStore{Dir: dir}, nil
+}
+
+func (s *FileUploadStore) path(key string) (string, error) {
+	if key == "" || key != filepath.Base(key) || key[0] == '.' {
+		return "", fmt.Errorf("invalid upload store key %q", key)
+	}
+	return filepath.Join(s.Dir, key+".json"), nil
+}
+
+func (s *FileUploadStore) Get(key string) (*UploadRecord, error) {
+	fn, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	buf, err := os.ReadFile(fn)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	rec := &UploadRecord{}
+	if err := pjson.Unmarshal(buf, rec); err != nil {
+		return nil, fmt.Errorf("failed to parse upload record %s: %w", key, err)
+	}
+	return rec, nil
+}
+
+func (s *FileUploadStore) Put(rec *UploadRecord) error {
+	fn, err := s.path(rec.Key)
+	if err != nil {
+		return err
+	}
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	buf, err := pjson.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	// write to temp file & rename so a crash doesn't leave partial records
+	tmp := fn + ".tmp"
+	if err := os.WriteFile(tmp, buf, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, fn)
+}
+
+func (s *FileUploadStore) Delete(key string) error {
+	fn, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	err = os.Remove(fn)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,89 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestStoredUpload(t *testing.T) {
+	var puts atomic.Int32
+	var failPart atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method == "PUT" {
+			io.Copy(io.Discard, req.Body)
+			if strings.HasPrefix(req.Header.Get("Content-Range"), "bytes 8-") && failPart.Load() > 0 {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			puts.Add(1)
+			return
+		}
+		w.Write([]byte(`{"result":"success","data":{"Blob__":"blob-1"}}`))
+	}))
+	defer srv.Close()
+
+	u, _ := url.Parse(srv.URL)
+	ctx := context.WithValue(context.Background(), BackendURL, u)
+
+	store, err := NewFileUploadStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create store: %s", err)
+	}
+	var resumed bool
+	prepare := func(ctx context.Context, state *UploadState) (*UploadInfo, error) {
+		if state != nil {
+			resumed = true
+			up, err := ResumeUpload(state)
+			if err == nil {
+				up.ParallelUploads = 1
+			}
+			return up, err
+		}
+		up, err := PrepareUpload(map[string]any{"PUT": srv.URL + "/put", "Complete": "Upload:complete", "Blocksize": float64(4)})
+		if err == nil {
+			up.ParallelUploads = 1
+		}
+		return up, err
+	}
+	data := strings.Repeat("x", 16)
+
+	// first attempt fails on the third part
+	failPart.Store(1)
+	if _, err := StoredUpload(ctx, store, "file1", prepare, strings.NewReader(data), "text/plain", 16); err == nil {
+		t.Fatalf("expected upload to fail")
+	}
+	rec, err := store.Get("file1")
+	if err != nil || rec == nil || rec.Done || len(rec.State.Parts) != 2 {
+		t.Fatalf("unexpected record after failure: %+v, %v", rec, err)
+	}
+
+	// second attempt resumes
+	failPart.Store(0)
+	puts.Store(0)
+	res, err := StoredUpload(ctx, store, "file1", prepare, strings.NewReader(data), "text/plain", 16)
+	if err != nil {
+		t.Fatalf("resumed upload failed: %s", err)
+	}
+	if !resumed || puts.Load() != 2 {
+		t.Errorf("expected upload to be resumed with 2 parts, got resumed=%v puts=%d", resumed, puts.Load())
+	}
+
+	// third attempt is deduplicated
+	puts.Store(0)
+	res, err = StoredUpload(ctx, store, "file1", func(ctx context.Context, state *UploadState) (*UploadInfo, error) {
+		return nil, errors.New("should not be called")
+	}, strings.NewReader(data), "text/plain", 16)
+	if err != nil || puts.Load() != 0 {
+		t.Fatalf("expected stored result, got %v with %d puts", err, puts.Load())
+	}
+	if blob, _ := res.GetString("Blob__"); blob != "blob-1" {
+		t.Errorf("unexpected stored result %s", res.Data)
+	}
+}
@@ -4,10 +4,23 @@ import (
 	"context"
 	"crypto/rand"
 	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
 	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/crc32"
 	"io"
 	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 type emptyReader struct{}
@@ -51,3 +64,577 @@ func TestUploadEmpty(t *testing.T) {
 
 	log.Printf("res = %s", res.Data)
 }
+
+func TestUploadResume(t *testing.T) {
+	var lk sync.Mutex
+	var ranges []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method == "PUT" {
+			lk.Lock()
+			ranges = append(ranges, req.Header.Get("Content-Range"))
+			lk.Unlock()
+			io.Copy(io.Discard, req.Body)
+			return
+		}
+		w.Write([]byte(`{"result":"success","data":{"Blob__":"blob-test"}}`))
+	}))
+	defer srv.Close()
+
+	u, _ := url.Parse(srv.URL)
+	ctx := context.WithValue(context.Background(), BackendURL, u)
+
+	state := &UploadState{
+		Info:  map[string]any{"PUT": srv.URL + "/put", "Complete": "Upload:complete", "Blocksize": float64(4)},
+		Parts: map[int]string{1: ""},
+	}
+	up, err := ResumeUpload(state)
+	if err != nil {
+		t.Fatalf("failed to resume upload: %s", err)
+	}
+
+	var lastSent int64
+	up.OnState = func(st *UploadState) {}
+	ctx = context.WithValue(ctx, UploadProgress, UploadProgressFunc(func(sent, total int64) { lastSent = sent }))
+
+	res, err := up.Do(ctx, strings.NewReader("0123456789"), "application/octet-stream", 10)
+	if err != nil {
+		t.Fatalf("failed to do upload: %s", err)
+	}
+	if blob, _ := res.GetString("Blob__"); blob != "blob-test" {
+		t.Errorf("unexpected upload response %s", res.Data)
+	}
+
+	sort.Strings(ranges)
+	if strings.Join(ranges, ",") != "bytes 4-7/*,bytes 8-9/*" {
+		t.Errorf("unexpected uploaded ranges %v", ranges)
+	}
+	if lastSent != 10 {
+		t.Errorf("unexpected final progress %d", lastSent)
+	}
+	if st := up.State(); len(st.Parts) != 3 {
+		t.Errorf("unexpected final state %+v", st)
+	}
+}
+
+func TestUploadRetry(t *testing.T) {
+	var lk sync.Mutex
+	attempts := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method == "PUT" {
+			io.Copy(io.Discard, req.Body)
+			lk.Lock()
+			defer lk.Unlock()
+			attempts += 1
+			if attempts == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+			return
+		}
+		w.Write([]byte(`{"result":"success","data":{}}`))
+	}))
+	defer srv.Close()
+
+	u, _ := url.Parse(srv.URL)
+	ctx := context.WithValue(context.Background(), BackendURL, u)
+
+	up, err := PrepareUpload(map[string]any{"PUT": srv.URL + "/put", "Complete": "Upload:complete"})
+	if err != nil {
+		t.Fatalf("failed to prepare upload: %s", err)
+	}
+
+	// without retries, the error is returned
+	_, err = up.Do(ctx, strings.NewReader("0123456789"), "application/octet-stream", 10)
+	var httpErr *HttpError
+	if !errors.As(err, &httpErr) || httpErr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 error, got %v", err)
+	}
+
+	attempts = 0
+	up.Retries = 1
+	up.RetryDelay = time.Millisecond
+	if _, err := up.Do(ctx, strings.NewReader("0123456789"), "application/octet-stream", 10); err != nil {
+		t.Fatalf("failed to do upload: %s", err)
+	}
+	if attempts != 2 {
+		t.Errorf("expected 2 attempts, got %d", attempts)
+	}
+	if r := up.Result(); r.Method != UploadMethodPut || r.Retries != 1 || r.Parts != 1 || r.Bytes != 10 {
+		t.Errorf("unexpected upload result %+v", r)
+	}
+}
+
+func TestUploadProgress(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method == "PUT" {
+			io.Copy(io.Discard, req.Body)
+			return
+		}
+		w.Write([]byte(`{"result":"success","data":{}}`))
+	}))
+	defer srv.Close()
+
+	u, _ := url.Parse(srv.URL)
+	ctx := context.WithValue(context.Background(), BackendURL, u)
+
+	var calls int
+	var lastSent int64
+	ctx = WithProgress(ctx, func(sent, total int64) {
+		calls += 1
+		lastSent = sent
+	})
+
+	up, err := PrepareUpload(map[string]any{"PUT": srv.URL + "/put", "Complete": "Upload:complete"})
+	if err != nil {
+		t.Fatalf("failed to prepare upload: %s", err)
+	}
+	data := strings.Repeat("x", 1024*1024)
+	if _, err := up.Do(ctx, strings.NewReader(data), "application/octet-stream", int64(len(data))); err != nil {
+		t.Fatalf("failed to do upload: %s", err)
+	}
+	if calls < 2 || lastSent != int64(len(data)) {
+		t.Errorf("expected incremental progress up to %d, got %d calls ending at %d", len(data), calls, lastSent)
+	}
+}
+
+func TestPartUploadParallel(t *testing.T) {
+	var lk sync.Mutex
+	var inFlight, maxInFlight int
+	received := make(map[string]string)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method == "PUT" {
+			lk.Lock()
+			inFlight += 1
+			maxInFlight = max(maxInFlight, inFlight)
+			lk.Unlock()
+			buf, _ := io.ReadAll(req.Body)
+			time.Sleep(5 * time.Millisecond)
+			lk.Lock()
+			inFlight -= 1
+			received[req.Header.Get("Content-Range")] = string(buf)
+			lk.Unlock()
+			return
+		}
+		w.Write([]byte(`{"result":"success","data":{}}`))
+	}))
+	defer srv.Close()
+
+	u, _ := url.Parse(srv.URL)
+	ctx := context.WithValue(context.Background(), BackendURL, u)
+
+	up, err := PrepareUpload(map[string]any{"PUT": srv.URL + "/put", "Complete": "Upload:complete", "Blocksize": float64(4)})
+	if err != nil {
+		t.Fatalf("failed to prepare upload: %s", err)
+	}
+	up.ParallelUploads = 2
+
+	data := "0123456789abcdefghijklmnopqrstuvwxyz" // 9 parts
+	if _, err := up.Do(ctx, &nonSeekReader{strings.NewReader(data)}, "application/octet-stream", int64(len(data))); err != nil {
+		t.Fatalf("failed to do upload: %s", err)
+	}
+	if len(received) != 9 || received["bytes 0-3/*"] != "0123" || received["bytes 32-35/*"] != "wxyz" {
+		t.Errorf("unexpected parts %v", received)
+	}
+	if maxInFlight > 2 {
+		t.Errorf("expected at most 2 parallel uploads, got %d", maxInFlight)
+	}
+	if r := up.Result(); r.Method != UploadMethodPart || r.Parts != 9 || r.Bytes != int64(len(data)) || r.Duration <= 0 {
+		t.Errorf("unexpected upload result %+v", r)
+	}
+}
+
+// nonSeekReader hides the io.Seeker implementation of a reader, so parts are
+// read sequentially
+type nonSeekReader struct {
+	r io.Reader
+}
+
+func (e *nonSeekReader) Read(b []byte) (int, error) {
+	return e.r.Read(b)
+}
+
+func TestUploadIntegrity(t *testing.T) {
+	data := strings.Repeat("abcd", 1000)
+	sum := sha256.Sum256([]byte(data))
+	var complete string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method == "PUT" {
+			io.Copy(io.Discard, req.Body)
+			return
+		}
+		w.Write([]byte(complete))
+	}))
+	defer srv.Close()
+
+	u, _ := url.Parse(srv.URL)
+	ctx := context.WithValue(context.Background(), BackendURL, u)
+
+	for _, blocksize := range []float64{0, 1024} {
+		info := map[string]any{"PUT": srv.URL + "/put", "Complete": "Upload:complete"}
+		if blocksize > 0 {
+			info["Blocksize"] = blocksize
+		}
+
+		complete = fmt.Sprintf(`{"result":"success","data":{"Size":%d,"SHA256":"%x"}}`, len(data), sum)
+		up, _ := PrepareUpload(info)
+		if _, err := up.Do(ctx, strings.NewReader(data), "application/octet-stream", int64(len(data))); err != nil {
+			t.Errorf("blocksize %v: unexpected error on matching upload: %s", blocksize, err)
+		}
+
+		complete = fmt.Sprintf(`{"result":"success","data":{"Size":%d,"SHA256":"%064x"}}`, len(data), 0)
+		up, _ = PrepareUpload(info)
+		_, err := up.Do(ctx, strings.NewReader(data), "application/octet-stream", int64(len(data)))
+		var ie *IntegrityError
+		if !errors.As(err, &ie) || ie.Field != "sha256" {
+			t.Errorf("blocksize %v: expected sha256 integrity error, got %v", blocksize, err)
+		}
+
+		complete = `{"result":"success","data":{"Size":12}}`
+		up, _ = PrepareUpload(info)
+		_, err = up.Do(ctx, strings.NewReader(data), "application/octet-stream", int64(len(data)))
+		if !errors.As(err, &ie) || ie.Field != "size" {
+			t.Errorf("blocksize %v: expected size integrity error, got %v", blocksize, err)
+		}
+	}
+}
+
+func TestUploadAbort(t *testing.T) {
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method == "PUT" {
+			io.Copy(io.Discard, req.Body)
+			if strings.HasPrefix(req.Header.Get("Content-Range"), "bytes 8-") {
+				cancel()
+			}
+			return
+		}
+		w.Write([]byte(`{"result":"success","data":{}}`))
+	}))
+	defer srv.Close()
+
+	u, _ := url.Parse(srv.URL)
+	ctx = context.WithValue(ctx, BackendURL, u)
+
+	up, err := PrepareUpload(map[string]any{"PUT": srv.URL + "/put", "Complete": "Upload:complete", "Blocksize": float64(4)})
+	if err != nil {
+		t.Fatalf("failed to prepare upload: %s", err)
+	}
+	var abort *UploadAbort
+	up.OnAbort = func(a *UploadAbort) { abort = a }
+
+	data := strings.Repeat("x", 64)
+	_, err = up.Do(ctx, &nonSeekReader{strings.NewReader(data)}, "application/octet-stream", int64(len(data)))
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected cancellation error, got %v", err)
+	}
+	if abort == nil || !errors.Is(abort.Err, context.Canceled) || abort.TempFiles == 0 {
+		t.Fatalf("expected abort report with removed temp files, got %+v", abort)
+	}
+	if left, _ := os.ReadDir(tmp); len(left) != 0 {
+		t.Errorf("expected temp files to be removed, found %d", len(left))
+	}
+}
+
+func TestUploadProgressListeners(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method == "PUT" {
+			io.Copy(io.Discard, req.Body)
+			return
+		}
+		w.Write([]byte(`{"result":"success","data":{}}`))
+	}))
+	defer srv.Close()
+
+	u, _ := url.Parse(srv.URL)
+	ctx := context.WithValue(context.Background(), BackendURL, u)
+
+	var appSent int64
+	ctx = WithProgress(ctx, func(sent, total int64) { appSent = sent })
+	ids := make(map[string]int64)
+	ctx = WithProgressListener(ctx, func(ev *UploadProgressEvent) { ids[ev.ID] = ev.Sent })
+
+	data := strings.Repeat("x", 1024)
+	for _, id := range []string{"first", ""} {
+		up, _ := PrepareUpload(map[string]any{"PUT": srv.URL + "/put", "Complete": "Upload:complete"})
+		up.ID = id
+		if _, err := up.Do(ctx, strings.NewReader(data), "application/octet-stream", int64(len(data))); err != nil {
+			t.Fatalf("failed to do upload: %s", err)
+		}
+	}
+	if appSent != int64(len(data)) {
+		t.Errorf("expected application callback to still be called, got %d", appSent)
+	}
+	if len(ids) != 2 || ids["first"] != int64(len(data)) {
+		t.Errorf("expected progress of 2 distinct uploads, got %v", ids)
+	}
+}
+
+func TestUploadAs(t *testing.T) {
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/_special/rest/Blob:upload":
+			w.Write([]byte(`{"result":"success","data":{"PUT":"` + srv.URL + `/put","Complete":"Blob:complete"}}`))
+		case "/put":
+			io.Copy(io.Discard, req.Body)
+		default:
+			w.Write([]byte(`{"result":"success","data":{"Blob__":"blob-1","Size":5}}`))
+		}
+	}))
+	defer srv.Close()
+
+	u, _ := url.Parse(srv.URL)
+	ctx := context.WithValue(context.Background(), BackendURL, u)
+
+	type blob struct {
+		Blob__ string
+		Size   int64
+	}
+	res, err := UploadAs[blob](ctx, "Blob:upload", "POST", Param{}, strings.NewReader("hello"), "text/plain")
+	if err != nil {
+		t.Fatalf("UploadAs failed: %s", err)
+	}
+	if res.Blob__ != "blob-1" || res.Size != 5 {
+		t.Errorf("unexpected result %+v", res)
+	}
+}
+
+func TestUploadChecksumNegotiation(t *testing.T) {
+	data := "hello world"
+	crc := crc32.Checksum([]byte(data), crc32.MakeTable(crc32.Castagnoli))
+	crcB64 := base64.StdEncoding.EncodeToString(binary.BigEndian.AppendUint32(nil, crc))
+
+	var advertised, remote string
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/_special/rest/Blob:upload":
+			advertised = req.Header.Get("Sec-Rest-Checksum")
+			w.Write([]byte(`{"result":"success","data":{"PUT":"` + srv.URL + `/put","Complete":"Blob:complete","Checksum":"crc32c"}}`))
+		case "/put":
+			io.Copy(io.Discard, req.Body)
+		default:
+			w.Write([]byte(`{"result":"success","data":{"CRC32C":"` + remote + `"}}`))
+		}
+	}))
+	defer srv.Close()
+
+	u, _ := url.Parse(srv.URL)
+	ctx := context.WithValue(context.Background(), BackendURL, u)
+
+	remote = crcB64
+	if _, err := Upload(ctx, "Blob:upload", "POST", Param{}, strings.NewReader(data), "text/plain"); err != nil {
+		t.Fatalf("upload failed: %s", err)
+	}
+	if !strings.Contains(advertised, "crc32c") || !strings.Contains(advertised, "sha256") {
+		t.Errorf("unexpected advertised checksums %q", advertised)
+	}
+
+	remote = "AAAAAA=="
+	_, err := Upload(ctx, "Blob:upload", "POST", Param{}, strings.NewReader(data), "text/plain")
+	var ie *IntegrityError
+	if !errors.As(err, &ie) || ie.Field != "crc32c" {
+		t.Errorf("expected crc32c integrity error, got %v", err)
+	}
+
+	up, _ := PrepareUpload(map[string]any{"PUT": srv.URL + "/put", "Complete": "Blob:complete", "Checksum": "crc32c"})
+	remote = crcB64
+	if _, err := up.Do(ctx, strings.NewReader(data), "text/plain", int64(len(data))); err != nil {
+		t.Fatalf("upload failed: %s", err)
+	}
+	if res := up.Result(); res.ChecksumAlgorithm != "crc32c" || res.Checksum != fmt.Sprintf("%08x", crc) {
+		t.Errorf("unexpected checksum in result %+v", res)
+	}
+}
+
+func TestUploadPartErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method == "PUT" {
+			io.Copy(io.Discard, req.Body)
+			if strings.HasPrefix(req.Header.Get("Content-Range"), "bytes 8-") {
+				w.WriteHeader(http.StatusBadRequest)
+			}
+			return
+		}
+		w.Write([]byte(`{"result":"success","data":{}}`))
+	}))
+	defer srv.Close()
+
+	u, _ := url.Parse(srv.URL)
+	ctx := context.WithValue(context.Background(), BackendURL, u)
+
+	up, _ := PrepareUpload(map[string]any{"PUT": srv.URL + "/put", "Complete": "Upload:complete", "Blocksize": float64(4)})
+	parts := make(map[int]bool)
+	ctx = WithProgressListener(ctx, func(ev *UploadProgressEvent) { parts[ev.Part] = true })
+	_, err := up.Do(ctx, strings.NewReader(strings.Repeat("x", 16)), "application/octet-stream", 16)
+	if err == nil || !strings.Contains(err.Error(), "part 3/4 of upload "+up.ID[:8]) {
+		t.Errorf("expected error identifying the part, got %v", err)
+	}
+	if len(up.ID) != 36 {
+		t.Errorf("expected a UUID upload id, got %s", up.ID)
+	}
+	if !parts[1] || parts[0] {
+		t.Errorf("expected progress events identifying parts, got %v", parts)
+	}
+}
+
+func TestUploadEndpointFailover(t *testing.T) {
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if strings.HasSuffix(req.URL.Path, ":signV4") {
+			w.Write([]byte(`{"result":"success","data":{"authorization":"AWS4-HMAC-SHA256 test"}}`))
+			return
+		}
+		w.Write([]byte(`{"result":"success","data":{}}`))
+	}))
+	defer api.Close()
+
+	var hits []string
+	var hitsLk sync.Mutex
+	storage := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		hitsLk.Lock()
+		hits = append(hits, req.Method+" "+req.URL.Path)
+		hitsLk.Unlock()
+		io.Copy(io.Discard, req.Body)
+		switch {
+		case req.URL.RawQuery == "uploads=":
+			w.Write([]byte(`<InitiateMultipartUploadResult><Bucket>b</Bucket><Key>k</Key><UploadId>up1</UploadId></InitiateMultipartUploadResult>`))
+		case req.Method == "PUT":
+			w.Header().Set("Etag", `"etag"`)
+		}
+	}))
+	defer storage.Close()
+	down := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {}))
+	down.Close()
+
+	client := UploadHttpClient
+	UploadHttpClient = storage.Client()
+	defer func() { UploadHttpClient = client }()
+	probe := probeEndpoint
+	probeEndpoint = func(ctx context.Context, host string) time.Duration {
+		// pretend the unreachable endpoint is the fastest
+		if host == down.Listener.Addr().String() {
+			return time.Millisecond
+		}
+		return time.Second
+	}
+	defer func() { probeEndpoint = probe }()
+
+	host := func(srv *httptest.Server) string { return strings.TrimPrefix(srv.URL, "https://") }
+	up, err := PrepareUpload(map[string]any{
+		"PUT":                       "https://invalid/",
+		"Complete":                  "Upload:complete",
+		"Cloud_Aws_Bucket_Upload__": "x",
+		"Key":                       "k",
+		"Bucket_Endpoint":           map[string]any{"Region": "r1", "Name": "b", "Host": host(storage)},
+		"Bucket_Endpoints":          []any{map[string]any{"Region": "r1", "Name": "b", "Host": host(down)}},
+	})
+	if err != nil {
+		t.Fatalf("prepare failed: %s", err)
+	}
+	up.SkipVerify = true
+
+	u, _ := url.Parse(api.URL)
+	ctx := context.WithValue(context.Background(), BackendURL, u)
+	if _, err := up.Do(ctx, io.MultiReader(strings.NewReader("hello")), "text/plain", -1); err != nil {
+		t.Fatalf("upload failed: %s", err)
+	}
+	if r := up.Result(); r.Endpoint != host(storage) {
+		t.Errorf("expected upload to fail over to %s, got %s", host(storage), r.Endpoint)
+	}
+	if len(hits) != 3 || hits[0] != "POST /b/k" {
+		t.Errorf("unexpected storage requests %v", hits)
+	}
+	if st := up.State(); st.AwsEndpoint != host(storage) {
+		t.Errorf("state does not record the endpoint: %+v", st)
+	}
+}
+
+func TestUploadJournal(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method == "PUT" {
+			io.Copy(io.Discard, req.Body)
+			return
+		}
+		w.Write([]byte(`{"result":"success","data":{"Blob__":"blob-1234","Size":5}}`))
+	}))
+	defer srv.Close()
+
+	u, _ := url.Parse(srv.URL)
+	var buf strings.Builder
+	ctx := context.WithValue(context.Background(), BackendURL, u)
+	ctx = WithUploadPath(WithUploadJournal(ctx, NewJSONJournal(&buf)), "dir/hello.txt")
+
+	up, _ := PrepareUpload(map[string]any{"PUT": srv.URL + "/put", "Complete": "Upload:complete"})
+	if _, err := up.Do(ctx, strings.NewReader("hello"), "text/plain", 5); err != nil {
+		t.Fatalf("upload failed: %s", err)
+	}
+
+	entries, err := ReadJournal(strings.NewReader(buf.String()))
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("failed to read journal %q: %v", buf.String(), err)
+	}
+	e := entries[0]
+	sum := sha256.Sum256([]byte("hello"))
+	if e.ID != up.ID || e.Path != "dir/hello.txt" || e.Size != 5 || e.Blob != "blob-1234" || e.Hashes["sha256"] != hex.EncodeToString(sum[:]) || e.Error != "" {
+		t.Errorf("unexpected journal entry %+v", e)
+	}
+	if !strings.Contains(string(e.Response), "blob-1234") {
+		t.Errorf("journal entry does not include the response: %s", e.Response)
+	}
+}
+
+func TestUploadContentType(t *testing.T) {
+	var got []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method == "PUT" {
+			buf, _ := io.ReadAll(req.Body)
+			got = append(got, req.Header.Get("Content-Type")+" "+strings.TrimSpace(string(buf[min(len(buf), 8):])))
+			return
+		}
+		w.Write([]byte(`{"result":"success","data":{}}`))
+	}))
+	defer srv.Close()
+
+	u, _ := url.Parse(srv.URL)
+	ctx := context.WithValue(context.Background(), BackendURL, u)
+
+	// detected from the data, seekable source
+	up, _ := PrepareUpload(map[string]any{"PUT": srv.URL + "/put", "Complete": "Upload:complete"})
+	data := "\x89PNG\r\n\x1a\nrest of the image"
+	if _, err := up.Do(ctx, strings.NewReader(data), "", int64(len(data))); err != nil {
+		t.Fatalf("upload failed: %s", err)
+	}
+	if r := up.Result(); r.ContentType != "image/png" {
+		t.Errorf("expected detected image/png, got %q", r.ContentType)
+	}
+
+	// detected from the file name, non seekable source
+	up, _ = PrepareUpload(map[string]any{"PUT": srv.URL + "/put", "Complete": "Upload:complete"})
+	data = `{"a":"some json"}`
+	if _, err := up.Do(WithUploadPath(ctx, "dir/file.json"), io.MultiReader(strings.NewReader(data)), "", int64(len(data))); err != nil {
+		t.Fatalf("upload failed: %s", err)
+	}
+
+	if len(got) != 2 || got[0] != "image/png rest of the image" || got[1] != `application/json me json"}` {
+		t.Errorf("unexpected uploads %q", got)
+	}
+}
+
+func TestUploadAbortResumable(t *testing.T) {
+	up, err := PrepareUpload(map[string]any{"PUT": "http://localhost/put", "Complete": "Upload:complete"})
+	if err != nil {
+		t.Fatalf("failed to prepare upload: %s", err)
+	}
+	up.ctx = context.Background()
+	up.awsuploadid = "upload-id"
+	up.OnState = func(st *UploadState) {}
+
+	// the saved state refers to the multipart upload, it must not be aborted
+	up.abortAws()
+	if up.abort.AwsUploadID != "" || !up.abort.Resumable {
+		t.Errorf("expected multipart upload to be kept, got %+v", up.abort)
+	}
+}
@@ -0,0 +1,201 @@
+package rest
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// IntegrityError is returned when the size or checksum of an upload reported
+// by the server on completion does not match the data that was sent
+type IntegrityError struct {
+	Field  string // "size", or the checksum algorithm such as "sha256"
+	Local  string
+	Remote string
+}
+
+func (e *IntegrityError) Error() string {
+	return fmt.Sprintf("[rest] upload integrity check failed: %s is %s locally but %s on server", e.Field, e.Local, e.Remote)
+}
+
+// checksumAlgorithms are the checksum algorithms uploads can compute. They
+// are advertised when requesting an upload, and the server may select one
+// with the Checksum parameter of its response. SHA-256 is always computed.
+var checksumAlgorithms = map[string]func() hash.Hash{
+	"sha256": func() hash.Hash { return Crypto.NewSHA256() },
+	"crc32c": func() hash.Hash { return crc32.New(crc32.MakeTable(crc32.Castagnoli)) },
+}
+
+// supportedChecksums returns the list of supported checksum algorithms, as
+// advertised in the Sec-Rest-Checksum header
+func supportedChecksums() string {
+	var res []string
+	for k := range checksumAlgorithms {
+		res = append(res, k)
+	}
+	sort.Strings(res)
+	return strings.Join(res, ", ")
+}
+
+// hashReader computes checksums of the data read through it. Data read again
+// after seeking back (when retrying) is only hashed once. If a part of the
+// data is skipped, the checksums are marked incomplete.
+type hashReader struct {
+	r      io.Reader
+	hashes map[string]hash.Hash
+	w      io.Writer
+	pos    int64 // current position
+	hashed int64 // number of bytes hashed
+	gap    bool  // some data was skipped
+}
+
+type hashReadSeeker struct {
+	*hashReader
+	s io.Seeker
+}
+
+// hashReader returns a reader computing the checksums of data read from f,
+// which is an io.Seeker if f is one
+func (u *UploadInfo) hashReader(f io.Reader) io.Reader {
+	h := &hashReader{r: f, hashes: map[string]hash.Hash{"sha256": checksumAlgorithms["sha256"]()}}
+	if newHash, ok := checksumAlgorithms[u.checksum]; ok && u.checksum != "sha256" {
+		h.hashes[u.checksum] = newHash()
+	}
+	var w []io.Writer
+	for _, hh := range h.hashes {
+		w = append(w, hh)
+	}
+	h.w = io.MultiWriter(w...)
+	u.hash = h
+
+	if s, ok := f.(io.Seeker); ok {
+		return &hashReadSeeker{hashReader: h, s: s}
+	}
+	return h
+}
+
+func (h *hashReader) Read(b []byte) (int, error) {
+	n, err := h.r.Read(b)
+	if n > 0 {
+		end := h.pos + int64(n)
+		if h.pos > h.hashed {
+			h.gap = true
+		} else if end > h.hashed {
+			h.w.Write(b[h.hashed-h.pos : n])
+			h.hashed = end
+		}
+		h.pos = end
+	}
+	return n, err
+}
+
+func (h *hashReadSeeker) Seek(offset int64, whence int) (int64, error) {
+	pos, err := h.s.Seek(offset, whence)
+	if err == nil {
+		h.pos = pos
+	}
+	return pos, err
+}
+
+// complete returns true if all the data went through the hashes
+func (h *hashReader) complete(size int64) bool {
+	return !h.gap && (size < 0 || h.hashed == size)
+}
+
+// checksum returns the hex encoded checksum for algo
+func (h *hashReader) checksum(algo string) string {
+	return hex.EncodeToString(h.hashes[algo].Sum(nil))
+}
+
+// verify compares the size and checksums returned by the server on
+// completion with the data that was read, and records the checksum in the
+// upload result
+func (u *UploadInfo) verify(res *Response) error {
+	if u.hash == nil {
+		return nil
+	}
+
+	algo := "sha256"
+	if _, ok := u.hash.hashes[u.checksum]; ok {
+		algo = u.checksum
+	}
+	if u.hash.complete(u.size) {
+		sum := u.hash.checksum(algo)
+		u.updateResult(func(r *UploadResult) {
+			r.ChecksumAlgorithm = algo
+			r.Checksum = sum
+		})
+	}
+	if u.SkipVerify {
+		return nil
+	}
+
+	size := u.size
+	if size < 0 && !u.hash.gap {
+		size = u.hash.hashed
+	}
+	if remote, ok := responseInt(res, "Size"); ok && size >= 0 && remote != size {
+		return &IntegrityError{Field: "size", Local: strconv.FormatInt(size, 10), Remote: strconv.FormatInt(remote, 10)}
+	}
+
+	if !u.hash.complete(u.size) {
+		// not all data went through the hashes, for example when resuming
+		return nil
+	}
+	for algo := range u.hash.hashes {
+		remote := responseChecksum(res, algo)
+		if remote == "" {
+			continue
+		}
+		sum := u.hash.hashes[algo].Sum(nil)
+		if !checksumEqual(remote, sum) {
+			return &IntegrityError{Field: algo, Local: hex.EncodeToString(sum), Remote: remote}
+		}
+	}
+	return nil
+}
+
+// responseChecksum returns the checksum for algo in the data of res, if any
+func responseChecksum(res *Response, algo string) string {
+	upper := strings.ToUpper(algo)
+	for _, k := range []string{upper, upper[:1] + algo[1:]} {
+		if v, err := res.GetString(k); err == nil && v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// checksumEqual returns true if remote, encoded as hex or base64, is sum
+func checksumEqual(remote string, sum []byte) bool {
+	if strings.EqualFold(remote, hex.EncodeToString(sum)) {
+		return true
+	}
+	return remote == base64.StdEncoding.EncodeToString(sum)
+}
+
+// responseInt returns the integer value of k in the data of res, if any
+func responseInt(res *Response, k string) (int64, bool) {
+	v, err := res.Get(k)
+	if err != nil {
+		return 0, false
+	}
+	switch n := v.(type) {
+	case float64:
+		return int64(n), true
+	case string:
+		i, err := strconv.ParseInt(n, 10, 64)
+		return i, err == nil
+	case interface{ Int64() (int64, error) }:
+		i, err := n.Int64()
+		return i, err == nil
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,17 @@
+//go:build !tinygo
+
+package rest
+
+import (
+	"encoding/xml"
+	"io"
+)
+
+// decodeAwsResp reads a S3 multipart upload initialization response
+func decodeAwsResp(r io.Reader) (*uploadAwsResp, error) {
+	res := &uploadAwsResp{}
+	if err := xml.NewDecoder(r).Decode(res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
@@ -0,0 +1,46 @@
+//go:build tinygo
+
+package rest
+
+import (
+	"errors"
+	"io"
+	"strings"
+)
+
+// decodeAwsResp reads a S3 multipart upload initialization response. This
+// version avoids encoding/xml, which is heavy and only partially supported
+// by TinyGo, and simply looks for the elements we need.
+func decodeAwsResp(r io.Reader) (*uploadAwsResp, error) {
+	buf, err := io.ReadAll(io.LimitReader(r, 64*1024))
+	if err != nil {
+		return nil, err
+	}
+	s := string(buf)
+	if !strings.Contains(s, "<InitiateMultipartUploadResult") {
+		return nil, errors.New("unexpected response from aws")
+	}
+
+	return &uploadAwsResp{
+		Bucket:   xmlElement(s, "Bucket"),
+		Key:      xmlElement(s, "Key"),
+		UploadId: xmlElement(s, "UploadId"),
+	}, nil
+}
+
+// xmlElement returns the unescaped text content of the first element with
+// the given name in s
+func xmlElement(s, name string) string {
+	start := strings.Index(s, "<"+name+">")
+	if start == -1 {
+		return ""
+	}
+	s = s[start+len(name)+2:]
+	end := strings.Index(s, "</"+name+">")
+	if end == -1 {
+		return ""
+	}
+	return xmlUnescaper.Replace(s[:end])
+}
+
+var xmlUnescaper = strings.NewReplacer("&lt;", "<", "&gt;", ">", "&quot;", "\"", "&apos;", "'", "&amp;", "&")
@@ -0,0 +1,80 @@
+package rest
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// APIVersion, if set, is sent with each API request so the server keeps the
+// behavior of that version of the API, see also WithAPIVersion
+var APIVersion string
+
+// OnDeprecation, if set, is called when the server reports a called endpoint
+// as deprecated. Otherwise a warning is logged once per endpoint.
+var OnDeprecation func(ctx context.Context, d *Deprecation)
+
+// Deprecation describes a deprecation notice returned by the server
+type Deprecation struct {
+	Method  string
+	Path    string
+	Since   string    // value of the Deprecation header, if any
+	Sunset  time.Time // when the endpoint will be removed, zero if unknown
+	Message string    // text of the Warning header, if any
+}
+
+var deprecationLogged sync.Map
+
+// WithAPIVersion returns a context in which requests are sent for version v
+// of the API instead of APIVersion
+func WithAPIVersion(ctx context.Context, v string) context.Context {
+	return context.WithValue(ctx, Version, v)
+}
+
+// apiVersion returns the API version to request for ctx
+func apiVersion(ctx context.Context) string {
+	if v, ok := ctx.Value(Version).(string); ok {
+		return v
+	}
+	return APIVersion
+}
+
+// checkDeprecation reports deprecation notices found in a response header
+func checkDeprecation(ctx context.Context, method, path string, h http.Header) {
+	d := &Deprecation{Method: method, Path: path, Since: h.Get("Deprecation")}
+	if s := h.Get("Sunset"); s != "" {
+		d.Sunset, _ = http.ParseTime(s)
+	}
+	for _, w := range h.Values("Warning") {
+		// 299 is the "miscellaneous persistent warning" code
+		if code, text, ok := strings.Cut(w, " "); ok && code == "299" {
+			if _, text, ok = strings.Cut(text, " "); ok {
+				text, _, _ = strings.Cut(strings.TrimPrefix(text, "\""), "\"")
+				d.Message = text
+			}
+		}
+	}
+	if d.Since == "" && d.Sunset.IsZero() && d.Message == "" {
+		return
+	}
+
+	if OnDeprecation != nil {
+		OnDeprecation(ctx, d)
+		return
+	}
+	if _, loaded := deprecationLogged.LoadOrStore(method+" "+path, true); loaded {
+		return
+	}
+	msg := fmt.Sprintf("[rest] %s %s is deprecated", method, path)
+	if !d.Sunset.IsZero() {
+		msg += fmt.Sprintf(" and will be removed on %s", d.Sunset.Format(time.DateOnly))
+	}
+	if d.Message != "" {
+		msg += ": " + d.Message
+	}
+	slog.WarnContext(ctx, msg, "event", "rest:deprecated", "rest:method", method, "rest:request", path)
+}
@@ -0,0 +1,49 @@
+package rest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestAPIVersion(t *testing.T) {
+	var version string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		version = r.Header.Get("Sec-Rest-Version")
+		w.Header().Set("Deprecation", "true")
+		w.Header().Set("Sunset", "Wed, 01 Jan 2031 00:00:00 GMT")
+		w.Header().Set("Warning", `299 - "use Test/new instead"`)
+		w.Write([]byte(`{"result":"success","data":null}`))
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+	ctx := context.WithValue(context.Background(), BackendURL, u)
+
+	var dep *Deprecation
+	OnDeprecation = func(ctx context.Context, d *Deprecation) { dep = d }
+	APIVersion = "2"
+	defer func() {
+		OnDeprecation = nil
+		APIVersion = ""
+	}()
+
+	if _, err := Do(ctx, "Test/old", "GET", nil); err != nil {
+		t.Fatalf("Do failed: %s", err)
+	}
+	if version != "2" {
+		t.Errorf("expected version 2, got %q", version)
+	}
+	if dep == nil || dep.Path != "Test/old" || dep.Message != "use Test/new instead" || !dep.Sunset.Equal(time.Date(2031, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected deprecation %+v", dep)
+	}
+
+	if _, err := Do(WithAPIVersion(ctx, "3"), "Test/old", "GET", nil); err != nil {
+		t.Fatalf("Do failed: %s", err)
+	}
+	if version != "3" {
+		t.Errorf("expected version 3, got %q", version)
+	}
+}